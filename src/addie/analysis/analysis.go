@@ -0,0 +1,510 @@
+/*
+The analysis package computes operating points and linear models of the
+physical half of a design. It works on the explicit equation systems of the
+eqn package, either of a single model or of a set of phyos flattened into one
+system through the plinks between them.
+*/
+package analysis
+
+import (
+	"addie"
+	"addie/eqn"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+A Problem is an equation system together with the values of its parameters
+and of the inputs that are held fixed. Inputs are the variables the linear
+model is taken with respect to, Outputs are the variables it observes.
+*/
+type Problem struct {
+	Sys     *eqn.System
+	Env     eqn.Env
+	Inputs  []string
+	Outputs []string
+	//an initial guess for the equilibrium
+	Guess eqn.Env
+}
+
+type Eigenvalue struct {
+	Re float64 `json:"re"`
+	Im float64 `json:"im"`
+}
+
+type Result struct {
+	States      []string           `json:"states"`
+	Inputs      []string           `json:"inputs"`
+	Outputs     []string           `json:"outputs"`
+	Point       map[string]float64 `json:"point"`
+	Converged   bool               `json:"converged"`
+	Residual    float64            `json:"residual"`
+	A           Matrix             `json:"A"`
+	B           Matrix             `json:"B"`
+	C           Matrix             `json:"C"`
+	D           Matrix             `json:"D"`
+	Eigenvalues []Eigenvalue       `json:"eigenvalues"`
+	//stable, marginal or unstable
+	Stability string `json:"stability"`
+}
+
+func parseAssignments(s string) map[string]float64 {
+
+	result := make(map[string]float64)
+	for _, a := range strings.Split(strings.Replace(s, " ", "", -1), ",") {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(kv[1], 64)
+		if err == nil {
+			result[kv[0]] = v
+		}
+	}
+
+	return result
+
+}
+
+/*
+ModelProblem sets up the analysis of a single model. Every parameter of the
+model must have a value, the inputs of the model are held at the values in
+inputs.
+*/
+func ModelProblem(m addie.Model, params, inputs map[string]float64) (*Problem, error) {
+
+	eqs, err := eqn.ParseEquations(m.Equations)
+	if err != nil {
+		return nil, err
+	}
+	sys, err := eqn.NewSystem(eqs)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Problem{Sys: sys, Env: make(eqn.Env), Guess: make(eqn.Env)}
+	ps := make(map[string]bool)
+	for _, x := range strings.Split(strings.Replace(m.Params, " ", "", -1), ",") {
+		if x == "" {
+			continue
+		}
+		v, ok := params[x]
+		if !ok {
+			return nil, fmt.Errorf("the parameter %s has no value", x)
+		}
+		p.Env[x] = v
+		ps[x] = true
+	}
+
+	for _, u := range sys.Inputs() {
+		if ps[u] {
+			continue
+		}
+		v, ok := inputs[u]
+		if !ok {
+			return nil, fmt.Errorf("the input %s has no value", u)
+		}
+		p.Env[u] = v
+		p.Inputs = append(p.Inputs, u)
+	}
+
+	p.Outputs = append(append([]string{}, sys.States...), sys.Algebraics...)
+
+	return p, nil
+
+}
+
+/*
+PhyoProblem sets up the analysis of a set of phyos of a design. The variables
+of each phyo are named phyo.variable, the plinks between the phyos become
+equations and the parameters take the values of the phyo args unless params
+overrides them. Bindings to elements outside the set, such as saxs, are
+inputs held at the values in inputs.
+*/
+func PhyoProblem(dsg *addie.Design, models []addie.Model, phyos []string,
+	params, inputs map[string]float64) (*Problem, error) {
+
+	mdls := make(map[string]addie.Model)
+	for _, m := range models {
+		mdls[m.Name] = m
+	}
+
+	selected := make(map[addie.Id]bool)
+	byName := make(map[string]addie.Phyo)
+	for _, e := range dsg.Elements {
+		if p, ok := e.(addie.Phyo); ok {
+			byName[p.Name] = p
+		}
+	}
+
+	var eqs []eqn.Equation
+	env := make(eqn.Env)
+	guess := make(eqn.Env)
+	ps := make(map[string]bool)
+
+	for _, name := range phyos {
+		p, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("the design has no phyo %s", name)
+		}
+		selected[p.Id] = true
+
+		m, ok := mdls[p.Model]
+		if !ok {
+			return nil, fmt.Errorf("the phyo %s uses the unknown model %s", name, p.Model)
+		}
+		meqs, err := eqn.ParseEquations(m.Equations)
+		if err != nil {
+			return nil, fmt.Errorf("phyo %s: %v", name, err)
+		}
+
+		prefix := func(v string) string { return name + "." + v }
+		for _, e := range meqs {
+			eqs = append(eqs, eqn.Equation{
+				Lhs: eqn.Rename(e.Lhs, prefix),
+				Rhs: eqn.Rename(e.Rhs, prefix),
+				Src: name + ": " + e.Src,
+			})
+		}
+
+		args := parseAssignments(p.Args)
+		for _, x := range strings.Split(strings.Replace(m.Params, " ", "", -1), ",") {
+			if x == "" {
+				continue
+			}
+			v, ok := params[prefix(x)]
+			if !ok {
+				v, ok = args[x]
+			}
+			if !ok {
+				return nil, fmt.Errorf("the parameter %s of phyo %s has no value", x, name)
+			}
+			env[prefix(x)] = v
+			ps[prefix(x)] = true
+		}
+		for k, v := range parseAssignments(p.Init) {
+			guess[prefix(k)] = v
+		}
+	}
+
+	//plinks inside the set define the inputs they bind to
+	var links []addie.Plink
+	for _, e := range dsg.Elements {
+		if l, ok := e.(addie.Plink); ok && selected[l.Endpoints[0]] && selected[l.Endpoints[1]] {
+			links = append(links, l)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Name < links[j].Name })
+
+	defined := make(map[string]bool)
+	for _, e := range eqs {
+		switch l := e.Lhs.(type) {
+		case eqn.Der:
+			defined[string(l)] = true
+		case eqn.Var:
+			defined[string(l)] = true
+		}
+	}
+
+	for _, l := range links {
+		a := strings.Split(strings.Replace(l.Bindings[0], " ", "", -1), ",")
+		b := strings.Split(strings.Replace(l.Bindings[1], " ", "", -1), ",")
+		if len(a) != len(b) {
+			return nil, fmt.Errorf("the plink %s binds %d variables to %d", l.Name,
+				len(a), len(b))
+		}
+		an, bn := dsg.Elements[l.Endpoints[0]].Identify().Name,
+			dsg.Elements[l.Endpoints[1]].Identify().Name
+		for i := range a {
+			x, y := an+"."+a[i], bn+"."+b[i]
+			if defined[x] && defined[y] {
+				return nil, fmt.Errorf("the plink %s binds %s to %s, both are defined "+
+					"by their models", l.Name, x, y)
+			}
+			if defined[y] {
+				x, y = y, x
+			}
+			eqs = append(eqs, eqn.Equation{Lhs: eqn.Var(y), Rhs: eqn.Var(x),
+				Src: l.Name + ": " + y + " = " + x})
+			defined[y] = true
+		}
+	}
+
+	sys, err := eqn.NewSystem(eqs)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Problem{Sys: sys, Env: env, Guess: guess}
+	for _, u := range sys.Inputs() {
+		if ps[u] {
+			continue
+		}
+		v, ok := inputs[u]
+		if !ok {
+			return nil, fmt.Errorf("the input %s has no value", u)
+		}
+		p.Env[u] = v
+		p.Inputs = append(p.Inputs, u)
+	}
+	p.Outputs = append(append([]string{}, sys.States...), sys.Algebraics...)
+
+	return p, nil
+
+}
+
+/*
+eval evaluates the derivatives and outputs at states x and inputs u
+*/
+func (p *Problem) eval(x, u []float64) ([]float64, []float64, error) {
+
+	env := make(eqn.Env)
+	for k, v := range p.Env {
+		env[k] = v
+	}
+	env["time"] = 0
+	for i, s := range p.Sys.States {
+		env[s] = x[i]
+	}
+	for i, s := range p.Inputs {
+		env[s] = u[i]
+	}
+
+	err := p.Sys.Update(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := make([]float64, len(p.Sys.States))
+	for i, s := range p.Sys.States {
+		f[i] = env[s+"'"]
+	}
+	y := make([]float64, len(p.Outputs))
+	for i, o := range p.Outputs {
+		v, ok := env[o]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s is not a variable of the system", o)
+		}
+		y[i] = v
+	}
+
+	return f, y, nil
+
+}
+
+func norm(v []float64) float64 {
+	s := 0.0
+	for _, x := range v {
+		s += x * x
+	}
+	return math.Sqrt(s)
+}
+
+func step(x float64) float64 { return 1e-6 * math.Max(1, math.Abs(x)) }
+
+/*
+jacobian differentiates the derivatives and outputs with central differences,
+with respect to the states when wrt is 0 and to the inputs otherwise
+*/
+func (p *Problem) jacobian(x, u []float64, wrt int) (Matrix, Matrix, error) {
+
+	v := x
+	if wrt != 0 {
+		v = u
+	}
+
+	df := NewMatrix(len(p.Sys.States), len(v))
+	dy := NewMatrix(len(p.Outputs), len(v))
+
+	for j := range v {
+		h := step(v[j])
+		orig := v[j]
+
+		v[j] = orig + h
+		fp, yp, err := p.eval(x, u)
+		if err != nil {
+			v[j] = orig
+			return nil, nil, err
+		}
+		v[j] = orig - h
+		fm, ym, err := p.eval(x, u)
+		v[j] = orig
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range fp {
+			df[i][j] = (fp[i] - fm[i]) / (2 * h)
+		}
+		for i := range yp {
+			dy[i][j] = (yp[i] - ym[i]) / (2 * h)
+		}
+	}
+
+	return df, dy, nil
+
+}
+
+/*
+Equilibrium solves for the states at which every derivative vanishes with a
+damped Newton iteration started from the guess, states the guess does not
+cover start at 0. It returns the states found and the norm of the remaining
+derivatives.
+*/
+func (p *Problem) Equilibrium() ([]float64, float64, bool, error) {
+
+	x := make([]float64, len(p.Sys.States))
+	for i, s := range p.Sys.States {
+		x[i] = p.Guess[s]
+	}
+	u := p.inputValues()
+
+	f, _, err := p.eval(x, u)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	r := norm(f)
+
+	for it := 0; it < 100 && r > 1e-10; it++ {
+		a, _, err := p.jacobian(x, u, 0)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		neg := make([]float64, len(f))
+		for i := range f {
+			neg[i] = -f[i]
+		}
+		dx, err := Solve(a, neg)
+		if err != nil {
+			return x, r, false, nil
+		}
+
+		//halve the step until the residual decreases
+		improved := false
+		for lambda := 1.0; lambda > 1e-6; lambda /= 2 {
+			xn := make([]float64, len(x))
+			for i := range x {
+				xn[i] = x[i] + lambda*dx[i]
+			}
+			fn, _, err := p.eval(xn, u)
+			if err == nil && norm(fn) < r && !math.IsNaN(norm(fn)) {
+				x, f, r = xn, fn, norm(fn)
+				improved = true
+				break
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+
+	return x, r, r <= 1e-8, nil
+
+}
+
+func (p *Problem) inputValues() []float64 {
+	u := make([]float64, len(p.Inputs))
+	for i, s := range p.Inputs {
+		u[i] = p.Env[s]
+	}
+	return u
+}
+
+/*
+Linearize computes the state space matrices of the system around the states
+x and the fixed inputs
+*/
+func (p *Problem) Linearize(x []float64) (a, b, c, d Matrix, err error) {
+
+	u := p.inputValues()
+
+	a, c, err = p.jacobian(x, u, 0)
+	if err != nil {
+		return
+	}
+	b, d, err = p.jacobian(x, u, 1)
+
+	return
+
+}
+
+/*
+Analyze finds an operating point and linearizes the system around it. When
+point is given the system is linearized around it instead of an equilibrium.
+*/
+func Analyze(p *Problem, point map[string]float64) (*Result, error) {
+
+	res := &Result{States: p.Sys.States, Inputs: p.Inputs, Outputs: p.Outputs,
+		Point: make(map[string]float64)}
+
+	var x []float64
+	if point != nil {
+		x = make([]float64, len(p.Sys.States))
+		for i, s := range p.Sys.States {
+			v, ok := point[s]
+			if !ok {
+				return nil, fmt.Errorf("the operating point does not set the state %s", s)
+			}
+			x[i] = v
+		}
+		f, _, err := p.eval(x, p.inputValues())
+		if err != nil {
+			return nil, err
+		}
+		res.Residual = norm(f)
+		res.Converged = res.Residual <= 1e-8
+	} else {
+		var err error
+		x, res.Residual, res.Converged, err = p.Equilibrium()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, s := range p.Sys.States {
+		res.Point[s] = x[i]
+	}
+
+	var err error
+	res.A, res.B, res.C, res.D, err = p.Linearize(x)
+	if err != nil {
+		return nil, err
+	}
+
+	ev, err := Eigenvalues(res.A)
+	if err != nil {
+		return nil, err
+	}
+	maxRe := math.Inf(-1)
+	for _, e := range ev {
+		res.Eigenvalues = append(res.Eigenvalues, Eigenvalue{real(e), imag(e)})
+		maxRe = math.Max(maxRe, real(e))
+	}
+
+	tol := 1e-7 * math.Max(1, normInf(res.A))
+	switch {
+	case len(ev) == 0 || maxRe < -tol:
+		res.Stability = "stable"
+	case maxRe <= tol:
+		res.Stability = "marginal"
+	default:
+		res.Stability = "unstable"
+	}
+
+	return res, nil
+
+}
+
+func normInf(m Matrix) float64 {
+	r := 0.0
+	for _, row := range m {
+		s := 0.0
+		for _, v := range row {
+			s += math.Abs(v)
+		}
+		r = math.Max(r, s)
+	}
+	return r
+}
@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"addie"
+	"math"
+	"math/cmplx"
+	"sort"
+	"testing"
+)
+
+func sortedEigenvalues(t *testing.T, m Matrix) []complex128 {
+
+	ev, err := Eigenvalues(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(ev, func(i, j int) bool {
+		if real(ev[i]) != real(ev[j]) {
+			return real(ev[i]) < real(ev[j])
+		}
+		return imag(ev[i]) < imag(ev[j])
+	})
+	return ev
+
+}
+
+func TestEigenvalues(t *testing.T) {
+
+	cases := []struct {
+		m        Matrix
+		expected []complex128
+	}{
+		{Matrix{{0, 1}, {-2, -3}}, []complex128{-2, -1}},
+		{Matrix{{0, -1}, {1, 0}}, []complex128{-1i, 1i}},
+		//companion matrix of (s+1)(s+2)(s+3)(s+4)
+		{Matrix{{0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}, {-24, -50, -35, -10}},
+			[]complex128{-4, -3, -2, -1}},
+		{Matrix{{2, 0, 0}, {0, -1, 5}, {0, 0, 3}}, []complex128{-1, 2, 3}},
+	}
+
+	for _, c := range cases {
+		ev := sortedEigenvalues(t, c.m)
+		for i := range ev {
+			if cmplx.Abs(ev[i]-c.expected[i]) > 1e-9 {
+				t.Fatalf("eigenvalues of %v are %v, expected %v", c.m, ev, c.expected)
+			}
+		}
+	}
+
+}
+
+func TestPendulum(t *testing.T) {
+
+	m := addie.Model{Name: "Pendulum", Params: "g,l,b",
+		Equations: "th' = om\nom' = -g/l*sin(th) - b*om + u"}
+
+	p, err := ModelProblem(m, map[string]float64{"g": 9.81, "l": 1, "b": 0.5},
+		map[string]float64{"u": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//hanging down is stable
+	p.Guess["th"] = 0.3
+	res, err := Analyze(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Converged || math.Abs(res.Point["th"]) > 1e-8 || res.Stability != "stable" {
+		t.Fatalf("bad hanging equilibrium %+v", res)
+	}
+	if math.Abs(res.A[1][0]+9.81) > 1e-5 || math.Abs(res.B[1][0]-1) > 1e-6 {
+		t.Fatalf("bad linearization A=%v B=%v", res.A, res.B)
+	}
+
+	//upright is not
+	res, err = Analyze(p, map[string]float64{"th": math.Pi, "om": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Converged || res.Stability != "unstable" {
+		t.Fatalf("bad upright analysis %+v", res)
+	}
+
+}
+
+func TestPhyoProblem(t *testing.T) {
+
+	tank := addie.Model{Name: "Tank", Params: "A,k",
+		Equations: "h' = (qin - qout)/A\nqout = k*h"}
+
+	dsg := addie.EmptyDesign("lagavulin")
+	for _, n := range []string{"upper", "lower"} {
+		p := addie.Phyo{}
+		p.Id = addie.Id{Name: n, Sys: "root", Design: "lagavulin"}
+		p.Model = "Tank"
+		p.Args = "A=2, k=0.5"
+		dsg.Elements[p.Id] = p
+	}
+	l := addie.Plink{}
+	l.Id = addie.Id{Name: "pipe", Sys: "root", Design: "lagavulin"}
+	l.Endpoints = [2]addie.Id{
+		{Name: "upper", Sys: "root", Design: "lagavulin"},
+		{Name: "lower", Sys: "root", Design: "lagavulin"}}
+	l.Bindings = [2]string{"qout", "qin"}
+	dsg.Elements[l.Id] = l
+
+	p, err := PhyoProblem(&dsg, []addie.Model{tank}, []string{"upper", "lower"},
+		map[string]float64{"lower.k": 0.25}, map[string]float64{"upper.qin": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Inputs) != 1 || p.Inputs[0] != "upper.qin" {
+		t.Fatalf("bad inputs %v", p.Inputs)
+	}
+
+	res, err := Analyze(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Converged || math.Abs(res.Point["upper.h"]-2) > 1e-6 ||
+		math.Abs(res.Point["lower.h"]-4) > 1e-6 || res.Stability != "stable" {
+		t.Fatalf("bad cascade equilibrium %+v", res)
+	}
+
+}
@@ -0,0 +1,320 @@
+/*
+This file contains the dense linear algebra the analyses need, a linear
+solver and an eigenvalue solver for general real matrices
+*/
+package analysis
+
+import (
+	"fmt"
+	"math"
+)
+
+type Matrix [][]float64
+
+func NewMatrix(rows, cols int) Matrix {
+	m := make(Matrix, rows)
+	for i := range m {
+		m[i] = make([]float64, cols)
+	}
+	return m
+}
+
+func (m Matrix) copy() Matrix {
+	c := make(Matrix, len(m))
+	for i := range m {
+		c[i] = append([]float64(nil), m[i]...)
+	}
+	return c
+}
+
+/*
+Solve solves a x = b by Gaussian elimination with partial pivoting
+*/
+func Solve(a Matrix, b []float64) ([]float64, error) {
+
+	n := len(b)
+	a = a.copy()
+	x := append([]float64(nil), b...)
+
+	for k := 0; k < n; k++ {
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(a[i][k]) > math.Abs(a[p][k]) {
+				p = i
+			}
+		}
+		if a[p][k] == 0 {
+			return nil, fmt.Errorf("the matrix is singular")
+		}
+		a[k], a[p] = a[p], a[k]
+		x[k], x[p] = x[p], x[k]
+
+		for i := k + 1; i < n; i++ {
+			f := a[i][k] / a[k][k]
+			for j := k; j < n; j++ {
+				a[i][j] -= f * a[k][j]
+			}
+			x[i] -= f * x[k]
+		}
+	}
+
+	for k := n - 1; k >= 0; k-- {
+		for j := k + 1; j < n; j++ {
+			x[k] -= a[k][j] * x[j]
+		}
+		x[k] /= a[k][k]
+	}
+
+	return x, nil
+
+}
+
+/*
+Eigenvalues computes the eigenvalues of a square matrix. The matrix is
+reduced to upper Hessenberg form by elimination and the eigenvalues of the
+Hessenberg matrix are found with the shifted QR algorithm.
+*/
+func Eigenvalues(m Matrix) ([]complex128, error) {
+
+	n := len(m)
+	if n == 0 {
+		return nil, nil
+	}
+
+	//the QR iteration below is written with 1 based indices
+	a := NewMatrix(n+1, n+1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			a[i+1][j+1] = m[i][j]
+		}
+	}
+
+	hessenberg(a, n)
+	wr, wi, err := hqr(a, n)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]complex128, n)
+	for i := 0; i < n; i++ {
+		result[i] = complex(wr[i+1], wi[i+1])
+	}
+
+	return result, nil
+
+}
+
+func hessenberg(a Matrix, n int) {
+
+	for m := 2; m < n; m++ {
+		x, i := 0.0, m
+		for j := m; j <= n; j++ {
+			if math.Abs(a[j][m-1]) > math.Abs(x) {
+				x, i = a[j][m-1], j
+			}
+		}
+		if i != m {
+			for j := m - 1; j <= n; j++ {
+				a[i][j], a[m][j] = a[m][j], a[i][j]
+			}
+			for j := 1; j <= n; j++ {
+				a[j][i], a[j][m] = a[j][m], a[j][i]
+			}
+		}
+		if x != 0 {
+			for i := m + 1; i <= n; i++ {
+				y := a[i][m-1]
+				if y != 0 {
+					y /= x
+					a[i][m-1] = y
+					for j := m; j <= n; j++ {
+						a[i][j] -= y * a[m][j]
+					}
+					for j := 1; j <= n; j++ {
+						a[j][m] += y * a[j][i]
+					}
+				}
+			}
+		}
+	}
+
+	//clear the multipliers left below the subdiagonal
+	for i := 3; i <= n; i++ {
+		for j := 1; j < i-1; j++ {
+			a[i][j] = 0
+		}
+	}
+
+}
+
+func withSign(a, b float64) float64 {
+	if b >= 0 {
+		return math.Abs(a)
+	}
+	return -math.Abs(a)
+}
+
+func hqr(a Matrix, n int) ([]float64, []float64, error) {
+
+	wr := make([]float64, n+1)
+	wi := make([]float64, n+1)
+
+	anorm := 0.0
+	for i := 1; i <= n; i++ {
+		for j := int(math.Max(float64(i-1), 1)); j <= n; j++ {
+			anorm += math.Abs(a[i][j])
+		}
+	}
+
+	var p, q, r, s, w, x, y, z float64
+	nn, t := n, 0.0
+	for nn >= 1 {
+		its := 0
+		var l int
+		for {
+			for l = nn; l >= 2; l-- {
+				s = math.Abs(a[l-1][l-1]) + math.Abs(a[l][l])
+				if s == 0 {
+					s = anorm
+				}
+				if math.Abs(a[l][l-1])+s == s {
+					a[l][l-1] = 0
+					break
+				}
+			}
+			x = a[nn][nn]
+			if l == nn {
+				wr[nn], wi[nn] = x+t, 0
+				nn--
+			} else {
+				y = a[nn-1][nn-1]
+				w = a[nn][nn-1] * a[nn-1][nn]
+				if l == nn-1 {
+					p = 0.5 * (y - x)
+					q = p*p + w
+					z = math.Sqrt(math.Abs(q))
+					x += t
+					if q >= 0 {
+						z = p + withSign(z, p)
+						wr[nn-1], wr[nn] = x+z, x+z
+						if z != 0 {
+							wr[nn] = x - w/z
+						}
+						wi[nn-1], wi[nn] = 0, 0
+					} else {
+						wr[nn-1], wr[nn] = x+p, x+p
+						wi[nn-1], wi[nn] = -z, z
+					}
+					nn -= 2
+				} else {
+					if its == 60 {
+						return nil, nil, fmt.Errorf("the eigenvalues did not converge")
+					}
+					if its == 10 || its == 20 {
+						//exceptional shift
+						t += x
+						for i := 1; i <= nn; i++ {
+							a[i][i] -= x
+						}
+						s = math.Abs(a[nn][nn-1]) + math.Abs(a[nn-1][nn-2])
+						x = 0.75 * s
+						y = x
+						w = -0.4375 * s * s
+					}
+					its++
+
+					var m int
+					for m = nn - 2; m >= l; m-- {
+						z = a[m][m]
+						r = x - z
+						s = y - z
+						p = (r*s-w)/a[m+1][m] + a[m][m+1]
+						q = a[m+1][m+1] - z - r - s
+						r = a[m+2][m+1]
+						s = math.Abs(p) + math.Abs(q) + math.Abs(r)
+						p /= s
+						q /= s
+						r /= s
+						if m == l {
+							break
+						}
+						u := math.Abs(a[m][m-1]) * (math.Abs(q) + math.Abs(r))
+						v := math.Abs(p) * (math.Abs(a[m-1][m-1]) + math.Abs(z) +
+							math.Abs(a[m+1][m+1]))
+						if u+v == v {
+							break
+						}
+					}
+					for i := m + 2; i <= nn; i++ {
+						a[i][i-2] = 0
+						if i != m+2 {
+							a[i][i-3] = 0
+						}
+					}
+
+					for k := m; k <= nn-1; k++ {
+						if k != m {
+							p = a[k][k-1]
+							q = a[k+1][k-1]
+							r = 0
+							if k != nn-1 {
+								r = a[k+2][k-1]
+							}
+							x = math.Abs(p) + math.Abs(q) + math.Abs(r)
+							if x != 0 {
+								p /= x
+								q /= x
+								r /= x
+							}
+						}
+						s = withSign(math.Sqrt(p*p+q*q+r*r), p)
+						if s == 0 {
+							continue
+						}
+						if k == m {
+							if l != m {
+								a[k][k-1] = -a[k][k-1]
+							}
+						} else {
+							a[k][k-1] = -s * x
+						}
+						p += s
+						x = p / s
+						y = q / s
+						z = r / s
+						q /= p
+						r /= p
+						for j := k; j <= nn; j++ {
+							p = a[k][j] + q*a[k+1][j]
+							if k != nn-1 {
+								p += r * a[k+2][j]
+								a[k+2][j] -= p * z
+							}
+							a[k+1][j] -= p * y
+							a[k][j] -= p * x
+						}
+						mmin := nn
+						if k+3 < nn {
+							mmin = k + 3
+						}
+						for i := l; i <= mmin; i++ {
+							p = x*a[i][k] + y*a[i][k+1]
+							if k != nn-1 {
+								p += z * a[i][k+2]
+								a[i][k+2] -= p * r
+							}
+							a[i][k+1] -= p * q
+							a[i][k] -= p
+						}
+					}
+				}
+			}
+			if l >= nn-1 {
+				break
+			}
+		}
+	}
+
+	return wr, wi, nil
+
+}
@@ -0,0 +1,132 @@
+/*
+This file contains the user defined attributes of elements
+*/
+package addie
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+Attributes are free form key value pairs a user attaches to an element. The
+attributes of computers, routers and saxes that the deter package knows are
+carried into the TopDL of the experiment, see deter.TopDLAttributes.
+*/
+type Attributes map[string]string
+
+func (a Attributes) Equals(x Attributes) bool {
+
+	if len(a) != len(x) {
+		return false
+	}
+	for k, v := range a {
+		_v, ok := x[k]
+		if !ok || v != _v {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+/*
+Keys returns the attribute names in order
+*/
+func (a Attributes) Keys() []string {
+
+	var result []string
+	for k := range a {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+
+	return result
+
+}
+
+/*
+ElementAttributes returns the attributes of an element, nil for elements that
+do not have any
+*/
+func ElementAttributes(e Identify) Attributes {
+
+	switch e.(type) {
+	case Computer:
+		return e.(Computer).Attributes
+	case Switch:
+		return e.(Switch).Attributes
+	case Router:
+		return e.(Router).Attributes
+	case Sax:
+		return e.(Sax).Attributes
+	case Link:
+		return e.(Link).Attributes
+	case Phyo:
+		return e.(Phyo).Attributes
+	case Plink:
+		return e.(Plink).Attributes
+	case Trace:
+		return e.(Trace).Attributes
+	case Sensor:
+		return e.(Sensor).Attributes
+	case Actuator:
+		return e.(Actuator).Attributes
+	}
+
+	return nil
+
+}
+
+/*
+WithAttributes returns a copy of an element with the attributes a
+*/
+func WithAttributes(e Identify, a Attributes) (Identify, error) {
+
+	switch e.(type) {
+	case Computer:
+		x := e.(Computer)
+		x.Attributes = a
+		return x, nil
+	case Switch:
+		x := e.(Switch)
+		x.Attributes = a
+		return x, nil
+	case Router:
+		x := e.(Router)
+		x.Attributes = a
+		return x, nil
+	case Sax:
+		x := e.(Sax)
+		x.Attributes = a
+		return x, nil
+	case Link:
+		x := e.(Link)
+		x.Attributes = a
+		return x, nil
+	case Phyo:
+		x := e.(Phyo)
+		x.Attributes = a
+		return x, nil
+	case Plink:
+		x := e.(Plink)
+		x.Attributes = a
+		return x, nil
+	case Trace:
+		x := e.(Trace)
+		x.Attributes = a
+		return x, nil
+	case Sensor:
+		x := e.(Sensor)
+		x.Attributes = a
+		return x, nil
+	case Actuator:
+		x := e.(Actuator)
+		x.Attributes = a
+		return x, nil
+	}
+
+	return nil, fmt.Errorf("elements of type %T do not have attributes", e)
+
+}
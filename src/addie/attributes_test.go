@@ -0,0 +1,31 @@
+package addie
+
+import "testing"
+
+func TestAttributes(t *testing.T) {
+
+	c := Computer{}
+	c.Id = Id{Name: "hmi", Sys: "root", Design: "talisker"}
+
+	e, err := WithAttributes(c, Attributes{"hardware": "pc3000", "osid": "Ubuntu1604-STD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ElementAttributes(e)
+	if a["hardware"] != "pc3000" || len(a.Keys()) != 2 || a.Keys()[0] != "hardware" {
+		t.Fatalf("bad attributes %v", a)
+	}
+
+	x := e.(Computer)
+	if x.Equals(&c) || !x.Equals(&x) {
+		t.Fatal("attributes are not compared")
+	}
+	if !Attributes(nil).Equals(Attributes{}) {
+		t.Fatal("no attributes differ from empty attributes")
+	}
+
+	if _, err := WithAttributes(Model{Name: "Tank"}, a); err == nil {
+		t.Fatal("a model took attributes")
+	}
+
+}
@@ -6,7 +6,9 @@ package db
 
 import (
 	"addie"
+	"addie/templates"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	_ "github.com/lib/pq"
@@ -14,6 +16,7 @@ import (
 	"path"
 	"runtime"
 	"strings"
+	"time"
 )
 
 //Common Variables ------------------------------------------------------------
@@ -360,6 +363,11 @@ func ReadDesign(name, owner string) (*addie.Design, error) {
 
 	dsg := addie.EmptyDesign(name)
 
+	dsg.Params, err = ReadDesignParams(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
 	//we are going to go from the top down, grabbing all of the systems
 	//and then grabbing the components of the systems
 	q := fmt.Sprintf("SELECT id FROM systems WHERE design_id = %d", key)
@@ -442,6 +450,15 @@ func ReadDesign(name, owner string) (*addie.Design, error) {
 			dsg.Elements[p.Id] = p
 		}
 
+		//traces
+		traces, err := ReadSystemTraces(sys_key)
+		if err != nil {
+			return nil, readFailure(err)
+		}
+		for _, t := range traces {
+			dsg.Elements[t.Id] = t
+		}
+
 	}
 
 	return &dsg, nil
@@ -452,8 +469,13 @@ func ReadDesign(name, owner string) (*addie.Design, error) {
 func CreateSimSettings(s addie.SimSettings, design_key int) error {
 
 	q := fmt.Sprintf(
-		"INSERT INTO sim_settings (design_id, tbegin, tend, max_step)"+
-			"VALUES (%d, %f, %f, %f)", design_key, s.Begin, s.End, s.MaxStep)
+		"INSERT INTO sim_settings (design_id, tbegin, tend, max_step, cluster_size, "+
+			"solver, abs_tol, rel_tol, sample_interval, record, "+
+			"real_time, real_time_ratio, checkpoint_interval, init_set)"+
+			"VALUES (%d, %f, %f, %f, %d, '%s', %g, %g, %g, '%s', %t, %g, %g, '%s')",
+		design_key, s.Begin, s.End, s.MaxStep, s.ClusterSize,
+		s.Solver, s.AbsTol, s.RelTol, s.SampleInterval, pgMathStr(s.Record),
+		s.RealTime, s.RealTimeRatio, s.CheckpointInterval, pgMathStr(s.InitSet))
 
 	err := runC(q)
 	if err != nil {
@@ -467,8 +489,15 @@ func CreateSimSettings(s addie.SimSettings, design_key int) error {
 func UpdateSimSettings(s addie.SimSettings, design_key int) error {
 
 	q := fmt.Sprintf(
-		"UPDATE sim_settings SET tbegin = %f, tend = %f, max_step = %f"+
-			"WHERE design_id = %d", s.Begin, s.End, s.MaxStep, design_key)
+		"UPDATE sim_settings SET tbegin = %f, tend = %f, max_step = %f, "+
+			"cluster_size = %d, solver = '%s', abs_tol = %g, rel_tol = %g, "+
+			"sample_interval = %g, record = '%s', "+
+			"real_time = %t, real_time_ratio = %g, checkpoint_interval = %g, "+
+			"init_set = '%s' "+
+			"WHERE design_id = %d", s.Begin, s.End, s.MaxStep, s.ClusterSize,
+		s.Solver, s.AbsTol, s.RelTol, s.SampleInterval, pgMathStr(s.Record),
+		s.RealTime, s.RealTimeRatio, s.CheckpointInterval, pgMathStr(s.InitSet),
+		design_key)
 
 	err := runC(q)
 	if err != nil {
@@ -482,7 +511,11 @@ func UpdateSimSettings(s addie.SimSettings, design_key int) error {
 func ReadSimSettingsByDesignId(design_id int) (*addie.SimSettings, error) {
 
 	q := fmt.Sprintf(
-		"SELECT tbegin, tend, max_step FROM sim_settings WHERE design_id = %d",
+		"SELECT tbegin, tend, max_step, cluster_size, "+
+			"solver, abs_tol, rel_tol, sample_interval, record, "+
+			"real_time, real_time_ratio, checkpoint_interval, init_set "+
+			"FROM sim_settings "+
+			"WHERE design_id = %d",
 		design_id)
 
 	rows, err := runQ(q)
@@ -495,452 +528,1682 @@ func ReadSimSettingsByDesignId(design_id int) (*addie.SimSettings, error) {
 		return nil, emptyReadFailure()
 	}
 	var begin, end, maxStep float64
-	err = rows.Scan(&begin, &end, &maxStep)
+	var clusterSize int
+	s := addie.SimSettings{}
+	err = rows.Scan(&begin, &end, &maxStep, &clusterSize,
+		&s.Solver, &s.AbsTol, &s.RelTol, &s.SampleInterval, &s.Record,
+		&s.RealTime, &s.RealTimeRatio, &s.CheckpointInterval, &s.InitSet)
 	if err != nil {
 		return nil, scanFailure(err)
 	}
 	rows.Close()
 
-	s := addie.SimSettings{}
 	s.Begin = begin
 	s.End = end
 	s.MaxStep = maxStep
+	s.ClusterSize = clusterSize
 
 	return &s, nil
 
 }
 
-// Systems --------------------------------------------------------------------
-
-func CreateSystem(name, design, owner string) (int, error) {
+// Design Parameters ----------------------------------------------------------
 
-	design_key, err := ReadDesignKey(design, owner)
-	if err != nil {
-		return -1, readFailure(err)
-	}
+func ReadDesignParams(design_key int) (map[string]float64, error) {
 
 	q := fmt.Sprintf(
-		"INSERT INTO systems (design_id, name) VALUES (%d, '%s') RETURNING id",
-		design_key, name)
+		"SELECT name, value FROM design_params WHERE design_id = %d", design_key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return -1, insertFailure(err)
+		return nil, selectFailure(err)
 	}
-	if !rows.Next() {
-		return -1, emptyReadbackFailure()
+
+	params := make(map[string]float64)
+	for rows.Next() {
+		var name string
+		var value float64
+		err = rows.Scan(&name, &value)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+		params[name] = value
 	}
-	var id_key int
-	err = rows.Scan(&id_key)
+
+	return params, nil
+
+}
+
+/*
+SetDesignParam sets the value of a design parameter, creating the parameter
+if it does not exist yet
+*/
+func SetDesignParam(name string, value float64, design, owner string) error {
+
+	design_key, err := ReadDesignKey(design, owner)
 	if err != nil {
-		return -1, scanFailure(err)
+		return readFailure(err)
 	}
 
-	return id_key, nil
+	q := fmt.Sprintf(
+		"UPDATE design_params SET value = %g WHERE design_id = %d AND name = '%s' "+
+			"RETURNING id",
+		value, design_key, name)
+
+	_, err = getKey(q)
+	if err == nil {
+		return nil
+	}
+
+	q = fmt.Sprintf(
+		"INSERT INTO design_params (design_id, name, value) VALUES (%d, '%s', %g)",
+		design_key, name, value)
+
+	err = runC(q)
+	if err != nil {
+		return insertFailure(err)
+	}
+
+	return nil
 
 }
 
-func ReadSysKey(name, design, owner string) (int, error) {
+func DeleteDesignParam(name, design, owner string) error {
 
 	design_key, err := ReadDesignKey(design, owner)
 	if err != nil {
-		return -1, readFailure(err)
+		return readFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT id FROM systems WHERE name = '%s' AND design_id = %d",
-		name, design_key)
+	q := fmt.Sprintf(
+		"DELETE FROM design_params WHERE design_id = %d AND name = '%s'",
+		design_key, name)
 
-	sys_key, err := getKey(q)
+	err = runC(q)
 	if err != nil {
-		return -1, selectFailure(err)
+		return deleteFailure(err)
 	}
 
-	return sys_key, nil
+	return nil
 
 }
 
-func ReadSystemComputers(key int) ([]addie.Computer, error) {
+// Init Sets ------------------------------------------------------------------
 
-	var result []addie.Computer
+func CreateInitSet(set addie.InitSet, design, owner string) error {
 
-	q := fmt.Sprintf(
-		"SELECT computers.id FROM computers "+
-			"INNER JOIN ids on computers.id = ids.id "+
-			"WHERE ids.sys_id = %d", key)
+	design_key, err := ReadDesignKey(design, owner)
+	if err != nil {
+		return readFailure(err)
+	}
 
-	rows, err := runQ(q)
-	defer safeClose(rows)
+	q := fmt.Sprintf(
+		"INSERT INTO init_sets (design_id, name) VALUES (%d, '%s') RETURNING id",
+		design_key, set.Name)
 
+	set_key, err := getKey(q)
 	if err != nil {
-		return nil, selectFailure(err)
+		return insertFailure(err)
 	}
 
-	for rows.Next() {
-		var comp_key int
-		err := rows.Scan(&comp_key)
+	for _, pi := range set.Inits {
+		phyo_key, err := ReadIdKey(pi.Phyo, owner)
 		if err != nil {
-			return nil, scanFailure(err)
+			return readFailure(err)
 		}
-
-		comp, err := ReadComputerByKey(comp_key)
-		if err != nil {
-			return nil, readFailure(err)
+		for state, value := range pi.Values {
+			q = fmt.Sprintf(
+				"INSERT INTO init_values (set_id, phyo_id, state, value) "+
+					"VALUES (%d, %d, '%s', %g)", set_key, phyo_key, state, value)
+			err = runC(q)
+			if err != nil {
+				return insertFailure(err)
+			}
 		}
-		result = append(result, *comp)
 	}
 
-	return result, nil
+	return nil
+
 }
 
-func SysRecycle() error {
+func DeleteInitSet(name, design, owner string) error {
 
-	//TODO
+	design_key, err := ReadDesignKey(design, owner)
+	if err != nil {
+		return readFailure(err)
+	}
+
+	q := fmt.Sprintf(
+		"DELETE FROM init_sets WHERE design_id = %d AND name = '%s'",
+		design_key, name)
+
+	err = runC(q)
+	if err != nil {
+		return deleteFailure(err)
+	}
 
 	return nil
 
 }
 
-// Ids ------------------------------------------------------------------------
-
-func ReadIdKey(id addie.Id, owner string) (int, error) {
+/*
+UpdateInitSet replaces the initial conditions of a set, creating the set if
+it does not exist yet
+*/
+func UpdateInitSet(set addie.InitSet, design, owner string) error {
 
-	sys_key, err := ReadSysKey(id.Sys, id.Design, owner)
+	err := DeleteInitSet(set.Name, design, owner)
 	if err != nil {
-		return -1, readFailure(err)
+		return deleteFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT id FROM ids WHERE name = '%s' AND sys_id = '%d'",
-		id.Name, sys_key)
-
-	id_key, err := getKey(q)
+	err = CreateInitSet(set, design, owner)
 	if err != nil {
-		log.Println(id)
-		return -1, selectFailure(err)
+		return createFailure(err)
 	}
-	return id_key, nil
+
+	return nil
+
 }
 
-func CreateId(id addie.Id, owner string) (int, error) {
-	sys_id, err := ReadSysKey(id.Sys, id.Design, owner)
+func ReadInitSets(design, owner string) ([]addie.InitSet, error) {
+
+	design_key, err := ReadDesignKey(design, owner)
 	if err != nil {
-		return -1, readFailure(err)
+		return nil, readFailure(err)
 	}
 
 	q := fmt.Sprintf(
-		"INSERT INTO ids (name, sys_id) VALUES ('%s', %d) RETURNING id",
-		id.Name, sys_id)
+		"SELECT init_sets.name, init_values.phyo_id, "+
+			"init_values.state, init_values.value "+
+			"FROM init_sets "+
+			"LEFT JOIN init_values ON init_values.set_id = init_sets.id "+
+			"WHERE init_sets.design_id = %d "+
+			"ORDER BY init_sets.name, init_values.phyo_id", design_key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return -1, insertFailure(err)
+		return nil, selectFailure(err)
 	}
 
-	if !rows.Next() {
-		return -1, emptyReadbackFailure()
+	type value struct {
+		set, state string
+		phyo       int
+		value      float64
 	}
-	var id_key int
-	err = rows.Scan(&id_key)
-	if err != nil {
-		return -1, scanFailure(err)
+	var vs []value
+	var order []string
+	seen := make(map[string]bool)
+
+	for rows.Next() {
+		var name string
+		var phyo sql.NullInt64
+		var state sql.NullString
+		var v sql.NullFloat64
+		err = rows.Scan(&name, &phyo, &state, &v)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+		if !seen[name] {
+			seen[name] = true
+			order = append(order, name)
+		}
+		if phyo.Valid {
+			vs = append(vs, value{name, state.String, int(phyo.Int64), v.Float64})
+		}
 	}
+	rows.Close()
+
+	sets := make(map[string]*addie.InitSet)
+	for _, name := range order {
+		sets[name] = &addie.InitSet{Name: name}
+	}
+
+	ids := make(map[int]*addie.Id)
+	for _, v := range vs {
+		id, ok := ids[v.phyo]
+		if !ok {
+			id, err = ReadId(v.phyo)
+			if err != nil {
+				return nil, readFailure(err)
+			}
+			ids[v.phyo] = id
+		}
+
+		set := sets[v.set]
+		n := len(set.Inits)
+		if n == 0 || set.Inits[n-1].Phyo != *id {
+			set.Inits = append(set.Inits,
+				addie.PhyoInit{Phyo: *id, Values: make(map[string]float64)})
+			n++
+		}
+		set.Inits[n-1].Values[v.state] = v.value
+	}
+
+	var result []addie.InitSet
+	for _, name := range order {
+		result = append(result, *sets[name])
+	}
+
+	return result, nil
 
-	return id_key, nil
 }
 
-func ReadId(id int) (*addie.Id, error) {
+// Sim Runs -------------------------------------------------------------------
 
-	_id := new(addie.Id)
+func CreateSimRun(r addie.SimRun, design_key int) (int, error) {
+
+	params, err := json.Marshal(r.Params)
+	if err != nil {
+		return -1, createFailure(err)
+	}
 
 	q := fmt.Sprintf(
-		"select ids.name, systems.name, designs.name "+
-			"from ids "+
-			"inner join systems on ids.sys_id = systems.id "+
-			"inner join designs on systems.design_id = designs.id "+
-			"where ids.id = %d", id)
+		"INSERT INTO sim_runs (design_id, started, resumed_from, params, init_set) "+
+			"VALUES (%d, '%s', '%s', '%s', '%s') RETURNING id",
+		design_key, r.Started.UTC().Format(time.RFC3339), pgMathStr(r.ResumedFrom),
+		pgMathStr(string(params)), pgMathStr(r.InitSet))
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return nil, selectFailure(err)
+		return -1, insertFailure(err)
 	}
 	if !rows.Next() {
-		return nil, emptyReadFailure()
+		return -1, emptyReadbackFailure()
 	}
-	err = rows.Scan(&_id.Name, &_id.Sys, &_id.Design)
+	var key int
+	err = rows.Scan(&key)
 	if err != nil {
-		return nil, scanFailure(err)
+		return -1, scanFailure(err)
 	}
 
-	return _id, nil
+	return key, nil
 
 }
 
-func DeleteId(id addie.Id, owner string) error {
+func ReadSimRuns(design_key int) ([]addie.SimRun, error) {
 
 	q := fmt.Sprintf(
-		"DELETE FROM ids "+
-			"USING systems, designs, users "+
-			"WHERE ids.sys_id = systems.id "+
-			"AND systems.design_id = designs.id "+
-			"AND designs.owner = users.id "+
-			"AND ids.name = '%s' "+
-			"AND systems.name = '%s' "+
-			"AND designs.name = '%s' "+
-			"AND users.name = '%s'", id.Name, id.Sys, id.Design, owner)
+		"SELECT id, started, resumed_from, params, init_set FROM sim_runs "+
+			"WHERE design_id = %d ORDER BY started", design_key)
 
-	err := runC(q)
+	rows, err := runQ(q)
+	defer safeClose(rows)
 	if err != nil {
-		return deleteFailure(err)
+		return nil, selectFailure(err)
 	}
 
-	return nil
+	var result []addie.SimRun
+	for rows.Next() {
+		var r addie.SimRun
+		var params string
+		err = rows.Scan(&r.Id, &r.Started, &r.ResumedFrom, &params, &r.InitSet)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+		err = json.Unmarshal([]byte(params), &r.Params)
+		if err != nil {
+			return nil, readFailure(err)
+		}
+		result = append(result, r)
+	}
+
+	return result, nil
 
 }
 
-/*
-UpdateID updates an id. If the system in the new id does not exist it is created.
-Changing design is not supported through this interface
-*/
-func UpdateId(oid addie.Id, id addie.Id, owner string) (int, error) {
+// Systems --------------------------------------------------------------------
 
-	key, err := ReadIdKey(oid, owner)
+func CreateSystem(name, design, owner string) (int, error) {
+
+	design_key, err := ReadDesignKey(design, owner)
 	if err != nil {
 		return -1, readFailure(err)
 	}
 
-	if oid == id {
-		return key, nil
+	q := fmt.Sprintf(
+		"INSERT INTO systems (design_id, name) VALUES (%d, '%s') RETURNING id",
+		design_key, name)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return -1, insertFailure(err)
 	}
-	if oid.Design != id.Design {
-		return key,
-			fmt.Errorf("[UpdateId] changing design though this interface not supported")
+	if !rows.Next() {
+		return -1, emptyReadbackFailure()
+	}
+	var id_key int
+	err = rows.Scan(&id_key)
+	if err != nil {
+		return -1, scanFailure(err)
 	}
 
-	if oid.Sys != id.Sys {
-		sys_key, err := ReadSysKey(id.Sys, id.Design, owner)
-		if err != nil {
-			sys_key, err = CreateSystem(id.Sys, id.Design, owner)
-			if err != nil {
-				return key, createFailure(err)
-			}
-		}
-		q := fmt.Sprintf(
-			"UPDATE ids SET sys_id = %d WHERE id = %d", sys_key, key)
+	return id_key, nil
 
-		err = runC(q)
+}
+
+func ReadSysKey(name, design, owner string) (int, error) {
+
+	design_key, err := ReadDesignKey(design, owner)
+	if err != nil {
+		return -1, readFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT id FROM systems WHERE name = '%s' AND design_id = %d",
+		name, design_key)
+
+	sys_key, err := getKey(q)
+	if err != nil {
+		return -1, selectFailure(err)
+	}
+
+	return sys_key, nil
+
+}
+
+func ReadSystemComputers(key int) ([]addie.Computer, error) {
+
+	var result []addie.Computer
+
+	q := fmt.Sprintf(
+		"SELECT computers.id FROM computers "+
+			"INNER JOIN ids on computers.id = ids.id "+
+			"WHERE ids.sys_id = %d", key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+
+	for rows.Next() {
+		var comp_key int
+		err := rows.Scan(&comp_key)
 		if err != nil {
-			return key, updateFailure(err)
+			return nil, scanFailure(err)
 		}
 
-		//err := SysRecycle() do this in background?
-		//if err != nil {
-		//	log.Println(err)
-		//	return key, fmt.Errorf("[UpdateId] an error occured during recycling")
-		//}
-	}
-	if oid.Name != id.Name {
-		q := fmt.Sprintf(
-			"UPDATE ids SET name = '%s' WHERE id = %d", id.Name, key)
-		err = runC(q)
+		comp, err := ReadComputerByKey(comp_key)
 		if err != nil {
-			return key, updateFailure(err)
+			return nil, readFailure(err)
 		}
+		result = append(result, *comp)
 	}
 
-	return key, nil
+	return result, nil
 }
 
-// Interfaces ------------------------------------------------------------------------
+/*
+ReadSystems reads the systems of a design along with the system each one is
+nested under
+*/
+func ReadSystems(design, owner string) ([]addie.System, error) {
 
-func ReadInterfaceKey(host_id int, ifname string) (int, error) {
+	design_key, err := ReadDesignKey(design, owner)
+	if err != nil {
+		return nil, readFailure(err)
+	}
 
 	q := fmt.Sprintf(
-		"SELECT id FROM interfaces WHERE host_id = %d AND name = '%s'",
-		host_id, ifname)
+		"SELECT s.name, p.name FROM systems AS s "+
+			"LEFT JOIN systems AS p ON s.parent_id = p.id "+
+			"WHERE s.design_id = %d ORDER BY s.name", design_key)
 
-	key, err := getKey(q)
+	rows, err := runQ(q)
+	defer safeClose(rows)
 	if err != nil {
-		log.Printf("hid=%d, if=%s", host_id, ifname)
-		return -1, selectFailure(err)
+		return nil, selectFailure(err)
 	}
 
-	return key, nil
+	var result []addie.System
+	for rows.Next() {
+		var s addie.System
+		var parent sql.NullString
+		err = rows.Scan(&s.Name, &parent)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+		s.Parent = parent.String
+		if !parent.Valid && s.Name != addie.RootSystem {
+			s.Parent = addie.RootSystem
+		}
+		result = append(result, s)
+	}
+
+	return result, nil
 
 }
 
-func CreateInterface(host_id int, ifx addie.Interface) error {
+/*
+NestSystem nests the system name under the system parent
+*/
+func NestSystem(name, parent, design, owner string) error {
 
-	pkt_id, err := CreatePacketConductor(ifx.PacketConductor)
+	sys_key, err := ReadSysKey(name, design, owner)
 	if err != nil {
-		return createFailure(err)
+		return readFailure(err)
+	}
+	parent_key, err := ReadSysKey(parent, design, owner)
+	if err != nil {
+		return readFailure(err)
+	}
+
+	q := fmt.Sprintf("UPDATE systems SET parent_id = %d WHERE id = %d",
+		parent_key, sys_key)
+
+	err = runC(q)
+	if err != nil {
+		return updateFailure(err)
+	}
+
+	return nil
+
+}
+
+/*
+RenameSystem renames a system, the ids of its elements refer to the system by
+key so they follow along
+*/
+func RenameSystem(old, name, design, owner string) error {
+
+	sys_key, err := ReadSysKey(old, design, owner)
+	if err != nil {
+		return readFailure(err)
+	}
+
+	q := fmt.Sprintf("UPDATE systems SET name = '%s' WHERE id = %d", name, sys_key)
+
+	err = runC(q)
+	if err != nil {
+		return updateFailure(err)
+	}
+
+	return nil
+
+}
+
+/*
+DeleteSystem deletes a system. Its elements and the systems nested in it are
+handed to its parent first, so deleting a system never deletes elements.
+*/
+func DeleteSystem(name, design, owner string) error {
+
+	sys_key, err := ReadSysKey(name, design, owner)
+	if err != nil {
+		return readFailure(err)
 	}
 
+	//systems created before nesting existed have no parent, they sit at the root
 	q := fmt.Sprintf(
-		"INSERT INTO interfaces (name, host_id, packet_conductor_id) "+
-			"VALUES ('%s', %d, %d)",
-		ifx.Name, host_id, pkt_id)
+		"SELECT COALESCE(s.parent_id, r.id) FROM systems AS s "+
+			"INNER JOIN systems AS r ON r.design_id = s.design_id "+
+			"WHERE s.id = %d AND r.name = '%s'", sys_key, addie.RootSystem)
+	parent_key, err := getKey(q)
+	if err != nil {
+		return selectFailure(err)
+	}
 
+	q = fmt.Sprintf("UPDATE ids SET sys_id = %d WHERE sys_id = %d",
+		parent_key, sys_key)
 	err = runC(q)
 	if err != nil {
-		return insertFailure(err)
+		return updateFailure(err)
+	}
+
+	q = fmt.Sprintf("UPDATE systems SET parent_id = %d WHERE parent_id = %d",
+		parent_key, sys_key)
+	err = runC(q)
+	if err != nil {
+		return updateFailure(err)
+	}
+
+	q = fmt.Sprintf("DELETE FROM systems WHERE id = %d", sys_key)
+	err = runC(q)
+	if err != nil {
+		return deleteFailure(err)
 	}
 
 	return nil
+
 }
 
-func UpdateInterface(host_id int, old addie.Interface, ifx addie.Interface) error {
+func SysRecycle() error {
+
+	//TODO
+
+	return nil
+
+}
+
+// Ids ------------------------------------------------------------------------
+
+func ReadIdKey(id addie.Id, owner string) (int, error) {
+
+	sys_key, err := ReadSysKey(id.Sys, id.Design, owner)
+	if err != nil {
+		return -1, readFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT id FROM ids WHERE name = '%s' AND sys_id = '%d'",
+		id.Name, sys_key)
+
+	id_key, err := getKey(q)
+	if err != nil {
+		log.Println(id)
+		return -1, selectFailure(err)
+	}
+	return id_key, nil
+}
+
+func CreateId(id addie.Id, owner string) (int, error) {
+	sys_id, err := ReadSysKey(id.Sys, id.Design, owner)
+	if err != nil {
+		return -1, readFailure(err)
+	}
 
 	q := fmt.Sprintf(
-		"SELECT id, packet_conductor_id FROM interfaces "+
-			"WHERE host_id = %d AND name = '%s'",
-		host_id, old.Name)
+		"INSERT INTO ids (name, sys_id) VALUES ('%s', %d) RETURNING id",
+		id.Name, sys_id)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return -1, insertFailure(err)
+	}
+
+	if !rows.Next() {
+		return -1, emptyReadbackFailure()
+	}
+	var id_key int
+	err = rows.Scan(&id_key)
+	if err != nil {
+		return -1, scanFailure(err)
+	}
+
+	return id_key, nil
+}
+
+func ReadId(id int) (*addie.Id, error) {
+
+	_id := new(addie.Id)
+
+	q := fmt.Sprintf(
+		"select ids.name, systems.name, designs.name "+
+			"from ids "+
+			"inner join systems on ids.sys_id = systems.id "+
+			"inner join designs on systems.design_id = designs.id "+
+			"where ids.id = %d", id)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+	if !rows.Next() {
+		return nil, emptyReadFailure()
+	}
+	err = rows.Scan(&_id.Name, &_id.Sys, &_id.Design)
+	if err != nil {
+		return nil, scanFailure(err)
+	}
+
+	return _id, nil
+
+}
+
+func DeleteId(id addie.Id, owner string) error {
+
+	q := fmt.Sprintf(
+		"DELETE FROM ids "+
+			"USING systems, designs, users "+
+			"WHERE ids.sys_id = systems.id "+
+			"AND systems.design_id = designs.id "+
+			"AND designs.owner = users.id "+
+			"AND ids.name = '%s' "+
+			"AND systems.name = '%s' "+
+			"AND designs.name = '%s' "+
+			"AND users.name = '%s'", id.Name, id.Sys, id.Design, owner)
+
+	err := runC(q)
+	if err != nil {
+		return deleteFailure(err)
+	}
+
+	return nil
+
+}
+
+/*
+UpdateID updates an id. If the system in the new id does not exist it is created.
+Changing design is not supported through this interface
+*/
+func UpdateId(oid addie.Id, id addie.Id, owner string) (int, error) {
+
+	key, err := ReadIdKey(oid, owner)
+	if err != nil {
+		return -1, readFailure(err)
+	}
+
+	if oid == id {
+		return key, nil
+	}
+	if oid.Design != id.Design {
+		return key,
+			fmt.Errorf("[UpdateId] changing design though this interface not supported")
+	}
+
+	if oid.Sys != id.Sys {
+		sys_key, err := ReadSysKey(id.Sys, id.Design, owner)
+		if err != nil {
+			sys_key, err = CreateSystem(id.Sys, id.Design, owner)
+			if err != nil {
+				return key, createFailure(err)
+			}
+		}
+		q := fmt.Sprintf(
+			"UPDATE ids SET sys_id = %d WHERE id = %d", sys_key, key)
+
+		err = runC(q)
+		if err != nil {
+			return key, updateFailure(err)
+		}
+
+		//err := SysRecycle() do this in background?
+		//if err != nil {
+		//	log.Println(err)
+		//	return key, fmt.Errorf("[UpdateId] an error occured during recycling")
+		//}
+	}
+	if oid.Name != id.Name {
+		q := fmt.Sprintf(
+			"UPDATE ids SET name = '%s' WHERE id = %d", id.Name, key)
+		err = runC(q)
+		if err != nil {
+			return key, updateFailure(err)
+		}
+	}
+
+	return key, nil
+}
+
+// Attributes ------------------------------------------------------------------
+
+func ReadAttributes(id_key int) (addie.Attributes, error) {
+
+	q := fmt.Sprintf(
+		"SELECT name, value FROM attributes WHERE id_id = %d", id_key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+
+	var attrs addie.Attributes
+	for rows.Next() {
+		var name, value string
+		err = rows.Scan(&name, &value)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+		if attrs == nil {
+			attrs = make(addie.Attributes)
+		}
+		attrs[name] = value
+	}
+
+	return attrs, nil
+
+}
+
+/*
+UpdateAttributes replaces the attributes of the element with the id key
+id_key, the attributes of every element type live in the one table
+*/
+func UpdateAttributes(id_key int, attrs addie.Attributes) error {
+
+	q := fmt.Sprintf("DELETE FROM attributes WHERE id_id = %d", id_key)
+	err := runC(q)
+	if err != nil {
+		return deleteFailure(err)
+	}
+
+	for _, k := range attrs.Keys() {
+		q = fmt.Sprintf(
+			"INSERT INTO attributes (id_id, name, value) VALUES (%d, '%s', '%s')",
+			id_key, pgMathStr(k), pgMathStr(attrs[k]))
+		err = runC(q)
+		if err != nil {
+			return insertFailure(err)
+		}
+	}
+
+	return nil
+
+}
+
+// Interfaces ------------------------------------------------------------------------
+
+func ReadInterfaceKey(host_id int, ifname string) (int, error) {
+
+	q := fmt.Sprintf(
+		"SELECT id FROM interfaces WHERE host_id = %d AND name = '%s'",
+		host_id, ifname)
+
+	key, err := getKey(q)
+	if err != nil {
+		log.Printf("hid=%d, if=%s", host_id, ifname)
+		return -1, selectFailure(err)
+	}
+
+	return key, nil
+
+}
+
+func CreateInterface(host_id int, ifx addie.Interface) error {
+
+	pkt_id, err := CreatePacketConductor(ifx.PacketConductor)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	q := fmt.Sprintf(
+		"INSERT INTO interfaces (name, host_id, packet_conductor_id) "+
+			"VALUES ('%s', %d, %d)",
+		ifx.Name, host_id, pkt_id)
+
+	err = runC(q)
+	if err != nil {
+		return insertFailure(err)
+	}
+
+	return nil
+}
+
+func UpdateInterface(host_id int, old addie.Interface, ifx addie.Interface) error {
+
+	q := fmt.Sprintf(
+		"SELECT id, packet_conductor_id FROM interfaces "+
+			"WHERE host_id = %d AND name = '%s'",
+		host_id, old.Name)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return selectFailure(err)
+	}
+	var key, pkt_key int
+	err = rows.Scan(&key, &pkt_key)
+	if err != nil {
+		return scanFailure(err)
+	}
+
+	_, err = UpdatePacketConductor(pkt_key, ifx.PacketConductor)
+	if err != nil {
+		return updateFailure(err)
+	}
+
+	q = fmt.Sprintf(
+		"UPDATE interfaces SET name ='%s' WHERE id = %d", ifx.Name, key)
+	err = runC(q)
+	if err != nil {
+		return updateFailure(err)
+	}
+
+	return nil
+}
+
+func ReadInterface(id int) (*addie.Interface, error) {
+
+	q := fmt.Sprintf(
+		"SELECT name, host_id, packet_conductor_id FROM interfaces WHERE id = %d", id)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+	if !rows.Next() {
+		return nil, emptyReadFailure()
+	}
+	var name string
+	var host_key, pkt_key int
+	err = rows.Scan(&name, &host_key, &pkt_key)
+	if err != nil {
+		return nil, scanFailure(err)
+	}
+
+	pkt, err := ReadPacketConductor(pkt_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	ifx := addie.Interface{}
+	ifx.Name = name
+	ifx.PacketConductor = *pkt
+
+	return &ifx, nil
+
+}
+
+func ReadHostInterfaces(host_id int) (*map[string]addie.Interface, error) {
+
+	q := fmt.Sprintf(
+		"SELECT name, packet_conductor_id FROM interfaces WHERE host_id = %d", host_id)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+	result := make(map[string]addie.Interface)
+	for rows.Next() {
+		var name string
+		var pkt_key int
+		err = rows.Scan(&name, &pkt_key)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+
+		pkt, err := ReadPacketConductor(pkt_key)
+		if err != nil {
+			return nil, readFailure(err)
+		}
+
+		ifx := addie.Interface{}
+		ifx.Name = name
+		ifx.PacketConductor = *pkt
+
+		result[name] = ifx
+
+	}
+
+	return &result, nil
+}
+
+//TODO leaking packet conductors in DB
+func DeleteInterface(ir addie.NetIfRef, user string) error {
+
+	q := fmt.Sprintf(
+		"DELETE FROM interfaces "+
+			"USING ids, systems, designs, users "+
+			"WHERE interfaces.host_id = ids.id "+
+			"AND ids.name = '%s' "+
+			"AND ids.sys_id = systems.id "+
+			"AND systems.name = '%s' "+
+			"AND systems.design_id = designs.id "+
+			"AND designs.name = '%s' "+
+			"AND designs.owner = users.id "+
+			"AND users.name = '%s' "+
+			"AND interfaces.name = '%s'", ir.Id.Name, ir.Id.Sys, ir.Id.Design, ir.IfName)
+
+	err := runC(q)
+	if err != nil {
+		return deleteFailure(err)
+	}
+
+	return nil
+
+}
+
+// Network Hosts ---------------------------------------------------------------------
+
+func CreateNetworkHost(h addie.NetHost, owner string) (int, error) {
+
+	//id insert
+	id_key, err := CreateId(h.Id, owner)
+	if err != nil {
+		return -1, createFailure(err)
+	}
+
+	//network host insert
+	err = CreateNetworkHostByKey(id_key)
+	if err != nil {
+		return -1, createFailure(err)
+	}
+
+	err = UpdateAttributes(id_key, h.Attributes)
+	if err != nil {
+		return -1, createFailure(err)
+	}
+
+	return id_key, nil
+}
+
+func CreateNetworkHostByKey(id_key int) error {
+
+	q := fmt.Sprintf(
+		"INSERT INTO network_hosts (id) VALUES (%d)", id_key)
+
+	err := runC(q)
+	if err != nil {
+		return insertFailure(err)
+	}
+
+	return nil
+
+}
+
+func UpdateNetworkHost(oid addie.Id, old addie.NetHost, h addie.NetHost, owner string) (
+	int, error) {
+
+	key, err := UpdateId(oid, h.Id, owner)
+	if err != nil {
+		return -1, updateFailure(err)
+	}
+
+	err = UpdateAttributes(key, h.Attributes)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	for k, v := range h.Interfaces {
+		_v, ok := old.Interfaces[k]
+		if ok && _v == v {
+			//log.Printf("ifx %v == %v", _v, v)
+			continue
+		} else if ok && _v != v {
+			//log.Printf("ifx % %v --> %v", _v, v)
+			UpdateInterface(key, _v, v)
+		} else {
+			//log.Printf("ifx + %v", v)
+			CreateInterface(key, v)
+		}
+	}
+
+	return key, nil
+
+}
+
+// Positions -------------------------------------------------------------------------
+
+func CreatePosition(p addie.Position) (int, error) {
+
+	q := fmt.Sprintf(
+		"INSERT INTO positions (x, y, z) VALUES (%f, %f, %f) RETURNING id",
+		p.X, p.Y, p.Z)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return -1, insertFailure(err)
+	}
+	if !rows.Next() {
+		return -1, emptyReadbackFailure()
+	}
+
+	var pos_key int
+	err = rows.Scan(&pos_key)
+	if err != nil {
+		return -1, scanFailure(err)
+	}
+
+	return pos_key, nil
+}
+
+func ReadPosition(id int) (*addie.Position, error) {
+
+	q := fmt.Sprintf(
+		"SELECT x, y, z FROM positions WHERE id = %d", id)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+
+	if !rows.Next() {
+		return nil, emptyReadFailure()
+	}
+
+	var x, y, z float32
+	err = rows.Scan(&x, &y, &z)
+	if err != nil {
+		return nil, scanFailure(err)
+	}
+
+	return &addie.Position{x, y, z}, nil
+
+}
+
+func UpdatePosition(key int, p addie.Position) (int, error) {
+
+	q := fmt.Sprintf("UPDATE positions SET x = %f, y = %f, z = %f WHERE id = %d",
+		p.X, p.Y, p.Z, key)
+
+	err := runC(q)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	return key, nil
+}
+
+// Packet Conductors -----------------------------------------------------------------
+
+func CreatePacketConductor(p addie.PacketConductor) (int, error) {
+
+	q := fmt.Sprintf(
+		"INSERT INTO packet_conductors (capacity, latency, capacity_expr, latency_expr) "+
+			"VALUES (%d, %d, '%s', '%s') RETURNING id",
+		p.Capacity, p.Latency, pgMathStr(p.CapacityExpr), pgMathStr(p.LatencyExpr))
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return -1, insertFailure(err)
+	}
+	if !rows.Next() {
+		return -1, emptyReadbackFailure()
+	}
+
+	var pkt_key int
+	err = rows.Scan(&pkt_key)
+	if err != nil {
+		return -1, scanFailure(err)
+	}
+
+	return pkt_key, nil
+
+}
+
+func ReadPacketConductor(id int) (*addie.PacketConductor, error) {
+
+	q := fmt.Sprintf(
+		"SELECT capacity, latency, capacity_expr, latency_expr "+
+			"FROM packet_conductors WHERE id = %d", id)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+	if !rows.Next() {
+		return nil, emptyReadFailure()
+	}
+
+	var p addie.PacketConductor
+	var capacityExpr, latencyExpr sql.NullString
+	err = rows.Scan(&p.Capacity, &p.Latency, &capacityExpr, &latencyExpr)
+	if err != nil {
+		return nil, scanFailure(err)
+	}
+	p.CapacityExpr = capacityExpr.String
+	p.LatencyExpr = latencyExpr.String
+
+	return &p, nil
+
+}
+
+func UpdatePacketConductor(key int, p addie.PacketConductor) (int, error) {
+
+	q := fmt.Sprintf(
+		"UPDATE packet_conductors SET capacity = %d, latency = %d, "+
+			"capacity_expr = '%s', latency_expr = '%s' WHERE id = %d",
+		p.Capacity, p.Latency, pgMathStr(p.CapacityExpr), pgMathStr(p.LatencyExpr), key)
+
+	err := runC(q)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	return key, nil
+
+}
+
+// Computers -------------------------------------------------------------------------
+
+func CreateComputer(c addie.Computer, owner string) error {
+
+	//nethost insert
+	host_key, err := CreateNetworkHost(c.NetHost, owner)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	//position insert
+	pos_key, err := CreatePosition(c.Position)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	//interfaces insert
+	for _, ifx := range c.Interfaces {
+		err = CreateInterface(host_key, ifx)
+		if err != nil {
+			return createFailure(err)
+		}
+	}
+
+	//computer insert
+	q := fmt.Sprintf("INSERT INTO computers (id, os, start_script, position_id) "+
+		"values (%d, '%s', '%s', %d)", host_key, c.OS, c.Start_script, pos_key)
+
+	err = runC(q)
+	if err != nil {
+		return insertFailure(err)
+	}
+
+	return nil
+}
+
+func UpdateComputer(oid addie.Id, old addie.Computer, c addie.Computer, owner string) (
+	int, error) {
+
+	key, err := UpdateNetworkHost(oid, old.NetHost, c.NetHost, owner)
+	if err != nil {
+		return -1, updateFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT position_id FROM computers WHERE id = %d", key)
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return key, selectFailure(err)
+	}
+	if !rows.Next() {
+		return key, emptyReadFailure()
+	}
+	var pos_key int
+	err = rows.Scan(&pos_key)
+	if err != nil {
+		return key, scanFailure(err)
+	}
+
+	_, err = UpdatePosition(pos_key, c.Position)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	q = fmt.Sprintf(
+		"UPDATE computers SET os = '%s', start_script = '%s' WHERE id = %d",
+		c.OS, c.Start_script, key)
+
+	err = runC(q)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	return key, nil
+
+}
+
+func ReadComputerByKey(id_key int) (*addie.Computer, error) {
+
+	id, err := ReadId(id_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	attrs, err := ReadAttributes(id_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	q := fmt.Sprintf(
+		"SELECT os, start_script, position_id FROM computers WHERE id = %d", id_key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+
+	if !rows.Next() {
+		return nil, emptyReadFailure()
+	}
+	var os, start_script string
+	var pos_key int
+	err = rows.Scan(&os, &start_script, &pos_key)
+	if err != nil {
+		return nil, scanFailure(err)
+	}
+	rows.Close()
+
+	pos, err := ReadPosition(pos_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	ifs, err := ReadHostInterfaces(id_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	c := addie.Computer{}
+	c.Id = *id
+	c.Interfaces = *ifs
+	c.OS = os
+	c.Start_script = start_script
+	c.Position = *pos
+	c.Attributes = attrs
+
+	return &c, nil
+
+}
+
+func ReadComputer(id addie.Id, owner string) (*addie.Computer, error) {
+
+	id_key, err := ReadIdKey(id, owner)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	return ReadComputerByKey(id_key)
+
+}
+
+// Routers ---------------------------------------------------------------------------
+
+func CreateRouter(r addie.Router, owner string) error {
+
+	//create nethost
+	host_key, err := CreateNetworkHost(r.NetHost, owner)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	//position insert
+	pos_key, err := CreatePosition(r.Position)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	//packet conductor insert
+	pkt_key, err := CreatePacketConductor(r.PacketConductor)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	//router insert
+	q := fmt.Sprintf(
+		"INSERT INTO routers (id, packet_conductor_id, position_id) "+
+			"values (%d, %d, %d)",
+		host_key, pkt_key, pos_key)
+
+	err = runC(q)
+	if err != nil {
+		return insertFailure(err)
+	}
+
+	return nil
+}
+
+func ReadRouterByKey(key int) (*addie.Router, error) {
+
+	id, err := ReadId(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	attrs, err := ReadAttributes(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	q := fmt.Sprintf(
+		"SELECT packet_conductor_id, position_id FROM routers WHERE id = %d", key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+	if !rows.Next() {
+		return nil, emptyReadFailure()
+	}
+
+	var pkt_key, pos_key int
+	err = rows.Scan(&pkt_key, &pos_key)
+	if err != nil {
+		return nil, scanFailure(err)
+	}
+	rows.Close()
+
+	pos, err := ReadPosition(pos_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	pkt, err := ReadPacketConductor(pkt_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	ifs, err := ReadHostInterfaces(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	rtr := addie.Router{}
+	rtr.Id = *id
+	rtr.Interfaces = *ifs
+	rtr.PacketConductor = *pkt
+	rtr.Position = *pos
+	rtr.Attributes = attrs
+
+	return &rtr, nil
+
+}
+
+func ReadRouter(id addie.Id, owner string) (*addie.Router, error) {
+
+	key, err := ReadIdKey(id, owner)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	return ReadRouterByKey(key)
+}
+
+func UpdateRouter(oid addie.Id, old addie.Router, r addie.Router, owner string) (
+	int, error) {
+
+	key, err := UpdateNetworkHost(oid, old.NetHost, r.NetHost, owner)
+	if err != nil {
+		return -1, updateFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT position_id, packet_conductor_id FROM routers "+
+		"WHERE id = %d", key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return key, selectFailure(err)
+	}
+	if !rows.Next() {
+		return key, emptyReadFailure()
+	}
+	var pos_key, pkt_key int
+	err = rows.Scan(&pos_key, &pkt_key)
+	if err != nil {
+		return key, scanFailure(err)
+	}
+
+	_, err = UpdatePosition(pos_key, r.Position)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	_, err = UpdatePacketConductor(pkt_key, r.PacketConductor)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	return key, nil
+}
+
+func ReadSystemRouters(key int) ([]addie.Router, error) {
+
+	var result []addie.Router
+
+	q := fmt.Sprintf(
+		"SELECT routers.id FROM routers "+
+			"INNER JOIN ids on routers.id = ids.id "+
+			"WHERE ids.sys_id = %d", key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+
+	for rows.Next() {
+		var rtr_key int
+		err := rows.Scan(&rtr_key)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+
+		rtr, err := ReadRouterByKey(rtr_key)
+		if err != nil {
+			return nil, readFailure(err)
+		}
+		result = append(result, *rtr)
+	}
+
+	return result, nil
+
+}
+
+// Switches --------------------------------------------------------------------------
+
+func CreateSwitch(s addie.Switch, owner string) error {
+
+	//id insert
+	id_key, err := CreateNetworkHost(s.NetHost, owner)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	//packet conductor insert
+	pkt_key, err := CreatePacketConductor(s.PacketConductor)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	//position insert
+	pos_key, err := CreatePosition(s.Position)
+	if err != nil {
+		return createFailure(err)
+	}
+
+	//switch insert
+	q := fmt.Sprintf(
+		"INSERT INTO switches (id, packet_conductor_id, position_id)  "+
+			"values (%d, %d, %d)",
+		id_key, pkt_key, pos_key)
+
+	err = runC(q)
+	if err != nil {
+		return insertFailure(err)
+	}
+
+	return nil
+}
+
+func ReadSwitchByKey(key int) (*addie.Switch, error) {
+
+	id, err := ReadId(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	attrs, err := ReadAttributes(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	q := fmt.Sprintf(
+		"SELECT packet_conductor_id, position_id FROM switches WHERE id = %d", key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return selectFailure(err)
+		return nil, selectFailure(err)
 	}
-	var key, pkt_key int
-	err = rows.Scan(&key, &pkt_key)
+	if !rows.Next() {
+		return nil, emptyReadFailure()
+	}
+
+	var pkt_key, pos_key int
+	err = rows.Scan(&pkt_key, &pos_key)
 	if err != nil {
-		return scanFailure(err)
+		return nil, scanFailure(err)
 	}
+	rows.Close()
 
-	_, err = UpdatePacketConductor(pkt_key, ifx.PacketConductor)
+	pos, err := ReadPosition(pos_key)
 	if err != nil {
-		return updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	q = fmt.Sprintf(
-		"UPDATE interfaces SET name ='%s' WHERE id = %d", ifx.Name, key)
-	err = runC(q)
+	pkt, err := ReadPacketConductor(pkt_key)
 	if err != nil {
-		return updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	return nil
+	ifs, err := ReadHostInterfaces(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	sw := addie.Switch{}
+	sw.Id = *id
+	sw.Interfaces = *ifs
+	sw.PacketConductor = *pkt
+	sw.Position = *pos
+	sw.Attributes = attrs
+
+	return &sw, nil
+
 }
 
-func ReadInterface(id int) (*addie.Interface, error) {
+func ReadSwitch(id addie.Id, owner string) (*addie.Switch, error) {
 
-	q := fmt.Sprintf(
-		"SELECT name, host_id, packet_conductor_id FROM interfaces WHERE id = %d", id)
+	key, err := ReadIdKey(id, owner)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	return ReadSwitchByKey(key)
+}
+
+func UpdateSwitch(oid addie.Id, old addie.Switch, s addie.Switch, owner string) (
+	int, error) {
+
+	key, err := UpdateNetworkHost(oid, old.NetHost, s.NetHost, owner)
+	if err != nil {
+		return -1, updateFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT packet_conductor_id, position_id FROM switches "+
+		"WHERE id = %d", key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return nil, selectFailure(err)
+		return key, selectFailure(err)
 	}
 	if !rows.Next() {
-		return nil, emptyReadFailure()
+		return key, emptyReadFailure()
 	}
-	var name string
-	var host_key, pkt_key int
-	err = rows.Scan(&name, &host_key, &pkt_key)
+	var pos_key, pkt_key int
+	err = rows.Scan(&pkt_key, &pos_key)
 	if err != nil {
-		return nil, scanFailure(err)
+		return key, scanFailure(err)
 	}
 
-	pkt, err := ReadPacketConductor(pkt_key)
+	_, err = UpdatePosition(pos_key, s.Position)
 	if err != nil {
-		return nil, readFailure(err)
+		return key, updateFailure(err)
 	}
 
-	ifx := addie.Interface{}
-	ifx.Name = name
-	ifx.PacketConductor = *pkt
+	_, err = UpdatePacketConductor(pkt_key, s.PacketConductor)
+	if err != nil {
+		return key, updateFailure(err)
+	}
 
-	return &ifx, nil
+	return key, nil
 
 }
 
-func ReadHostInterfaces(host_id int) (*map[string]addie.Interface, error) {
+func ReadSystemSwitches(key int) ([]addie.Switch, error) {
+
+	var result []addie.Switch
 
 	q := fmt.Sprintf(
-		"SELECT name, packet_conductor_id FROM interfaces WHERE host_id = %d", host_id)
+		"SELECT switches.id FROM switches "+
+			"INNER JOIN ids on switches.id = ids.id "+
+			"WHERE ids.sys_id = %d", key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
+
 	if err != nil {
 		return nil, selectFailure(err)
 	}
-	result := make(map[string]addie.Interface)
+
 	for rows.Next() {
-		var name string
-		var pkt_key int
-		err = rows.Scan(&name, &pkt_key)
+		var sw_key int
+		err := rows.Scan(&sw_key)
 		if err != nil {
 			return nil, scanFailure(err)
 		}
 
-		pkt, err := ReadPacketConductor(pkt_key)
+		sw, err := ReadSwitchByKey(sw_key)
 		if err != nil {
 			return nil, readFailure(err)
 		}
-
-		ifx := addie.Interface{}
-		ifx.Name = name
-		ifx.PacketConductor = *pkt
-
-		result[name] = ifx
-
+		result = append(result, *sw)
 	}
 
-	return &result, nil
+	return result, nil
+
 }
 
-//TODO leaking packet conductors in DB
-func DeleteInterface(ir addie.NetIfRef, user string) error {
+// Links -----------------------------------------------------------------------------
 
-	q := fmt.Sprintf(
-		"DELETE FROM interfaces "+
-			"USING ids, systems, designs, users "+
-			"WHERE interfaces.host_id = ids.id "+
-			"AND ids.name = '%s' "+
-			"AND ids.sys_id = systems.id "+
-			"AND systems.name = '%s' "+
-			"AND systems.design_id = designs.id "+
-			"AND designs.name = '%s' "+
-			"AND designs.owner = users.id "+
-			"AND users.name = '%s' "+
-			"AND interfaces.name = '%s'", ir.Id.Name, ir.Id.Sys, ir.Id.Design, ir.IfName)
+func CreateLink(l addie.Link, owner string) error {
 
-	err := runC(q)
+	//id insert
+	id_key, err := CreateId(l.Id, owner)
 	if err != nil {
-		return deleteFailure(err)
+		return createFailure(err)
 	}
 
-	return nil
-
-}
-
-// Network Hosts ---------------------------------------------------------------------
-
-func CreateNetworkHost(h addie.NetHost, owner string) (int, error) {
+	err = UpdateAttributes(id_key, l.Attributes)
+	if err != nil {
+		return createFailure(err)
+	}
 
-	//id insert
-	id_key, err := CreateId(h.Id, owner)
+	//packet conductor insert
+	pkt_key, err := CreatePacketConductor(l.PacketConductor)
 	if err != nil {
-		return -1, createFailure(err)
+		return createFailure(err)
 	}
 
-	//network host insert
-	err = CreateNetworkHostByKey(id_key)
+	//endpoint0
+	ep0_key, err := ReadIdKey(l.Endpoints[0].Id, owner)
 	if err != nil {
-		return -1, createFailure(err)
+		return readFailure(err)
+	}
+	if0_key, err := ReadInterfaceKey(ep0_key, l.Endpoints[0].IfName)
+	if err != nil {
+		return readFailure(err)
 	}
 
-	return id_key, nil
-}
+	//endpoint1
+	ep1_key, err := ReadIdKey(l.Endpoints[1].Id, owner)
+	if err != nil {
+		return readFailure(err)
+	}
+	if1_key, err := ReadInterfaceKey(ep1_key, l.Endpoints[1].IfName)
+	if err != nil {
+		return readFailure(err)
+	}
 
-func CreateNetworkHostByKey(id_key int) error {
+	path, err := json.Marshal(l.Path)
+	if err != nil {
+		return createFailure(err)
+	}
 
 	q := fmt.Sprintf(
-		"INSERT INTO network_hosts (id) VALUES (%d)", id_key)
+		"INSERT INTO links "+
+			"(id, packet_conductor_id, "+
+			"endpoint_a_id, interface_a_id, "+
+			"endpoint_b_id, interface_b_id, path) "+
+			"VALUES (%d, %d, %d, %d, %d, %d, '%s')",
+		id_key, pkt_key, ep0_key, if0_key, ep1_key, if1_key, string(path))
 
-	err := runC(q)
+	err = runC(q)
 	if err != nil {
 		return insertFailure(err)
 	}
@@ -949,836 +2212,909 @@ func CreateNetworkHostByKey(id_key int) error {
 
 }
 
-func UpdateNetworkHost(oid addie.Id, old addie.NetHost, h addie.NetHost, owner string) (
-	int, error) {
+func ReadLinkByKey(key int) (*addie.Link, error) {
 
-	key, err := UpdateId(oid, h.Id, owner)
+	id, err := ReadId(key)
 	if err != nil {
-		return -1, updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	for k, v := range h.Interfaces {
-		_v, ok := old.Interfaces[k]
-		if ok && _v == v {
-			//log.Printf("ifx %v == %v", _v, v)
-			continue
-		} else if ok && _v != v {
-			//log.Printf("ifx % %v --> %v", _v, v)
-			UpdateInterface(key, _v, v)
-		} else {
-			//log.Printf("ifx + %v", v)
-			CreateInterface(key, v)
-		}
+	attrs, err := ReadAttributes(key)
+	if err != nil {
+		return nil, readFailure(err)
 	}
 
-	return key, nil
-
-}
-
-// Positions -------------------------------------------------------------------------
-
-func CreatePosition(p addie.Position) (int, error) {
-
 	q := fmt.Sprintf(
-		"INSERT INTO positions (x, y, z) VALUES (%f, %f, %f) RETURNING id",
-		p.X, p.Y, p.Z)
+		"SELECT packet_conductor_id, "+
+			"endpoint_a_id, interface_a_id, "+
+			"endpoint_b_id, interface_b_id, path "+
+			"FROM links WHERE id = %d",
+		key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return -1, insertFailure(err)
+		return nil, selectFailure(err)
 	}
 	if !rows.Next() {
-		return -1, emptyReadbackFailure()
+		return nil, emptyReadFailure()
 	}
 
-	var pos_key int
-	err = rows.Scan(&pos_key)
+	var pkt_key, ep0_key, if0_key, ep1_key, if1_key int
+	var path sql.NullString
+	err = rows.Scan(&pkt_key, &ep0_key, &if0_key, &ep1_key, &if1_key, &path)
 	if err != nil {
-		return -1, scanFailure(err)
+		return nil, scanFailure(err)
 	}
+	rows.Close()
 
-	return pos_key, nil
-}
+	pkt, err := ReadPacketConductor(pkt_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
 
-func ReadPosition(id int) (*addie.Position, error) {
+	ep0, err := ReadId(ep0_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
 
-	q := fmt.Sprintf(
-		"SELECT x, y, z FROM positions WHERE id = %d", id)
+	ep1, err := ReadId(ep1_key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
 
-	rows, err := runQ(q)
-	defer safeClose(rows)
+	if0, err := ReadInterface(if0_key)
 	if err != nil {
-		return nil, selectFailure(err)
+		return nil, readFailure(err)
 	}
 
-	if !rows.Next() {
-		return nil, emptyReadFailure()
+	if1, err := ReadInterface(if1_key)
+	if err != nil {
+		return nil, readFailure(err)
 	}
 
-	var x, y, z float32
-	err = rows.Scan(&x, &y, &z)
-	if err != nil {
-		return nil, scanFailure(err)
+	lnk := addie.Link{}
+	lnk.Id = *id
+	lnk.PacketConductor = *pkt
+	lnk.Endpoints[0] = addie.NetIfRef{*ep0, if0.Name}
+	lnk.Endpoints[1] = addie.NetIfRef{*ep1, if1.Name}
+
+	//links saved before paths were stored have none
+	if path.Valid {
+		err = json.Unmarshal([]byte(path.String), &lnk.Path)
+		if err != nil {
+			return nil, readFailure(err)
+		}
 	}
+	lnk.Attributes = attrs
 
-	return &addie.Position{x, y, z}, nil
+	return &lnk, nil
 
 }
 
-func UpdatePosition(key int, p addie.Position) (int, error) {
-
-	q := fmt.Sprintf("UPDATE positions SET x = %f, y = %f, z = %f WHERE id = %d",
-		p.X, p.Y, p.Z, key)
+func ReadLink(id addie.Id, owner string) (*addie.Link, error) {
 
-	err := runC(q)
+	key, err := ReadIdKey(id, owner)
 	if err != nil {
-		return key, updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	return key, nil
+	return ReadLinkByKey(key)
 }
 
-// Packet Conductors -----------------------------------------------------------------
+func UpdateLink(oid addie.Id, l addie.Link, owner string) (int, error) {
 
-func CreatePacketConductor(p addie.PacketConductor) (int, error) {
+	key, err := UpdateId(oid, l.Id, owner)
+	if err != nil {
+		return -1, updateFailure(err)
+	}
 
-	q := fmt.Sprintf(
-		"INSERT INTO packet_conductors (capacity, latency) VALUES (%d, %d) "+
-			"RETURNING id",
-		p.Capacity, p.Latency)
+	err = UpdateAttributes(key, l.Attributes)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT packet_conductor_id FROM links WHERE id = %d", key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return -1, insertFailure(err)
+		return key, selectFailure(err)
 	}
 	if !rows.Next() {
-		return -1, emptyReadbackFailure()
+		return key, emptyReadFailure()
 	}
 
 	var pkt_key int
 	err = rows.Scan(&pkt_key)
 	if err != nil {
-		return -1, scanFailure(err)
+		return key, scanFailure(err)
 	}
 
-	return pkt_key, nil
-
-}
-
-func ReadPacketConductor(id int) (*addie.PacketConductor, error) {
+	//packet conductor
+	_, err = UpdatePacketConductor(pkt_key, l.PacketConductor)
+	if err != nil {
+		return key, updateFailure(err)
+	}
 
-	q := fmt.Sprintf(
-		"SELECT capacity, latency FROM packet_conductors WHERE id = %d", id)
+	//endpoints
+	e0, err := ReadIdKey(l.Endpoints[0].Id, owner)
+	if err != nil {
+		return key, readFailure(err)
+	}
+	e1, err := ReadIdKey(l.Endpoints[1].Id, owner)
+	if err != nil {
+		return key, readFailure(err)
+	}
 
-	rows, err := runQ(q)
-	defer safeClose(rows)
+	//interfaces
+	i0, err := ReadInterfaceKey(e0, l.Endpoints[0].IfName)
 	if err != nil {
-		return nil, selectFailure(err)
+		return key, readFailure(err)
 	}
-	if !rows.Next() {
-		return nil, emptyReadFailure()
+	i1, err := ReadInterfaceKey(e1, l.Endpoints[1].IfName)
+	if err != nil {
+		return key, readFailure(err)
 	}
 
-	var capacity, latency int
-	err = rows.Scan(&capacity, &latency)
+	path, err := json.Marshal(l.Path)
 	if err != nil {
-		return nil, scanFailure(err)
+		return key, updateFailure(err)
 	}
 
-	return &addie.PacketConductor{capacity, latency}, nil
+	q = fmt.Sprintf(
+		"UPDATE links SET "+
+			"endpoint_a_id = %d, interface_a_id = %d, "+
+			"endpoint_b_id = %d, interface_b_id = %d, path = '%s' "+
+			"WHERE id = %d", e0, i0, e1, i1, string(path), key)
+	err = runC(q)
+	if err != nil {
+		return key, updateFailure(err)
+	}
 
+	return key, nil
 }
 
-func UpdatePacketConductor(key int, p addie.PacketConductor) (int, error) {
+func ReadSystemLinks(key int) ([]addie.Link, error) {
+
+	var result []addie.Link
 
 	q := fmt.Sprintf(
-		"UPDATE packet_conductors SET capacity = %d, latency = %d WHERE id = %d",
-		p.Capacity, p.Latency, key)
+		"SELECT links.id FROM links "+
+			"INNER JOIN ids on links.id = ids.id "+
+			"WHERE ids.sys_id = %d", key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
 
-	err := runC(q)
 	if err != nil {
-		return key, updateFailure(err)
+		return nil, selectFailure(err)
 	}
 
-	return key, nil
+	for rows.Next() {
+		var lnk_key int
+		err := rows.Scan(&lnk_key)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+
+		lnk, err := ReadLinkByKey(lnk_key)
+		if err != nil {
+			return nil, readFailure(err)
+		}
+		result = append(result, *lnk)
+	}
+
+	return result, nil
 
 }
 
-// Computers -------------------------------------------------------------------------
+// Models ---------------------------------------------------------------------------
 
-func CreateComputer(c addie.Computer, owner string) error {
+func CreateModel(m addie.Model, owner string) error {
 
-	//nethost insert
-	host_key, err := CreateNetworkHost(c.NetHost, owner)
+	user_key, err := ReadUserKey(owner)
 	if err != nil {
-		return createFailure(err)
+		return readFailure(err)
 	}
 
-	//position insert
-	pos_key, err := CreatePosition(c.Position)
+	tests, err := json.Marshal(m.Tests)
 	if err != nil {
 		return createFailure(err)
 	}
 
-	//interfaces insert
-	for _, ifx := range c.Interfaces {
-		err = CreateInterface(host_key, ifx)
-		if err != nil {
-			return createFailure(err)
-		}
-	}
-
-	//computer insert
-	q := fmt.Sprintf("INSERT INTO computers (id, os, start_script, position_id) "+
-		"values (%d, '%s', '%s', %d)", host_key, c.OS, c.Start_script, pos_key)
+	q := fmt.Sprintf("INSERT INTO models (user_id, name, equations, params, icon, tests) "+
+		"values (%d, '%s', '%s', '%s', '%s', '%s')",
+		user_key, m.Name, pgMathStr(m.Equations), m.Params, m.Icon,
+		pgMathStr(string(tests)))
 
 	err = runC(q)
 	if err != nil {
 		return insertFailure(err)
 	}
 
-	return nil
+	return createModelVersion(m, user_key)
 }
 
-func UpdateComputer(oid addie.Id, old addie.Computer, c addie.Computer, owner string) (
-	int, error) {
-
-	key, err := UpdateNetworkHost(oid, old.NetHost, c.NetHost, owner)
-	if err != nil {
-		return -1, updateFailure(err)
-	}
+func UpdateModel(oldName string, m addie.Model, owner string) error {
 
-	q := fmt.Sprintf("SELECT position_id FROM computers WHERE id = %d", key)
-	rows, err := runQ(q)
-	defer safeClose(rows)
-	if err != nil {
-		return key, selectFailure(err)
-	}
-	if !rows.Next() {
-		return key, emptyReadFailure()
-	}
-	var pos_key int
-	err = rows.Scan(&pos_key)
+	user_key, err := ReadUserKey(owner)
 	if err != nil {
-		return key, scanFailure(err)
+		return readFailure(err)
 	}
 
-	_, err = UpdatePosition(pos_key, c.Position)
+	tests, err := json.Marshal(m.Tests)
 	if err != nil {
-		return key, updateFailure(err)
+		return updateFailure(err)
 	}
 
-	q = fmt.Sprintf(
-		"UPDATE computers SET os = '%s', start_script = '%s' WHERE id = %d",
-		c.OS, c.Start_script, key)
+	q := fmt.Sprintf("UPDATE models SET name = '%s', equations = '%s', params = '%s', icon = '%s', "+
+		"tests = '%s' WHERE user_id = %d AND name = '%s'", m.Name, pgMathStr(m.Equations),
+		m.Params, m.Icon, pgMathStr(string(tests)), user_key, oldName)
 
 	err = runC(q)
 	if err != nil {
-		return key, updateFailure(err)
+		return updateFailure(err)
 	}
 
-	return key, nil
+	return createModelVersion(m, user_key)
 
 }
 
-func ReadComputerByKey(id_key int) (*addie.Computer, error) {
-
-	id, err := ReadId(id_key)
-	if err != nil {
-		return nil, readFailure(err)
-	}
+func ReadModelByKey(key int) (*addie.Model, error) {
 
-	q := fmt.Sprintf(
-		"SELECT os, start_script, position_id FROM computers WHERE id = %d", id_key)
+	q := fmt.Sprintf("SELECT name, equations, params, icon, tests FROM models WHERE id = %d",
+		key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
 		return nil, selectFailure(err)
 	}
-
 	if !rows.Next() {
 		return nil, emptyReadFailure()
 	}
-	var os, start_script string
-	var pos_key int
-	err = rows.Scan(&os, &start_script, &pos_key)
+
+	var name, equations, params, icon string
+	var tests sql.NullString
+	err = rows.Scan(&name, &equations, &params, &icon, &tests)
 	if err != nil {
 		return nil, scanFailure(err)
 	}
 	rows.Close()
 
-	pos, err := ReadPosition(pos_key)
+	var m addie.Model
+	m.Name = name
+	m.Equations = equations
+	m.Params = params
+	m.Icon = icon
+
+	if tests.Valid && tests.String != "" {
+		err = json.Unmarshal([]byte(tests.String), &m.Tests)
+		if err != nil {
+			return nil, readFailure(err)
+		}
+	}
+
+	return &m, nil
+
+}
+
+func ReadModel(name, owner string) (*addie.Model, error) {
+
+	key, err := ReadModelKey(name, owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	ifs, err := ReadHostInterfaces(id_key)
+	return ReadModelByKey(key)
+}
+
+func ReadModelKey(name, owner string) (int, error) {
+
+	user_key, err := ReadUserKey(owner)
 	if err != nil {
-		return nil, readFailure(err)
+		return -1, readFailure(err)
 	}
 
-	c := addie.Computer{}
-	c.Id = *id
-	c.Interfaces = *ifs
-	c.OS = os
-	c.Start_script = start_script
-	c.Position = *pos
+	q := fmt.Sprintf("SELECT id FROM models WHERE name = '%s' AND user_id = %d",
+		name, user_key)
 
-	return &c, nil
+	key, err := getKey(q)
+	if err != nil {
+		log.Printf("ReadModelKey: %s", name)
+		return -1, selectFailure(err)
+	}
 
+	return key, nil
 }
 
-func ReadComputer(id addie.Id, owner string) (*addie.Computer, error) {
+func ReadUserModels(owner string) ([]addie.Model, error) {
 
-	id_key, err := ReadIdKey(id, owner)
+	var result []addie.Model
+
+	user_key, err := ReadUserKey(owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	return ReadComputerByKey(id_key)
+	q := fmt.Sprintf("SELECT id FROM models WHERE user_id = %d", user_key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+
+	for rows.Next() {
+		var key int
+		err := rows.Scan(&key)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+
+		mdl, err := ReadModelByKey(key)
+		if err != nil {
+			return nil, readFailure(err)
+		}
+		result = append(result, *mdl)
+	}
+
+	return result, nil
 
 }
 
-// Routers ---------------------------------------------------------------------------
+// Model Versions -------------------------------------------------------------------
 
-func CreateRouter(r addie.Router, owner string) error {
+/*
+Every save of a model is kept as a numbered version, versions follow the model
+through renames since they belong to the model's key rather than its name
+*/
+func createModelVersion(m addie.Model, user_key int) error {
 
-	//create nethost
-	host_key, err := CreateNetworkHost(r.NetHost, owner)
+	q := fmt.Sprintf("SELECT id FROM models WHERE name = '%s' AND user_id = %d",
+		m.Name, user_key)
+	model_key, err := getKey(q)
 	if err != nil {
-		return createFailure(err)
+		return selectFailure(err)
 	}
 
-	//position insert
-	pos_key, err := CreatePosition(r.Position)
-	if err != nil {
-		return createFailure(err)
-	}
+	q = fmt.Sprintf("INSERT INTO model_versions "+
+		"(model_id, version, name, equations, params, icon, author_id, saved) "+
+		"SELECT %d, COALESCE(MAX(version), 0) + 1, '%s', '%s', '%s', '%s', %d, '%s' "+
+		"FROM model_versions WHERE model_id = %d",
+		model_key, m.Name, pgMathStr(m.Equations), m.Params, m.Icon, user_key,
+		time.Now().UTC().Format(time.RFC3339), model_key)
 
-	//packet conductor insert
-	pkt_key, err := CreatePacketConductor(r.PacketConductor)
+	err = runC(q)
 	if err != nil {
-		return createFailure(err)
+		return insertFailure(err)
 	}
 
-	//router insert
-	q := fmt.Sprintf(
-		"INSERT INTO routers (id, packet_conductor_id, position_id) "+
-			"values (%d, %d, %d)",
-		host_key, pkt_key, pos_key)
+	return nil
 
-	err = runC(q)
+}
+
+const versionCols = "v.version, v.name, v.equations, v.params, v.icon, u.name, v.saved"
+
+func scanModelVersion(rows *sql.Rows) (*addie.ModelVersion, error) {
+
+	var v addie.ModelVersion
+	err := rows.Scan(&v.Version, &v.Name, &v.Equations, &v.Params, &v.Icon,
+		&v.Author, &v.Saved)
 	if err != nil {
-		return insertFailure(err)
+		return nil, scanFailure(err)
 	}
 
-	return nil
+	return &v, nil
+
 }
 
-func ReadRouterByKey(key int) (*addie.Router, error) {
+/*
+ReadModelVersions returns the saved versions of a model, oldest first
+*/
+func ReadModelVersions(name, owner string) ([]addie.ModelVersion, error) {
 
-	id, err := ReadId(key)
+	model_key, err := ReadModelKey(name, owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf(
-		"SELECT packet_conductor_id, position_id FROM routers WHERE id = %d", key)
+	q := fmt.Sprintf("SELECT "+versionCols+" FROM model_versions AS v "+
+		"INNER JOIN users AS u ON v.author_id = u.id "+
+		"WHERE v.model_id = %d ORDER BY v.version", model_key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
 		return nil, selectFailure(err)
 	}
-	if !rows.Next() {
-		return nil, emptyReadFailure()
-	}
 
-	var pkt_key, pos_key int
-	err = rows.Scan(&pkt_key, &pos_key)
-	if err != nil {
-		return nil, scanFailure(err)
+	var result []addie.ModelVersion
+	for rows.Next() {
+		v, err := scanModelVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *v)
 	}
-	rows.Close()
 
-	pos, err := ReadPosition(pos_key)
-	if err != nil {
-		return nil, readFailure(err)
-	}
+	return result, nil
 
-	pkt, err := ReadPacketConductor(pkt_key)
+}
+
+func ReadModelVersion(name string, version int, owner string) (*addie.ModelVersion, error) {
+
+	model_key, err := ReadModelKey(name, owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	ifs, err := ReadHostInterfaces(key)
+	q := fmt.Sprintf("SELECT "+versionCols+" FROM model_versions AS v "+
+		"INNER JOIN users AS u ON v.author_id = u.id "+
+		"WHERE v.model_id = %d AND v.version = %d", model_key, version)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
 	if err != nil {
-		return nil, readFailure(err)
+		return nil, selectFailure(err)
+	}
+	if !rows.Next() {
+		return nil, emptyReadFailure()
 	}
 
-	rtr := addie.Router{}
-	rtr.Id = *id
-	rtr.Interfaces = *ifs
-	rtr.PacketConductor = *pkt
-	rtr.Position = *pos
-
-	return &rtr, nil
+	return scanModelVersion(rows)
 
 }
 
-func ReadRouter(id addie.Id, owner string) (*addie.Router, error) {
+const designPhyos = "phyos AS p " +
+	"INNER JOIN ids AS i ON i.id = p.id " +
+	"INNER JOIN systems AS s ON s.id = i.sys_id "
 
-	key, err := ReadIdKey(id, owner)
+/*
+RecordModelUsage records the model versions a design was compiled with, it
+replaces whatever the design was compiled with before
+*/
+func RecordModelUsage(design_key int) error {
+
+	q := fmt.Sprintf("DELETE FROM design_model_versions WHERE design_id = %d",
+		design_key)
+	err := runC(q)
 	if err != nil {
-		return nil, readFailure(err)
+		return deleteFailure(err)
 	}
 
-	return ReadRouterByKey(key)
+	q = fmt.Sprintf("INSERT INTO design_model_versions (design_id, model_id, version) "+
+		"SELECT %d, v.model_id, MAX(v.version) FROM model_versions AS v "+
+		"WHERE v.model_id IN (SELECT p.model_id FROM "+designPhyos+
+		"WHERE s.design_id = %d) GROUP BY v.model_id", design_key, design_key)
+	err = runC(q)
+	if err != nil {
+		return insertFailure(err)
+	}
+
+	return nil
+
 }
 
-func UpdateRouter(oid addie.Id, old addie.Router, r addie.Router, owner string) (
-	int, error) {
+/*
+ReadModelUsage returns the designs whose phyos use a model, along with the
+version of the model each design was last compiled with
+*/
+func ReadModelUsage(name, owner string) ([]addie.ModelUsage, error) {
 
-	key, err := UpdateNetworkHost(oid, old.NetHost, r.NetHost, owner)
+	model_key, err := ReadModelKey(name, owner)
 	if err != nil {
-		return -1, updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT position_id, packet_conductor_id FROM routers "+
-		"WHERE id = %d", key)
+	q := fmt.Sprintf("SELECT d.name, COUNT(p.id), COALESCE(MAX(dv.version), 0) "+
+		"FROM "+designPhyos+
+		"INNER JOIN designs AS d ON d.id = s.design_id "+
+		"LEFT JOIN design_model_versions AS dv "+
+		"ON dv.design_id = d.id AND dv.model_id = p.model_id "+
+		"WHERE p.model_id = %d GROUP BY d.name ORDER BY d.name", model_key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return key, selectFailure(err)
-	}
-	if !rows.Next() {
-		return key, emptyReadFailure()
-	}
-	var pos_key, pkt_key int
-	err = rows.Scan(&pos_key, &pkt_key)
-	if err != nil {
-		return key, scanFailure(err)
+		return nil, selectFailure(err)
 	}
 
-	_, err = UpdatePosition(pos_key, r.Position)
-	if err != nil {
-		return key, updateFailure(err)
+	var result []addie.ModelUsage
+	for rows.Next() {
+		var u addie.ModelUsage
+		err = rows.Scan(&u.Design, &u.Phyos, &u.Compiled)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+		result = append(result, u)
 	}
 
-	_, err = UpdatePacketConductor(pkt_key, r.PacketConductor)
-	if err != nil {
-		return key, updateFailure(err)
-	}
+	return result, nil
 
-	return key, nil
 }
 
-func ReadSystemRouters(key int) ([]addie.Router, error) {
+// Published Models -----------------------------------------------------------------
 
-	var result []addie.Router
+/*
+ReadUserScopes returns the scopes a user may read and publish models in, the
+global scope and the teams the user is a member of
+*/
+func ReadUserScopes(owner string) ([]string, error) {
 
-	q := fmt.Sprintf(
-		"SELECT routers.id FROM routers "+
-			"INNER JOIN ids on routers.id = ids.id "+
-			"WHERE ids.sys_id = %d", key)
+	user_key, err := ReadUserKey(owner)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT team FROM team_members WHERE user_id = %d ORDER BY team",
+		user_key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
-
 	if err != nil {
 		return nil, selectFailure(err)
 	}
 
+	result := []string{"global"}
 	for rows.Next() {
-		var rtr_key int
-		err := rows.Scan(&rtr_key)
+		var team string
+		err = rows.Scan(&team)
 		if err != nil {
 			return nil, scanFailure(err)
 		}
-
-		rtr, err := ReadRouterByKey(rtr_key)
-		if err != nil {
-			return nil, readFailure(err)
-		}
-		result = append(result, *rtr)
+		result = append(result, team)
 	}
 
 	return result, nil
 
 }
 
-// Switches --------------------------------------------------------------------------
-
-func CreateSwitch(s addie.Switch, owner string) error {
+func checkScope(scope, owner string) error {
 
-	//id insert
-	id_key, err := CreateNetworkHost(s.NetHost, owner)
+	scopes, err := ReadUserScopes(owner)
 	if err != nil {
-		return createFailure(err)
+		return readFailure(err)
 	}
 
-	//packet conductor insert
-	pkt_key, err := CreatePacketConductor(s.PacketConductor)
-	if err != nil {
-		return createFailure(err)
+	for _, s := range scopes {
+		if s == scope {
+			return nil
+		}
 	}
 
-	//position insert
-	pos_key, err := CreatePosition(s.Position)
-	if err != nil {
-		return createFailure(err)
-	}
+	return fmt.Errorf("user %s is not a member of scope %s", owner, scope)
 
-	//switch insert
-	q := fmt.Sprintf(
-		"INSERT INTO switches (id, packet_conductor_id, position_id)  "+
-			"values (%d, %d, %d)",
-		id_key, pkt_key, pos_key)
+}
 
-	err = runC(q)
-	if err != nil {
-		return insertFailure(err)
-	}
+/*
+PublishModel publishes a version of a model to a scope. Published versions
+are immutable, publishing a version that already exists fails.
+*/
+func PublishModel(m addie.Model, scope, version, owner string) error {
 
-	return nil
-}
+	if version == "" {
+		return fmt.Errorf("published models must have a version")
+	}
 
-func ReadSwitchByKey(key int) (*addie.Switch, error) {
+	err := checkScope(scope, owner)
+	if err != nil {
+		return err
+	}
 
-	id, err := ReadId(key)
+	user_key, err := ReadUserKey(owner)
 	if err != nil {
-		return nil, readFailure(err)
+		return readFailure(err)
 	}
 
-	q := fmt.Sprintf(
-		"SELECT packet_conductor_id, position_id FROM switches WHERE id = %d", key)
+	q := fmt.Sprintf("SELECT id FROM published_models "+
+		"WHERE scope = '%s' AND name = '%s' AND version = '%s'",
+		pgMathStr(scope), pgMathStr(m.Name), pgMathStr(version))
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return nil, selectFailure(err)
-	}
-	if !rows.Next() {
-		return nil, emptyReadFailure()
+		return selectFailure(err)
 	}
-
-	var pkt_key, pos_key int
-	err = rows.Scan(&pkt_key, &pos_key)
-	if err != nil {
-		return nil, scanFailure(err)
+	if rows.Next() {
+		return fmt.Errorf("%s is already published, published versions are immutable",
+			addie.ModelRef{Scope: scope, Name: m.Name, Version: version})
 	}
 	rows.Close()
 
-	pos, err := ReadPosition(pos_key)
-	if err != nil {
-		return nil, readFailure(err)
-	}
-
-	pkt, err := ReadPacketConductor(pkt_key)
-	if err != nil {
-		return nil, readFailure(err)
-	}
+	q = fmt.Sprintf("INSERT INTO published_models "+
+		"(scope, name, version, equations, params, icon, publisher_id, published) "+
+		"values ('%s', '%s', '%s', '%s', '%s', '%s', %d, '%s')",
+		pgMathStr(scope), pgMathStr(m.Name), pgMathStr(version), pgMathStr(m.Equations),
+		pgMathStr(m.Params), pgMathStr(m.Icon), user_key, time.Now().UTC().Format(time.RFC3339))
 
-	ifs, err := ReadHostInterfaces(key)
+	err = runC(q)
 	if err != nil {
-		return nil, readFailure(err)
+		return insertFailure(err)
 	}
 
-	sw := addie.Switch{}
-	sw.Id = *id
-	sw.Interfaces = *ifs
-	sw.PacketConductor = *pkt
-	sw.Position = *pos
-
-	return &sw, nil
+	return nil
 
 }
 
-func ReadSwitch(id addie.Id, owner string) (*addie.Switch, error) {
+const publishedCols = "p.scope, p.name, p.version, p.equations, p.params, p.icon, " +
+	"u.name, p.published"
 
-	key, err := ReadIdKey(id, owner)
+func scanPublishedModel(rows *sql.Rows) (*addie.PublishedModel, error) {
+
+	var m addie.PublishedModel
+	err := rows.Scan(&m.Scope, &m.Name, &m.Version, &m.Equations, &m.Params,
+		&m.Icon, &m.Publisher, &m.Published)
 	if err != nil {
-		return nil, readFailure(err)
+		return nil, scanFailure(err)
 	}
 
-	return ReadSwitchByKey(key)
+	return &m, nil
+
 }
 
-func UpdateSwitch(oid addie.Id, old addie.Switch, s addie.Switch, owner string) (
-	int, error) {
+/*
+ReadPublishedModel resolves a model reference on behalf of a user, a
+reference without a version resolves to the latest published version
+*/
+func ReadPublishedModel(ref addie.ModelRef, owner string) (*addie.PublishedModel, error) {
 
-	key, err := UpdateNetworkHost(oid, old.NetHost, s.NetHost, owner)
+	err := checkScope(ref.Scope, owner)
 	if err != nil {
-		return -1, updateFailure(err)
+		return nil, err
 	}
 
-	q := fmt.Sprintf("SELECT packet_conductor_id, position_id FROM switches "+
-		"WHERE id = %d", key)
+	q := fmt.Sprintf("SELECT "+publishedCols+" FROM published_models AS p "+
+		"INNER JOIN users AS u ON p.publisher_id = u.id "+
+		"WHERE p.scope = '%s' AND p.name = '%s'", pgMathStr(ref.Scope), pgMathStr(ref.Name))
+	if ref.Pinned() {
+		q += fmt.Sprintf(" AND p.version = '%s'", pgMathStr(ref.Version))
+	}
+	q += " ORDER BY p.published DESC LIMIT 1"
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return key, selectFailure(err)
-	}
-	if !rows.Next() {
-		return key, emptyReadFailure()
-	}
-	var pos_key, pkt_key int
-	err = rows.Scan(&pkt_key, &pos_key)
-	if err != nil {
-		return key, scanFailure(err)
-	}
-
-	_, err = UpdatePosition(pos_key, s.Position)
-	if err != nil {
-		return key, updateFailure(err)
+		return nil, selectFailure(err)
 	}
-
-	_, err = UpdatePacketConductor(pkt_key, s.PacketConductor)
-	if err != nil {
-		return key, updateFailure(err)
+	if !rows.Next() {
+		return nil, emptyReadFailure()
 	}
 
-	return key, nil
+	return scanPublishedModel(rows)
 
 }
 
-func ReadSystemSwitches(key int) ([]addie.Switch, error) {
+/*
+ReadPublishedModels returns every published model version a user can read
+*/
+func ReadPublishedModels(owner string) ([]addie.PublishedModel, error) {
 
-	var result []addie.Switch
+	scopes, err := ReadUserScopes(owner)
+	if err != nil {
+		return nil, readFailure(err)
+	}
 
-	q := fmt.Sprintf(
-		"SELECT switches.id FROM switches "+
-			"INNER JOIN ids on switches.id = ids.id "+
-			"WHERE ids.sys_id = %d", key)
+	for i, scope := range scopes {
+		scopes[i] = pgMathStr(scope)
+	}
+	q := fmt.Sprintf("SELECT "+publishedCols+" FROM published_models AS p "+
+		"INNER JOIN users AS u ON p.publisher_id = u.id "+
+		"WHERE p.scope IN ('%s') ORDER BY p.scope, p.name, p.published",
+		strings.Join(scopes, "', '"))
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
-
 	if err != nil {
 		return nil, selectFailure(err)
 	}
 
+	var result []addie.PublishedModel
 	for rows.Next() {
-		var sw_key int
-		err := rows.Scan(&sw_key)
-		if err != nil {
-			return nil, scanFailure(err)
-		}
-
-		sw, err := ReadSwitchByKey(sw_key)
+		m, err := scanPublishedModel(rows)
 		if err != nil {
-			return nil, readFailure(err)
+			return nil, err
 		}
-		result = append(result, *sw)
+		result = append(result, *m)
 	}
 
 	return result, nil
 
 }
 
-// Links -----------------------------------------------------------------------------
+// Phyos ----------------------------------------------------------------------------
 
-func CreateLink(l addie.Link, owner string) error {
+/*
+The phyoModel function returns the model_id and model_ref columns of a phyo.
+Phyos built on the user's own models reference them by key, phyos built on
+published models keep the reference and have no model key.
+*/
+func phyoModel(p addie.Phyo, owner string) (string, string, error) {
 
-	//id insert
-	id_key, err := CreateId(l.Id, owner)
-	if err != nil {
-		return createFailure(err)
+	ref, ok := addie.ParseModelRef(p.Model)
+	if ok {
+		_, err := ReadPublishedModel(ref, owner)
+		if err != nil {
+			return "", "", readFailure(err)
+		}
+		return "NULL", pgMathStr(ref.String()), nil
 	}
 
-	//packet conductor insert
-	pkt_key, err := CreatePacketConductor(l.PacketConductor)
+	mdl_key, err := ReadModelKey(p.Model, owner)
 	if err != nil {
-		return createFailure(err)
+		return "", "", readFailure(err)
 	}
 
-	//endpoint0
-	ep0_key, err := ReadIdKey(l.Endpoints[0].Id, owner)
+	return fmt.Sprintf("%d", mdl_key), "", nil
+
+}
+
+func CreatePhyo(p addie.Phyo, owner string) (int, error) {
+
+	key, err := CreateId(p.Id, owner)
 	if err != nil {
-		return readFailure(err)
+		return -1, createFailure(err)
 	}
-	if0_key, err := ReadInterfaceKey(ep0_key, l.Endpoints[0].IfName)
+
+	err = UpdateAttributes(key, p.Attributes)
 	if err != nil {
-		return readFailure(err)
+		return key, createFailure(err)
 	}
 
-	//endpoint1
-	ep1_key, err := ReadIdKey(l.Endpoints[1].Id, owner)
+	pos_key, err := CreatePosition(p.Position)
 	if err != nil {
-		return readFailure(err)
+		return key, createFailure(err)
 	}
-	if1_key, err := ReadInterfaceKey(ep1_key, l.Endpoints[1].IfName)
+
+	mdl_key, mdl_ref, err := phyoModel(p, owner)
 	if err != nil {
-		return readFailure(err)
+		return key, readFailure(err)
 	}
 
-	q := fmt.Sprintf(
-		"INSERT INTO links "+
-			"(id, packet_conductor_id, "+
-			"endpoint_a_id, interface_a_id, "+
-			"endpoint_b_id, interface_b_id) "+
-			"VALUES (%d, %d, %d, %d, %d, %d)",
-		id_key, pkt_key, ep0_key, if0_key, ep1_key, if1_key)
+	q := fmt.Sprintf("INSERT INTO phyos (id, position_id, model_id, model_ref, args, init) "+
+		"values (%d, %d, %s, '%s', '%s', '%s')",
+		key, pos_key, mdl_key, mdl_ref, pgMathStr(p.Args), pgMathStr(p.Init))
 
 	err = runC(q)
 	if err != nil {
-		return insertFailure(err)
+		return key, insertFailure(err)
 	}
 
-	return nil
+	return key, nil
 
 }
 
-func ReadLinkByKey(key int) (*addie.Link, error) {
+func UpdatePhyo(oid addie.Id, p addie.Phyo, owner string) (int, error) {
 
-	id, err := ReadId(key)
+	key, err := UpdateId(oid, p.Id, owner)
 	if err != nil {
-		return nil, readFailure(err)
+		return -1, updateFailure(err)
 	}
 
-	q := fmt.Sprintf(
-		"SELECT packet_conductor_id, "+
-			"endpoint_a_id, interface_a_id, "+
-			"endpoint_b_id, interface_b_id "+
-			"FROM links WHERE id = %d",
-		key)
+	err = UpdateAttributes(key, p.Attributes)
+	if err != nil {
+		return key, updateFailure(err)
+	}
 
+	q := fmt.Sprintf("SELECT position_id FROM phyos WHERE id = %d", key)
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return nil, selectFailure(err)
+		return key, selectFailure(err)
 	}
 	if !rows.Next() {
-		return nil, emptyReadFailure()
-	}
-
-	var pkt_key, ep0_key, if0_key, ep1_key, if1_key int
-	err = rows.Scan(&pkt_key, &ep0_key, &if0_key, &ep1_key, &if1_key)
-	if err != nil {
-		return nil, scanFailure(err)
+		return key, emptyReadFailure()
 	}
-	rows.Close()
-
-	pkt, err := ReadPacketConductor(pkt_key)
+	var pos_key int
+	err = rows.Scan(&pos_key)
 	if err != nil {
-		return nil, readFailure(err)
+		return key, scanFailure(err)
 	}
 
-	ep0, err := ReadId(ep0_key)
+	_, err = UpdatePosition(pos_key, p.Position)
 	if err != nil {
-		return nil, readFailure(err)
+		return key, updateFailure(err)
 	}
 
-	ep1, err := ReadId(ep1_key)
+	mdl_key, mdl_ref, err := phyoModel(p, owner)
 	if err != nil {
-		return nil, readFailure(err)
+		return key, readFailure(err)
 	}
 
-	if0, err := ReadInterface(if0_key)
-	if err != nil {
-		return nil, readFailure(err)
-	}
+	q = fmt.Sprintf(
+		"UPDATE phyos SET args = '%s', init = '%s', model_id = %s, model_ref = '%s' "+
+			"WHERE id = %d",
+		pgMathStr(p.Args), pgMathStr(p.Init), mdl_key, mdl_ref, key)
 
-	if1, err := ReadInterface(if1_key)
+	err = runC(q)
 	if err != nil {
-		return nil, readFailure(err)
+		return key, updateFailure(err)
 	}
 
-	lnk := addie.Link{}
-	lnk.Id = *id
-	lnk.PacketConductor = *pkt
-	lnk.Endpoints[0] = addie.NetIfRef{*ep0, if0.Name}
-	lnk.Endpoints[1] = addie.NetIfRef{*ep1, if1.Name}
-
-	return &lnk, nil
+	return key, nil
 
 }
 
-func ReadLink(id addie.Id, owner string) (*addie.Link, error) {
+func ReadPhyoByKey(key int) (*addie.Phyo, error) {
 
-	key, err := ReadIdKey(id, owner)
+	id, err := ReadId(key)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	return ReadLinkByKey(key)
-}
-
-func UpdateLink(oid addie.Id, l addie.Link, owner string) (int, error) {
-
-	key, err := UpdateId(oid, l.Id, owner)
+	attrs, err := ReadAttributes(key)
 	if err != nil {
-		return -1, updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT packet_conductor_id FROM links WHERE id = %d", key)
+	q := fmt.Sprintf("SELECT args, init, model_id, model_ref, position_id FROM phyos "+
+		"WHERE id = %d", key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return key, selectFailure(err)
+		return nil, selectFailure(err)
 	}
+
 	if !rows.Next() {
-		return key, emptyReadFailure()
+		return nil, emptyReadFailure()
 	}
 
-	var pkt_key int
-	err = rows.Scan(&pkt_key)
+	var args, init string
+	var pos_key int
+	var mdl_key sql.NullInt64
+	var mdl_ref sql.NullString
+	err = rows.Scan(&args, &init, &mdl_key, &mdl_ref, &pos_key)
 	if err != nil {
-		return key, scanFailure(err)
+		return nil, scanFailure(err)
 	}
+	rows.Close()
 
-	//packet conductor
-	_, err = UpdatePacketConductor(pkt_key, l.PacketConductor)
+	pos, err := ReadPosition(pos_key)
 	if err != nil {
-		return key, updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	//endpoints
-	e0, err := ReadIdKey(l.Endpoints[0].Id, owner)
-	if err != nil {
-		return key, readFailure(err)
-	}
-	e1, err := ReadIdKey(l.Endpoints[1].Id, owner)
-	if err != nil {
-		return key, readFailure(err)
-	}
+	p := addie.Phyo{}
+	p.Id = *id
+	p.Position = *pos
+	p.Args = args
+	p.Init = init
 
-	//interfaces
-	i0, err := ReadInterfaceKey(e0, l.Endpoints[0].IfName)
-	if err != nil {
-		return key, readFailure(err)
-	}
-	i1, err := ReadInterfaceKey(e1, l.Endpoints[1].IfName)
-	if err != nil {
-		return key, readFailure(err)
+	if mdl_key.Valid {
+		mdl, err := ReadModelByKey(int(mdl_key.Int64))
+		if err != nil {
+			return nil, readFailure(err)
+		}
+		p.Model = mdl.Name
+	} else {
+		p.Model = mdl_ref.String
 	}
+	p.Attributes = attrs
 
-	q = fmt.Sprintf(
-		"UPDATE links SET "+
-			"endpoint_a_id = %d, interface_a_id = %d, "+
-			"endpoint_b_id = %d, interface_b_id = %d "+
-			"WHERE id = %d", e0, i0, e1, i1, key)
-	err = runC(q)
+	return &p, nil
+}
+
+func ReadPhyo(id addie.Id, owner string) (*addie.Phyo, error) {
+
+	key, err := ReadIdKey(id, owner)
 	if err != nil {
-		return key, updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	return key, nil
+	return ReadPhyoByKey(key)
+
 }
 
-func ReadSystemLinks(key int) ([]addie.Link, error) {
+func ReadSystemPhyos(key int) ([]addie.Phyo, error) {
 
-	var result []addie.Link
+	var result []addie.Phyo
 
 	q := fmt.Sprintf(
-		"SELECT links.id FROM links "+
-			"INNER JOIN ids on links.id = ids.id "+
+		"SELECT phyos.id FROM phyos "+
+			"INNER JOIN ids on phyos.id = ids.id "+
 			"WHERE ids.sys_id = %d", key)
 
 	rows, err := runQ(q)
@@ -1789,133 +3125,158 @@ func ReadSystemLinks(key int) ([]addie.Link, error) {
 	}
 
 	for rows.Next() {
-		var lnk_key int
-		err := rows.Scan(&lnk_key)
+		var pyo_key int
+		err := rows.Scan(&pyo_key)
 		if err != nil {
 			return nil, scanFailure(err)
 		}
 
-		lnk, err := ReadLinkByKey(lnk_key)
+		pyo, err := ReadPhyoByKey(pyo_key)
 		if err != nil {
 			return nil, readFailure(err)
 		}
-		result = append(result, *lnk)
+		result = append(result, *pyo)
 	}
 
 	return result, nil
 
 }
 
-// Models ---------------------------------------------------------------------------
+// Saxs ------------------------------------------------------------------------------
 
-func CreateModel(m addie.Model, owner string) error {
+func CreateSax(s addie.Sax, owner string) (int, error) {
+
+	key, err := CreateNetworkHost(s.NetHost, owner)
+	if err != nil {
+		return -1, createFailure(err)
+	}
+
+	pos_key, err := CreatePosition(s.Position)
+	if err != nil {
+		return key, createFailure(err)
+	}
+
+	q := fmt.Sprintf("INSERT INTO saxs (id, position_id, sense, actuate) "+
+		"values (%d, %d, '%s', '%s')", key, pos_key, s.Sense, s.Actuate)
+
+	err = runC(q)
+	if err != nil {
+		return key, insertFailure(err)
+	}
+
+	return key, nil
+
+}
+
+func UpdateSax(oid addie.Id, old, s addie.Sax, owner string) (int, error) {
+
+	key, err := UpdateNetworkHost(oid, old.NetHost, s.NetHost, owner)
+	if err != nil {
+		return -1, updateFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT position_id FROM saxs WHERE id = %d", key)
+	rows, err := runQ(q)
+	if err != nil {
+		return key, selectFailure(err)
+	}
+	if !rows.Next() {
+		return key, emptyReadFailure()
+	}
+	var pos_key int
+	err = rows.Scan(&pos_key)
+	if err != nil {
+		return key, scanFailure(err)
+	}
 
-	user_key, err := ReadUserKey(owner)
+	_, err = UpdatePosition(pos_key, s.Position)
 	if err != nil {
-		return readFailure(err)
+		return key, updateFailure(err)
 	}
 
-	q := fmt.Sprintf("INSERT INTO models (user_id, name, equations, params, icon) "+
-		"values (%d, '%s', '%s', '%s', '%s')",
-		user_key, m.Name, pgMathStr(m.Equations), m.Params, m.Icon)
+	q = fmt.Sprintf("UPDATE saxs SET actuate = '%s', sense = '%s' WHERE id = %d",
+		s.Actuate, s.Sense, key)
 
 	err = runC(q)
 	if err != nil {
-		return insertFailure(err)
+		return key, updateFailure(err)
 	}
 
-	return nil
+	return key, nil
+
 }
 
-func UpdateModel(oldName string, m addie.Model, owner string) error {
+func ReadSaxByKey(key int) (*addie.Sax, error) {
 
-	user_key, err := ReadUserKey(owner)
+	id, err := ReadId(key)
 	if err != nil {
-		return readFailure(err)
+		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf("UPDATE models SET name = '%s', equations = '%s', params = '%s', icon = '%s' "+
-		"WHERE user_id = %d AND name = '%s'", m.Name, pgMathStr(m.Equations), m.Params, m.Icon,
-		user_key, oldName)
-
-	err = runC(q)
+	attrs, err := ReadAttributes(key)
 	if err != nil {
-		return updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	return nil
-
-}
-
-func ReadModelByKey(key int) (*addie.Model, error) {
-
-	q := fmt.Sprintf("SELECT name, equations, params, icon FROM models WHERE id = %d", key)
+	q := fmt.Sprintf("SELECT sense, actuate, position_id FROM saxs WHERE id = %d",
+		key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
 		return nil, selectFailure(err)
 	}
+
 	if !rows.Next() {
 		return nil, emptyReadFailure()
 	}
 
-	var name, equations, params, icon string
-	err = rows.Scan(&name, &equations, &params, &icon)
-	if err != nil {
-		return nil, scanFailure(err)
-	}
+	var sense, actuate string
+	var pos_key int
+	err = rows.Scan(&sense, &actuate, &pos_key)
 	rows.Close()
 
-	var m addie.Model
-	m.Name = name
-	m.Equations = equations
-	m.Params = params
-	m.Icon = icon
-
-	return &m, nil
-
-}
-
-func ReadModel(name, owner string) (*addie.Model, error) {
-
-	key, err := ReadModelKey(name, owner)
+	pos, err := ReadPosition(pos_key)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	return ReadModelByKey(key)
-}
-
-func ReadModelKey(name, owner string) (int, error) {
-
-	user_key, err := ReadUserKey(owner)
+	ifs, err := ReadHostInterfaces(key)
 	if err != nil {
-		return -1, readFailure(err)
+		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT id FROM models WHERE name = '%s' AND user_id = %d",
-		name, user_key)
+	s := addie.Sax{}
+	s.Id = *id
+	s.Interfaces = *ifs
+	s.Position = *pos
+	s.Sense = sense
+	s.Actuate = actuate
+	s.Attributes = attrs
 
-	key, err := getKey(q)
-	if err != nil {
-		log.Printf("ReadModelKey: %s", name)
-		return -1, selectFailure(err)
-	}
+	return &s, nil
 
-	return key, nil
 }
 
-func ReadUserModels(owner string) ([]addie.Model, error) {
-
-	var result []addie.Model
+func ReadSax(id addie.Id, owner string) (*addie.Sax, error) {
 
-	user_key, err := ReadUserKey(owner)
+	key, err := ReadIdKey(id, owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT id FROM models WHERE user_id = %d", user_key)
+	return ReadSaxByKey(key)
+
+}
+
+func ReadSystemSaxs(key int) ([]addie.Sax, error) {
+
+	var result []addie.Sax
+
+	q := fmt.Sprintf(
+		"SELECT saxs.id FROM saxs "+
+			"INNER JOIN ids on saxs.id = ids.id "+
+			"WHERE ids.sys_id = %d", key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
@@ -1925,166 +3286,170 @@ func ReadUserModels(owner string) ([]addie.Model, error) {
 	}
 
 	for rows.Next() {
-		var key int
-		err := rows.Scan(&key)
+		var sax_key int
+		err := rows.Scan(&sax_key)
 		if err != nil {
 			return nil, scanFailure(err)
 		}
 
-		mdl, err := ReadModelByKey(key)
+		sax, err := ReadSaxByKey(sax_key)
 		if err != nil {
 			return nil, readFailure(err)
 		}
-		result = append(result, *mdl)
+		result = append(result, *sax)
 	}
 
 	return result, nil
 
 }
 
-// Phyos ----------------------------------------------------------------------------
+// Plinks ----------------------------------------------------------------------------
 
-func CreatePhyo(p addie.Phyo, owner string) (int, error) {
+func CreatePlink(p addie.Plink, owner string) error {
 
 	key, err := CreateId(p.Id, owner)
 	if err != nil {
-		return -1, createFailure(err)
+		return createFailure(err)
 	}
 
-	pos_key, err := CreatePosition(p.Position)
+	err = UpdateAttributes(key, p.Attributes)
 	if err != nil {
-		return key, createFailure(err)
+		return createFailure(err)
 	}
 
-	mdl_key, err := ReadModelKey(p.Model, owner)
+	epa_key, err := ReadIdKey(p.Endpoints[0], owner)
 	if err != nil {
-		return key, readFailure(err)
+		return readFailure(err)
 	}
 
-	q := fmt.Sprintf("INSERT INTO phyos (id, position_id, model_id, args, init) "+
-		"values (%d, %d, %d, '%s', '%s')",
-		key, pos_key, mdl_key, pgMathStr(p.Args), pgMathStr(p.Init))
+	epb_key, err := ReadIdKey(p.Endpoints[1], owner)
+	if err != nil {
+		return readFailure(err)
+	}
+
+	q := fmt.Sprintf("INSERT INTO plinks "+
+		"(id, endpoint_a_id, endpoint_b_id, epa_bindings, epb_bindings) "+
+		"VALUES (%d, %d, %d, '%s', '%s')",
+		key, epa_key, epb_key, p.Bindings[0], p.Bindings[1])
 
 	err = runC(q)
 	if err != nil {
-		return key, insertFailure(err)
+		return insertFailure(err)
 	}
 
-	return key, nil
+	return nil
 
 }
 
-func UpdatePhyo(oid addie.Id, p addie.Phyo, owner string) (int, error) {
+func ReadPlinkByKey(key int) (*addie.Plink, error) {
 
-	key, err := UpdateId(oid, p.Id, owner)
+	id, err := ReadId(key)
 	if err != nil {
-		return -1, updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT position_id FROM phyos WHERE id = %d", key)
+	attrs, err := ReadAttributes(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	q := fmt.Sprintf(
+		"SELECT endpoint_a_id, endpoint_b_id, epa_bindings, epb_bindings "+
+			"FROM plinks WHERE id = %d", key)
+
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
-		return key, selectFailure(err)
+		return nil, selectFailure(err)
 	}
 	if !rows.Next() {
-		return key, emptyReadFailure()
-	}
-	var pos_key int
-	err = rows.Scan(&pos_key)
-	if err != nil {
-		return key, scanFailure(err)
+		return nil, emptyReadFailure()
 	}
 
-	_, err = UpdatePosition(pos_key, p.Position)
+	var epa_key, epb_key int
+	var epa_bind, epb_bind string
+	err = rows.Scan(&epa_key, &epb_key, &epa_bind, &epb_bind)
 	if err != nil {
-		return key, updateFailure(err)
+		return nil, scanFailure(err)
 	}
+	rows.Close()
 
-	mdl_key, err := ReadModelKey(p.Model, owner)
+	epa, err := ReadId(epa_key)
 	if err != nil {
-		return key, readFailure(err)
+		return nil, readFailure(err)
 	}
 
-	q = fmt.Sprintf(
-		"UPDATE phyos SET args = '%s', init = '%s', model_id= %d WHERE id = %d",
-		pgMathStr(p.Args), pgMathStr(p.Init), mdl_key, key)
-
-	err = runC(q)
+	epb, err := ReadId(epb_key)
 	if err != nil {
-		return key, updateFailure(err)
+		return nil, readFailure(err)
 	}
 
-	return key, nil
+	plink := addie.Plink{}
+	plink.Id = *id
+	plink.Endpoints[0] = *epa
+	plink.Endpoints[1] = *epb
+	plink.Bindings[0] = epa_bind
+	plink.Bindings[1] = epb_bind
+	plink.Attributes = attrs
+
+	return &plink, nil
 
 }
 
-func ReadPhyoByKey(key int) (*addie.Phyo, error) {
+func ReadPlink(id addie.Id, p addie.Plink, owner string) (*addie.Plink, error) {
 
-	id, err := ReadId(key)
+	key, err := ReadIdKey(id, owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT args, init, model_id, position_id FROM phyos WHERE id = %d",
-		key)
+	return ReadPlinkByKey(key)
 
-	rows, err := runQ(q)
-	defer safeClose(rows)
-	if err != nil {
-		return nil, selectFailure(err)
-	}
+}
 
-	if !rows.Next() {
-		return nil, emptyReadFailure()
-	}
+func UpdatePlink(oid addie.Id, p addie.Plink, owner string) (int, error) {
 
-	var args, init string
-	var pos_key, mdl_key int
-	err = rows.Scan(&args, &init, &mdl_key, &pos_key)
+	key, err := UpdateId(oid, p.Id, owner)
 	if err != nil {
-		return nil, scanFailure(err)
+		return -1, updateFailure(err)
 	}
-	rows.Close()
 
-	pos, err := ReadPosition(pos_key)
+	err = UpdateAttributes(key, p.Attributes)
 	if err != nil {
-		return nil, readFailure(err)
+		return key, updateFailure(err)
 	}
 
-	mdl, err := ReadModelByKey(mdl_key)
+	ep0, err := ReadIdKey(p.Endpoints[0], owner)
 	if err != nil {
-		return nil, readFailure(err)
+		return key, readFailure(err)
 	}
 
-	p := addie.Phyo{}
-	p.Id = *id
-	p.Position = *pos
-	p.Args = args
-	p.Init = init
-	p.Model = mdl.Name
-
-	return &p, nil
-}
+	ep1, err := ReadIdKey(p.Endpoints[1], owner)
+	if err != nil {
+		return key, readFailure(err)
+	}
 
-func ReadPhyo(id addie.Id, owner string) (*addie.Phyo, error) {
+	q := fmt.Sprintf("UPDATE plinks SET "+
+		"endpoint_a_id = %d, endpoint_b_id = %d, "+
+		"epa_bindings = '%s', epb_bindings = '%s' "+
+		"WHERE id = %d", ep0, ep1, p.Bindings[0], p.Bindings[1], key)
 
-	key, err := ReadIdKey(id, owner)
+	err = runC(q)
 	if err != nil {
-		return nil, readFailure(err)
+		return key, updateFailure(err)
 	}
 
-	return ReadPhyoByKey(key)
+	return key, nil
 
 }
 
-func ReadSystemPhyos(key int) ([]addie.Phyo, error) {
+func ReadSystemPlinks(key int) ([]addie.Plink, error) {
 
-	var result []addie.Phyo
+	var result []addie.Plink
 
 	q := fmt.Sprintf(
-		"SELECT phyos.id FROM phyos "+
-			"INNER JOIN ids on phyos.id = ids.id "+
+		"SELECT plinks.id FROM plinks "+
+			"INNER JOIN ids on plinks.id = ids.id "+
 			"WHERE ids.sys_id = %d", key)
 
 	rows, err := runQ(q)
@@ -2095,39 +3460,47 @@ func ReadSystemPhyos(key int) ([]addie.Phyo, error) {
 	}
 
 	for rows.Next() {
-		var pyo_key int
-		err := rows.Scan(&pyo_key)
+		var plnk_key int
+		err := rows.Scan(&plnk_key)
 		if err != nil {
 			return nil, scanFailure(err)
 		}
 
-		pyo, err := ReadPhyoByKey(pyo_key)
+		plnk, err := ReadPlinkByKey(plnk_key)
 		if err != nil {
 			return nil, readFailure(err)
 		}
-		result = append(result, *pyo)
+		result = append(result, *plnk)
 	}
 
 	return result, nil
 
 }
 
-// Saxs ------------------------------------------------------------------------------
+// Traces ----------------------------------------------------------------------------
 
-func CreateSax(s addie.Sax, owner string) (int, error) {
+func CreateTrace(t addie.Trace, owner string) (int, error) {
 
-	key, err := CreateNetworkHost(s.NetHost, owner)
+	key, err := CreateId(t.Id, owner)
+	if err != nil {
+		return -1, createFailure(err)
+	}
+
+	err = UpdateAttributes(key, t.Attributes)
 	if err != nil {
-		return -1, createFailure(err)
+		return key, createFailure(err)
 	}
 
-	pos_key, err := CreatePosition(s.Position)
+	pos_key, err := CreatePosition(t.Position)
 	if err != nil {
 		return key, createFailure(err)
 	}
 
-	q := fmt.Sprintf("INSERT INTO saxs (id, position_id, sense, actuate) "+
-		"values (%d, %d, '%s', '%s')", key, pos_key, s.Sense, s.Actuate)
+	q := fmt.Sprintf("INSERT INTO traces "+
+		"(id, position_id, file, format, columns, interpolation, loop) "+
+		"values (%d, %d, '%s', '%s', '%s', '%s', %t)",
+		key, pos_key, pgMathStr(t.File), t.Format, pgMathStr(t.Columns),
+		t.Interpolation, t.Loop)
 
 	err = runC(q)
 	if err != nil {
@@ -2138,15 +3511,21 @@ func CreateSax(s addie.Sax, owner string) (int, error) {
 
 }
 
-func UpdateSax(oid addie.Id, old, s addie.Sax, owner string) (int, error) {
+func UpdateTrace(oid addie.Id, t addie.Trace, owner string) (int, error) {
 
-	key, err := UpdateNetworkHost(oid, old.NetHost, s.NetHost, owner)
+	key, err := UpdateId(oid, t.Id, owner)
 	if err != nil {
 		return -1, updateFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT position_id FROM saxs WHERE id = %d", key)
+	err = UpdateAttributes(key, t.Attributes)
+	if err != nil {
+		return key, updateFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT position_id FROM traces WHERE id = %d", key)
 	rows, err := runQ(q)
+	defer safeClose(rows)
 	if err != nil {
 		return key, selectFailure(err)
 	}
@@ -2159,13 +3538,14 @@ func UpdateSax(oid addie.Id, old, s addie.Sax, owner string) (int, error) {
 		return key, scanFailure(err)
 	}
 
-	_, err = UpdatePosition(pos_key, s.Position)
+	_, err = UpdatePosition(pos_key, t.Position)
 	if err != nil {
 		return key, updateFailure(err)
 	}
 
-	q = fmt.Sprintf("UPDATE saxs SET actuate = '%s', sense = '%s' WHERE id = %d",
-		s.Actuate, s.Sense, key)
+	q = fmt.Sprintf("UPDATE traces SET file = '%s', format = '%s', columns = '%s', "+
+		"interpolation = '%s', loop = %t WHERE id = %d",
+		pgMathStr(t.File), t.Format, pgMathStr(t.Columns), t.Interpolation, t.Loop, key)
 
 	err = runC(q)
 	if err != nil {
@@ -2176,70 +3556,69 @@ func UpdateSax(oid addie.Id, old, s addie.Sax, owner string) (int, error) {
 
 }
 
-func ReadSaxByKey(key int) (*addie.Sax, error) {
+func ReadTraceByKey(key int) (*addie.Trace, error) {
 
 	id, err := ReadId(key)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	q := fmt.Sprintf("SELECT sense, actuate, position_id FROM saxs WHERE id = %d",
-		key)
+	attrs, err := ReadAttributes(key)
+	if err != nil {
+		return nil, readFailure(err)
+	}
+
+	q := fmt.Sprintf("SELECT file, format, columns, interpolation, loop, position_id "+
+		"FROM traces WHERE id = %d", key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
 	if err != nil {
 		return nil, selectFailure(err)
 	}
-
 	if !rows.Next() {
 		return nil, emptyReadFailure()
 	}
 
-	var sense, actuate string
+	t := addie.Trace{}
 	var pos_key int
-	err = rows.Scan(&sense, &actuate, &pos_key)
-	rows.Close()
-
-	pos, err := ReadPosition(pos_key)
+	err = rows.Scan(&t.File, &t.Format, &t.Columns, &t.Interpolation, &t.Loop, &pos_key)
 	if err != nil {
-		return nil, readFailure(err)
+		return nil, scanFailure(err)
 	}
+	rows.Close()
 
-	ifs, err := ReadHostInterfaces(key)
+	pos, err := ReadPosition(pos_key)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	s := addie.Sax{}
-	s.Id = *id
-	s.Interfaces = *ifs
-	s.Position = *pos
-	s.Sense = sense
-	s.Actuate = actuate
+	t.Id = *id
+	t.Position = *pos
+	t.Attributes = attrs
 
-	return &s, nil
+	return &t, nil
 
 }
 
-func ReadSax(id addie.Id, owner string) (*addie.Sax, error) {
+func ReadTrace(id addie.Id, owner string) (*addie.Trace, error) {
 
 	key, err := ReadIdKey(id, owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	return ReadSaxByKey(key)
+	return ReadTraceByKey(key)
 
 }
 
-func ReadSystemSaxs(key int) ([]addie.Sax, error) {
+func ReadSystemTraces(key int) ([]addie.Trace, error) {
 
-	var result []addie.Sax
+	var result []addie.Trace
 
 	q := fmt.Sprintf(
-		"SELECT saxs.id FROM saxs "+
-			"INNER JOIN ids on saxs.id = ids.id "+
+		"SELECT traces.id FROM traces "+
+			"INNER JOIN ids on traces.id = ids.id "+
 			"WHERE ids.sys_id = %d", key)
 
 	rows, err := runQ(q)
@@ -2250,177 +3629,332 @@ func ReadSystemSaxs(key int) ([]addie.Sax, error) {
 	}
 
 	for rows.Next() {
-		var sax_key int
-		err := rows.Scan(&sax_key)
+		var trc_key int
+		err := rows.Scan(&trc_key)
 		if err != nil {
 			return nil, scanFailure(err)
 		}
 
-		sax, err := ReadSaxByKey(sax_key)
+		trc, err := ReadTraceByKey(trc_key)
 		if err != nil {
 			return nil, readFailure(err)
 		}
-		result = append(result, *sax)
+		result = append(result, *trc)
 	}
 
 	return result, nil
 
 }
 
-// Plinks ----------------------------------------------------------------------------
+// Templates ------------------------------------------------------------------------
 
-func CreatePlink(p addie.Plink, owner string) error {
+/*
+SaveTemplate creates a template or replaces the saved one of the same name,
+every save increments the version of the template which is returned
+*/
+func SaveTemplate(t templates.Template, owner string) (int, error) {
 
-	key, err := CreateId(p.Id, owner)
+	user_key, err := ReadUserKey(owner)
 	if err != nil {
-		return createFailure(err)
+		return -1, readFailure(err)
 	}
 
-	epa_key, err := ReadIdKey(p.Endpoints[0], owner)
+	params, err := json.Marshal(t.Params)
 	if err != nil {
-		return readFailure(err)
+		return -1, createFailure(err)
 	}
-
-	epb_key, err := ReadIdKey(p.Endpoints[1], owner)
+	elements, err := json.Marshal(t.Elements)
 	if err != nil {
-		return readFailure(err)
+		return -1, createFailure(err)
 	}
 
-	q := fmt.Sprintf("INSERT INTO plinks "+
-		"(id, endpoint_a_id, endpoint_b_id, epa_bindings, epb_bindings) "+
-		"VALUES (%d, %d, %d, '%s', '%s')",
-		key, epa_key, epb_key, p.Bindings[0], p.Bindings[1])
+	q := fmt.Sprintf(
+		"UPDATE design_templates SET params = '%s', elements = '%s', "+
+			"version = version + 1 WHERE owner = %d AND name = '%s' RETURNING version",
+		pgMathStr(string(params)), pgMathStr(string(elements)), user_key, t.Name)
+
+	version, err := getKey(q)
+	if err == nil {
+		return version, nil
+	}
+
+	q = fmt.Sprintf(
+		"INSERT INTO design_templates (owner, name, params, elements, version) "+
+			"VALUES (%d, '%s', '%s', '%s', 1)",
+		user_key, t.Name, pgMathStr(string(params)), pgMathStr(string(elements)))
 
 	err = runC(q)
 	if err != nil {
-		return insertFailure(err)
+		return -1, insertFailure(err)
 	}
 
-	return nil
+	return 1, nil
 
 }
 
-func ReadPlinkByKey(key int) (*addie.Plink, error) {
+func scanTemplate(rows *sql.Rows) (*templates.Template, error) {
 
-	id, err := ReadId(key)
+	t := new(templates.Template)
+	var params, elements string
+	err := rows.Scan(&t.Name, &params, &elements, &t.Version)
 	if err != nil {
-		return nil, readFailure(err)
+		return nil, scanFailure(err)
 	}
-
-	q := fmt.Sprintf(
-		"SELECT endpoint_a_id, endpoint_b_id, epa_bindings, epb_bindings "+
-			"FROM plinks WHERE id = %d", key)
-
-	rows, err := runQ(q)
-	defer safeClose(rows)
+	err = json.Unmarshal([]byte(params), &t.Params)
 	if err != nil {
-		return nil, selectFailure(err)
-	}
-	if !rows.Next() {
-		return nil, emptyReadFailure()
+		return nil, readFailure(err)
 	}
-
-	var epa_key, epb_key int
-	var epa_bind, epb_bind string
-	err = rows.Scan(&epa_key, &epb_key, &epa_bind, &epb_bind)
+	err = json.Unmarshal([]byte(elements), &t.Elements)
 	if err != nil {
-		return nil, scanFailure(err)
+		return nil, readFailure(err)
 	}
-	rows.Close()
 
-	epa, err := ReadId(epa_key)
+	return t, nil
+
+}
+
+func ReadTemplates(owner string) ([]templates.Template, error) {
+
+	user_key, err := ReadUserKey(owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	epb, err := ReadId(epb_key)
+	q := fmt.Sprintf(
+		"SELECT name, params, elements, version FROM design_templates "+
+			"WHERE owner = %d ORDER BY name", user_key)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
 	if err != nil {
-		return nil, readFailure(err)
+		return nil, selectFailure(err)
 	}
 
-	plink := addie.Plink{}
-	plink.Id = *id
-	plink.Endpoints[0] = *epa
-	plink.Endpoints[1] = *epb
-	plink.Bindings[0] = epa_bind
-	plink.Bindings[1] = epb_bind
+	var result []templates.Template
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *t)
+	}
 
-	return &plink, nil
+	return result, nil
 
 }
 
-func ReadPlink(id addie.Id, p addie.Plink, owner string) (*addie.Plink, error) {
+func ReadTemplate(name, owner string) (*templates.Template, error) {
 
-	key, err := ReadIdKey(id, owner)
+	user_key, err := ReadUserKey(owner)
 	if err != nil {
 		return nil, readFailure(err)
 	}
 
-	return ReadPlinkByKey(key)
+	q := fmt.Sprintf(
+		"SELECT name, params, elements, version FROM design_templates "+
+			"WHERE owner = %d AND name = '%s'", user_key, name)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+	if !rows.Next() {
+		return nil, emptyReadFailure()
+	}
+
+	return scanTemplate(rows)
 
 }
 
-func UpdatePlink(oid addie.Id, p addie.Plink, owner string) (int, error) {
+/*
+SaveTemplateInstance records an instance of a template in a design, an
+instance with an id is updated in place
+*/
+func SaveTemplateInstance(inst templates.Instance, design,
+	owner string) (int, error) {
 
-	key, err := UpdateId(oid, p.Id, owner)
+	design_key, err := ReadDesignKey(design, owner)
 	if err != nil {
-		return -1, updateFailure(err)
+		return -1, readFailure(err)
 	}
 
-	ep0, err := ReadIdKey(p.Endpoints[0], owner)
+	params, err := json.Marshal(inst.Params)
 	if err != nil {
-		return key, readFailure(err)
+		return -1, createFailure(err)
 	}
-
-	ep1, err := ReadIdKey(p.Endpoints[1], owner)
+	elements, err := json.Marshal(inst.Elements)
 	if err != nil {
-		return key, readFailure(err)
+		return -1, createFailure(err)
 	}
 
-	q := fmt.Sprintf("UPDATE plinks SET "+
-		"endpoint_a_id = %d, endpoint_b_id = %d, "+
-		"epa_bindings = '%s', epb_bindings = '%s' "+
-		"WHERE id = %d", ep0, ep1, p.Bindings[0], p.Bindings[1], key)
+	if inst.Id > 0 {
+		q := fmt.Sprintf(
+			"UPDATE template_instances SET version = %d, sys = '%s', params = '%s', "+
+				"elements = '%s' WHERE id = %d AND design_id = %d",
+			inst.Version, inst.Sys, pgMathStr(string(params)), pgMathStr(string(elements)),
+			inst.Id, design_key)
+		err = runC(q)
+		if err != nil {
+			return -1, updateFailure(err)
+		}
+		return inst.Id, nil
+	}
 
-	err = runC(q)
+	q := fmt.Sprintf(
+		"INSERT INTO template_instances "+
+			"(design_id, template, version, idx, pattern, sys, params, elements) "+
+			"VALUES (%d, '%s', %d, %d, '%s', '%s', '%s', '%s') RETURNING id",
+		design_key, inst.Template, inst.Version, inst.Index, inst.Pattern, inst.Sys,
+		pgMathStr(string(params)), pgMathStr(string(elements)))
+
+	key, err := getKey(q)
 	if err != nil {
-		return key, updateFailure(err)
+		return -1, insertFailure(err)
 	}
 
 	return key, nil
 
 }
 
-func ReadSystemPlinks(key int) ([]addie.Plink, error) {
+/*
+ReadTemplateInstances reads the instances of templates in a design
+*/
+func ReadTemplateInstances(design, owner string) ([]templates.Instance, error) {
 
-	var result []addie.Plink
+	design_key, err := ReadDesignKey(design, owner)
+	if err != nil {
+		return nil, readFailure(err)
+	}
 
 	q := fmt.Sprintf(
-		"SELECT plinks.id FROM plinks "+
-			"INNER JOIN ids on plinks.id = ids.id "+
-			"WHERE ids.sys_id = %d", key)
+		"SELECT id, template, version, idx, pattern, sys, params, elements "+
+			"FROM template_instances WHERE design_id = %d ORDER BY template, idx",
+		design_key)
 
 	rows, err := runQ(q)
 	defer safeClose(rows)
-
 	if err != nil {
 		return nil, selectFailure(err)
 	}
 
+	var result []templates.Instance
 	for rows.Next() {
-		var plnk_key int
-		err := rows.Scan(&plnk_key)
+		var inst templates.Instance
+		var params, elements string
+		err := rows.Scan(&inst.Id, &inst.Template, &inst.Version, &inst.Index,
+			&inst.Pattern, &inst.Sys, &params, &elements)
 		if err != nil {
 			return nil, scanFailure(err)
 		}
-
-		plnk, err := ReadPlinkByKey(plnk_key)
+		err = json.Unmarshal([]byte(params), &inst.Params)
 		if err != nil {
 			return nil, readFailure(err)
 		}
-		result = append(result, *plnk)
+		err = json.Unmarshal([]byte(elements), &inst.Elements)
+		if err != nil {
+			return nil, readFailure(err)
+		}
+		result = append(result, inst)
+	}
+
+	return result, nil
+
+}
+
+// Node Profiles --------------------------------------------------------------
+
+/*
+IsAdmin reports whether a user may manage the testbed catalogs
+*/
+func IsAdmin(user string) (bool, error) {
+
+	q := fmt.Sprintf("SELECT admin FROM users WHERE name = '%s'", user)
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return false, selectFailure(err)
+	}
+	if !rows.Next() {
+		return false, emptyReadFailure()
+	}
+
+	var admin sql.NullBool
+	err = rows.Scan(&admin)
+	if err != nil {
+		return false, scanFailure(err)
+	}
+
+	return admin.Bool, nil
+
+}
+
+func ReadNodeProfiles() ([]addie.NodeProfile, error) {
+
+	q := "SELECT name, hardware, osid, container, startup FROM node_profiles " +
+		"ORDER BY name"
+
+	rows, err := runQ(q)
+	defer safeClose(rows)
+	if err != nil {
+		return nil, selectFailure(err)
+	}
+
+	var result []addie.NodeProfile
+	for rows.Next() {
+		var p addie.NodeProfile
+		err = rows.Scan(&p.Name, &p.Hardware, &p.OSID, &p.Container, &p.Startup)
+		if err != nil {
+			return nil, scanFailure(err)
+		}
+		result = append(result, p)
 	}
 
 	return result, nil
 
 }
+
+/*
+SaveNodeProfile updates a node profile, creating it if it does not exist yet
+*/
+func SaveNodeProfile(p addie.NodeProfile) error {
+
+	q := fmt.Sprintf(
+		"UPDATE node_profiles SET hardware = '%s', osid = '%s', container = '%s', "+
+			"startup = '%s' WHERE name = '%s' RETURNING id",
+		pgMathStr(p.Hardware), pgMathStr(p.OSID), pgMathStr(p.Container),
+		pgMathStr(p.Startup), p.Name)
+
+	_, err := getKey(q)
+	if err == nil {
+		return nil
+	}
+
+	q = fmt.Sprintf(
+		"INSERT INTO node_profiles (name, hardware, osid, container, startup) "+
+			"VALUES ('%s', '%s', '%s', '%s', '%s')",
+		p.Name, pgMathStr(p.Hardware), pgMathStr(p.OSID), pgMathStr(p.Container),
+		pgMathStr(p.Startup))
+
+	err = runC(q)
+	if err != nil {
+		return insertFailure(err)
+	}
+
+	return nil
+
+}
+
+func DeleteNodeProfile(name string) error {
+
+	q := fmt.Sprintf("DELETE FROM node_profiles WHERE name = '%s'", name)
+
+	err := runC(q)
+	if err != nil {
+		return deleteFailure(err)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,45 @@
+package deter
+
+import (
+	"addie"
+	"testing"
+)
+
+/*
+Like TestImportTopDL this only builds with spi_test.go set aside
+*/
+func TestNodeAttributes(t *testing.T) {
+
+	values := func(attrs addie.Attributes) map[string][]string {
+		result := make(map[string][]string)
+		for _, a := range nodeAttributes(attrs) {
+			result[a.Attribute] = append(result[a.Attribute], a.Value)
+		}
+		return result
+	}
+
+	//nodes without attributes get the defaults
+	vs := values(nil)
+	for k, a := range TopDLAttributes {
+		if len(vs[a]) != 1 || vs[a][0] != defaultAttributes[k] {
+			t.Fatalf("bad default %s %v", a, vs[a])
+		}
+	}
+
+	//attributes override the defaults and topdl: ones pass through
+	vs = values(addie.Attributes{"hardware": "pc3000", "topdl:testbed": "deter",
+		"rack": "b2"})
+	if len(vs["type"]) != 1 || vs["type"][0] != "pc3000" ||
+		len(vs["testbed"]) != 1 || vs["testbed"][0] != "deter" || len(vs["rack"]) != 0 {
+		t.Fatalf("bad attributes %v", vs)
+	}
+
+	//a topdl: attribute naming a mapped one replaces it rather than doubling it
+	vs = values(addie.Attributes{"hardware": "pc3000", "topdl:type": "pc2133",
+		"topdl:osid": "custom-image"})
+	if len(vs["type"]) != 1 || vs["type"][0] != "pc2133" ||
+		len(vs["osid"]) != 1 || vs["osid"][0] != "custom-image" {
+		t.Fatalf("bad attributes %v", vs)
+	}
+
+}
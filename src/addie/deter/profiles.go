@@ -0,0 +1,97 @@
+/*
+This file contains the code that applies the node profiles of the testbed
+catalog to the nodes of a design
+*/
+package deter
+
+import (
+	"addie"
+)
+
+/*
+profileAttributes returns the attributes of a node with those of its profile
+filled in, attributes the node sets itself take precedence
+*/
+func profileAttributes(attrs addie.Attributes,
+	profiles map[string]addie.NodeProfile) (addie.Attributes, addie.NodeProfile, bool) {
+
+	p, ok := profiles[attrs[addie.ProfileAttribute]]
+	if !ok {
+		return attrs, p, false
+	}
+
+	result := make(addie.Attributes)
+	for k, v := range map[string]string{
+		"hardware":  p.Hardware,
+		"osid":      p.OSID,
+		"container": p.Container,
+		"startup":   p.Startup,
+	} {
+		if v != "" {
+			result[k] = v
+		}
+	}
+	for k, v := range attrs {
+		result[k] = v
+	}
+
+	return result, p, true
+
+}
+
+/*
+ApplyProfiles returns a copy of a design whose computers, routers and saxes
+carry the attributes of the node profile they name. A computer without an OS
+runs the image of its profile, a computer whose OS is the image of a profile
+runs that image unless its osid attribute says otherwise. Other OSes are not
+images, those computers run the default osid. Nodes naming a profile that is
+not in profiles are left as they are, sema.CheckProfiles reports them.
+*/
+func ApplyProfiles(dsg *addie.Design, profiles []addie.NodeProfile) *addie.Design {
+
+	catalog := make(map[string]addie.NodeProfile)
+	images := make(map[string]bool)
+	for _, p := range profiles {
+		catalog[p.Name] = p
+		if p.OSID != "" {
+			images[p.OSID] = true
+		}
+	}
+
+	result := addie.EmptyDesign(dsg.Name)
+	result.Params = dsg.Params
+
+	for id, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Computer:
+			c := e.(addie.Computer)
+			var p addie.NodeProfile
+			var ok bool
+			c.Attributes, p, ok = profileAttributes(c.Attributes, catalog)
+			if ok && c.OS == "" {
+				c.OS = p.OSID
+			}
+			if _, set := c.Attributes["osid"]; !set && images[c.OS] {
+				attrs := make(addie.Attributes)
+				for k, v := range c.Attributes {
+					attrs[k] = v
+				}
+				attrs["osid"] = c.OS
+				c.Attributes = attrs
+			}
+			e = c
+		case addie.Router:
+			r := e.(addie.Router)
+			r.Attributes, _, _ = profileAttributes(r.Attributes, catalog)
+			e = r
+		case addie.Sax:
+			s := e.(addie.Sax)
+			s.Attributes, _, _ = profileAttributes(s.Attributes, catalog)
+			e = s
+		}
+		result.Elements[id] = e
+	}
+
+	return &result
+
+}
@@ -0,0 +1,69 @@
+package deter
+
+import (
+	"addie"
+	"testing"
+)
+
+func osid(c *addie.Computer) string {
+
+	for _, a := range compComp(c).Attributes {
+		if a.Attribute == "osid" {
+			return a.Value
+		}
+	}
+	return ""
+
+}
+
+/*
+Like TestImportTopDL this only builds with spi_test.go set aside
+*/
+func TestComputerImage(t *testing.T) {
+
+	dsg := addie.EmptyDesign("glenfarclas")
+	c := addie.Computer{}
+	c.Id = addie.Id{Name: "hmi", Sys: "root", Design: "glenfarclas"}
+	c.OS = "Ubuntu1604-64-STD"
+	dsg.Elements[c.Id] = c
+	profiles := []addie.NodeProfile{{Name: "hmi", OSID: "Ubuntu1804-64-STD"},
+		{Name: "historian", OSID: "Ubuntu1604-64-STD"}}
+
+	//an OS the catalog has as an image is the image
+	pc := ApplyProfiles(&dsg, profiles).Elements[c.Id].(addie.Computer)
+	if osid(&pc) != "Ubuntu1604-64-STD" {
+		t.Fatalf("bad osid %s", osid(&pc))
+	}
+
+	//other OSes and designs without a catalog keep the default image
+	c.OS = "Ubuntu-15.04"
+	dsg.Elements[c.Id] = c
+	pc = ApplyProfiles(&dsg, profiles).Elements[c.Id].(addie.Computer)
+	if osid(&pc) != defaultAttributes["osid"] {
+		t.Fatalf("an OS that is not an image became the osid %s", osid(&pc))
+	}
+	c.OS = "Ubuntu1604-64-STD"
+	dsg.Elements[c.Id] = c
+	pc = ApplyProfiles(&dsg, nil).Elements[c.Id].(addie.Computer)
+	if osid(&pc) != defaultAttributes["osid"] {
+		t.Fatalf("an OS became the osid without a catalog %s", osid(&pc))
+	}
+
+	//an osid attribute says otherwise
+	c.Attributes = addie.Attributes{"osid": "custom-image"}
+	dsg.Elements[c.Id] = c
+	pc = ApplyProfiles(&dsg, profiles).Elements[c.Id].(addie.Computer)
+	if osid(&pc) != "custom-image" {
+		t.Fatalf("bad osid %s", osid(&pc))
+	}
+
+	//a computer without an OS runs the image of its profile
+	c.OS = ""
+	c.Attributes = addie.Attributes{addie.ProfileAttribute: "hmi"}
+	dsg.Elements[c.Id] = c
+	pc = ApplyProfiles(&dsg, profiles).Elements[c.Id].(addie.Computer)
+	if pc.OS != "Ubuntu1804-64-STD" || osid(&pc) != "Ubuntu1804-64-STD" {
+		t.Fatalf("bad profile image %s %s", pc.OS, osid(&pc))
+	}
+
+}
@@ -2,36 +2,83 @@ package deter
 
 import (
 	"addie"
+	"addie/param"
 	"encoding/xml"
 	"fmt"
 	"github.com/deter-project/go-spi/spi"
 	"log"
 	"reflect"
+	"strings"
 )
 
+/*
+TopDLAttributes maps the element attributes that are carried into the TopDL of
+computers, routers and saxes to the TopDL attributes they set. The hardware
+attribute is the testbed hardware type of the node, container its container
+template, osid its operating system image and startup the script it runs when
+it boots. Nodes without these attributes get the Cypress defaults. Any other
+attribute named topdl:<name> is passed through as the TopDL attribute <name>,
+the rest stay in addie. A topdl:<name> naming one of the TopDL attributes above
+replaces its value, so no node has an attribute twice.
+*/
+var TopDLAttributes = map[string]string{
+	"hardware":  "type",
+	"container": "containers:openvz_template",
+	"osid":      "osid",
+	"startup":   "startup",
+}
+
+var topdlOrder = []string{"hardware", "container", "osid", "startup"}
+
+var defaultAttributes = addie.Attributes{
+	"hardware":  "dl380g3",
+	"container": "ubuntu-14.04-x86_64",
+	"osid":      "Ubuntu1404-64-STD",
+	"startup":   "/proj/cypress/scripts/cyinit.sh",
+}
+
+/*
+nodeAttributes returns the TopDL attributes of a node with the element
+attributes attrs
+*/
+func nodeAttributes(attrs addie.Attributes) []spi.TopDLAttribute {
+
+	var result []spi.TopDLAttribute
+
+	for _, k := range topdlOrder {
+		v, ok := attrs["topdl:"+TopDLAttributes[k]]
+		if !ok {
+			v, ok = attrs[k]
+		}
+		if !ok {
+			v = defaultAttributes[k]
+		}
+		result = append(result,
+			spi.TopDLAttribute{Attribute: TopDLAttributes[k], Value: v})
+	}
+
+	mapped := make(map[string]bool)
+	for _, a := range TopDLAttributes {
+		mapped[a] = true
+	}
+	for _, k := range attrs.Keys() {
+		name := strings.TrimPrefix(k, "topdl:")
+		if strings.HasPrefix(k, "topdl:") && name != "" && !mapped[name] {
+			result = append(result,
+				spi.TopDLAttribute{Attribute: name, Value: attrs[k]})
+		}
+	}
+
+	return result
+
+}
+
 func compComp(c *addie.Computer) spi.Computer {
 
 	var _c spi.Computer
 	_c.Name = c.Name
 	_c.OSs = []spi.OS{spi.OS{Name: c.OS, Version: ""}}
-	_c.Attributes = []spi.TopDLAttribute{
-		spi.TopDLAttribute{
-			Attribute: "type",
-			Value:     "dl380g3",
-		},
-		spi.TopDLAttribute{
-			Attribute: "containers:openvz_template",
-			Value:     "ubuntu-14.04-x86_64",
-		},
-		spi.TopDLAttribute{
-			Attribute: "osid",
-			Value:     "Ubuntu1404-64-STD",
-		},
-		spi.TopDLAttribute{
-			Attribute: "startup",
-			Value:     "/proj/cypress/scripts/cyinit.sh",
-		},
-	}
+	_c.Attributes = nodeAttributes(c.Attributes)
 
 	for _, i := range c.Interfaces {
 		_c.Interfaces = append(_c.Interfaces,
@@ -56,24 +103,7 @@ func simComp() spi.Computer {
 	c.Name = fmt.Sprintf("kry%d", kryCount)
 	kryCount++
 	c.OSs = []spi.OS{spi.OS{Name: "Ubuntu1404-64-STD", Version: ""}}
-	c.Attributes = []spi.TopDLAttribute{
-		spi.TopDLAttribute{
-			Attribute: "type",
-			Value:     "dl380g3",
-		},
-		spi.TopDLAttribute{
-			Attribute: "containers:openvz_template",
-			Value:     "ubuntu-14.04-x86_64",
-		},
-		spi.TopDLAttribute{
-			Attribute: "osid",
-			Value:     "Ubuntu1404-64-STD",
-		},
-		spi.TopDLAttribute{
-			Attribute: "startup",
-			Value:     "/proj/cypress/scripts/cyinit.sh",
-		},
-	}
+	c.Attributes = nodeAttributes(nil)
 
 	c.Interfaces = append(c.Interfaces,
 		spi.Interface{
@@ -93,24 +123,7 @@ func saxComp(s *addie.Sax) spi.Computer {
 	var c spi.Computer
 	c.Name = s.Name
 	c.OSs = []spi.OS{spi.OS{Name: "Ubuntu1404-64-STD", Version: ""}}
-	c.Attributes = []spi.TopDLAttribute{
-		spi.TopDLAttribute{
-			Attribute: "type",
-			Value:     "dl380g3",
-		},
-		spi.TopDLAttribute{
-			Attribute: "containers:openvz_template",
-			Value:     "ubuntu-14.04-x86_64",
-		},
-		spi.TopDLAttribute{
-			Attribute: "osid",
-			Value:     "Ubuntu1404-64-STD",
-		},
-		spi.TopDLAttribute{
-			Attribute: "startup",
-			Value:     "/proj/cypress/scripts/cyinit.sh",
-		},
-	}
+	c.Attributes = nodeAttributes(s.Attributes)
 
 	c.Interfaces = append(c.Interfaces,
 		spi.Interface{
@@ -141,24 +154,7 @@ func rtrComp(r *addie.Router) spi.Computer {
 	var c spi.Computer
 	c.Name = r.Name
 	c.OSs = []spi.OS{spi.OS{Name: "Ubuntu1404-64-STD", Version: "Router"}}
-	c.Attributes = []spi.TopDLAttribute{
-		spi.TopDLAttribute{
-			Attribute: "type",
-			Value:     "dl380g3",
-		},
-		spi.TopDLAttribute{
-			Attribute: "containers:openvz_template",
-			Value:     "ubuntu-14.04-x86_64",
-		},
-		spi.TopDLAttribute{
-			Attribute: "osid",
-			Value:     "Ubuntu1404-64-STD",
-		},
-		spi.TopDLAttribute{
-			Attribute: "startup",
-			Value:     "/proj/cypress/scripts/cyinit.sh",
-		},
-	}
+	c.Attributes = nodeAttributes(r.Attributes)
 
 	for _, i := range r.Interfaces {
 		c.Interfaces = append(c.Interfaces,
@@ -180,24 +176,7 @@ func dnsComp(name string) spi.Computer {
 	var c spi.Computer
 	c.Name = name
 	c.OSs = []spi.OS{spi.OS{Name: "Ubuntu1404-64-STD", Version: "DNS"}}
-	c.Attributes = []spi.TopDLAttribute{
-		spi.TopDLAttribute{
-			Attribute: "type",
-			Value:     "dl380g3",
-		},
-		spi.TopDLAttribute{
-			Attribute: "containers:openvz_template",
-			Value:     "ubuntu-14.04-x86_64",
-		},
-		spi.TopDLAttribute{
-			Attribute: "osid",
-			Value:     "Ubuntu1404-64-STD",
-		},
-		spi.TopDLAttribute{
-			Attribute: "startup",
-			Value:     "/proj/cypress/scripts/cyinit.sh",
-		},
-	}
+	c.Attributes = nodeAttributes(nil)
 
 	c.Interfaces = append(c.Interfaces,
 		spi.Interface{
@@ -333,8 +312,29 @@ func linkSubstrate(link *addie.Link, dsg *addie.Design,
 
 func DesignTopDL(dsg *addie.Design) spi.Experiment {
 
+	return DesignClusterTopDL(dsg, 1)
+
+}
+
+/*
+DesignClusterTopDL builds the TopDL for a design whose simulation is split
+across kryNodes simulation nodes. Every kry node is attached to the krynet
+substrate shared with the saxs. The capacities and latencies that refer to
+design parameters are resolved first.
+*/
+func DesignClusterTopDL(dsg *addie.Design, kryNodes int) spi.Experiment {
+
+	if kryNodes < 1 {
+		kryNodes = 1
+	}
+
 	kryCount = 0
 
+	dsg, errs := param.Resolve(dsg)
+	for _, err := range errs {
+		log.Println(err)
+	}
+
 	var xp spi.Experiment
 
 	cMap := make(map[addie.Id]*spi.Computer)
@@ -382,7 +382,9 @@ func DesignTopDL(dsg *addie.Design) spi.Experiment {
 
 	}
 
-	xp.Elements.Elements = append(xp.Elements.Elements, simComp())
+	for i := 0; i < kryNodes; i++ {
+		xp.Elements.Elements = append(xp.Elements.Elements, simComp())
+	}
 	xp.Substrates = append(xp.Substrates, krySubstrate())
 
 	xp.Elements.Elements = append(xp.Elements.Elements, dnsComp("dns"))
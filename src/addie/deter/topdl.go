@@ -0,0 +1,218 @@
+/*
+This file contains the code that imports TopDL experiments into a design
+*/
+package deter
+
+import (
+	"addie"
+	"encoding/xml"
+	"fmt"
+	"github.com/deter-project/go-spi/spi"
+	"strconv"
+	"strings"
+)
+
+/*
+spi.Experiment holds its elements as interfaces which encoding/xml can not
+unmarshal into, so experiments are read through these. Computers appear
+directly under elements as DesignTopDL writes them, or wrapped in an element
+as the testbed writes them.
+*/
+type topdlComputer struct {
+	Name       string               `xml:"name"`
+	Interfaces []spi.Interface      `xml:"interface"`
+	OSs        []spi.OS             `xml:"os"`
+	Attributes []spi.TopDLAttribute `xml:"attribute"`
+}
+
+type topdlExperiment struct {
+	XMLName    xml.Name        `xml:"experiment"`
+	Substrates []spi.Substrate `xml:"substrates"`
+	Computers  []topdlComputer `xml:"elements>computer"`
+	Wrapped    []topdlComputer `xml:"elements>element>computer"`
+}
+
+/*
+isSynthetic tells whether a computer is one of the simulation or DNS nodes that
+DesignClusterTopDL adds to every experiment
+*/
+func isSynthetic(c *topdlComputer) bool {
+
+	for _, os := range c.OSs {
+		if os.Version == "DNS" {
+			return true
+		}
+	}
+	if strings.HasPrefix(c.Name, "kry") {
+		_, err := strconv.Atoi(strings.TrimPrefix(c.Name, "kry"))
+		return err == nil
+	}
+	return false
+
+}
+
+func isSyntheticSubstrate(name string) bool {
+	return name == "krynet" || name == "dnslink"
+}
+
+func isRouter(c *topdlComputer) bool {
+
+	for _, os := range c.OSs {
+		if os.Version == "Router" {
+			return true
+		}
+	}
+	return false
+
+}
+
+func conductor(capacity spi.Capacity, latency spi.Latency) addie.PacketConductor {
+	return addie.PacketConductor{
+		Capacity: int(capacity.Rate / 1000),
+		Latency:  int(latency.Time),
+	}
+}
+
+/*
+importAttributes is the inverse of nodeAttributes. Attributes holding the
+Cypress defaults are dropped, TopDL attributes addie has no name for are kept
+as topdl:<name>.
+*/
+func importAttributes(attrs []spi.TopDLAttribute) addie.Attributes {
+
+	names := make(map[string]string)
+	for k, v := range TopDLAttributes {
+		names[v] = k
+	}
+
+	result := make(addie.Attributes)
+	for _, a := range attrs {
+		k, ok := names[a.Attribute]
+		if !ok {
+			result["topdl:"+a.Attribute] = a.Value
+			continue
+		}
+		if a.Value != defaultAttributes[k] {
+			result[k] = a.Value
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+
+}
+
+/*
+ImportTopDL reads the TopDL experiment src into elements of the system sys of
+the design dsg. Computers become Computers, or Routers when their OS version
+is Router. Substrates joining two interfaces become Links, the others become
+Switches with a Link to every interface on them. Capacities in TopDL are in
+kbps and come back in Mbps. The kry simulation nodes, the DNS node and the
+substrates that connect them are skipped. The elements have no positions, the
+layout package can arrange them.
+*/
+func ImportTopDL(src []byte, dsg, sys string) ([]addie.Identify, error) {
+
+	var xp topdlExperiment
+	err := xml.Unmarshal(src, &xp)
+	if err != nil {
+		return nil, fmt.Errorf("[ImportTopDL] bad topdl xml: %v", err)
+	}
+
+	id := func(name string) addie.Id {
+		return addie.Id{Name: name, Sys: sys, Design: dsg}
+	}
+
+	names := make(map[string]bool)
+	var claim = func(name string) error {
+		if name == "" {
+			return fmt.Errorf("[ImportTopDL] an element has no name")
+		}
+		if names[name] {
+			return fmt.Errorf("[ImportTopDL] more than one element is named %s", name)
+		}
+		names[name] = true
+		return nil
+	}
+
+	//the interfaces on each substrate in the order they appear
+	attached := make(map[string][]addie.NetIfRef)
+	var nodes []addie.Identify
+
+	for _, c := range append(xp.Computers, xp.Wrapped...) {
+		if isSynthetic(&c) {
+			continue
+		}
+		err = claim(c.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		h := addie.NetHost{Id: id(c.Name),
+			Interfaces: make(map[string]addie.Interface),
+			Attributes: importAttributes(c.Attributes)}
+		for _, i := range c.Interfaces {
+			if isSyntheticSubstrate(i.Substrate) {
+				continue
+			}
+			h.Interfaces[i.Name] = addie.Interface{Name: i.Name,
+				PacketConductor: conductor(i.Capacity, i.Latency)}
+			attached[i.Substrate] = append(attached[i.Substrate],
+				addie.NetIfRef{Id: h.Id, IfName: i.Name})
+		}
+
+		if isRouter(&c) {
+			nodes = append(nodes, addie.Router{NetHost: h})
+			continue
+		}
+		comp := addie.Computer{NetHost: h}
+		if len(c.OSs) > 0 {
+			comp.OS = c.OSs[0].Name
+		}
+		nodes = append(nodes, comp)
+	}
+
+	var links []addie.Identify
+
+	for _, s := range xp.Substrates {
+		if isSyntheticSubstrate(s.Name) {
+			continue
+		}
+		err = claim(s.Name)
+		if err != nil {
+			return nil, err
+		}
+		pc := conductor(s.Capacity, s.Latency)
+		ends := attached[s.Name]
+
+		if len(ends) == 2 {
+			l := addie.Link{Id: id(s.Name), PacketConductor: pc}
+			l.Endpoints = [2]addie.NetIfRef{ends[0], ends[1]}
+			links = append(links, l)
+			continue
+		}
+
+		sw := addie.Switch{PacketConductor: pc}
+		sw.NetHost = addie.NetHost{Id: id(s.Name),
+			Interfaces: make(map[string]addie.Interface)}
+		for i, end := range ends {
+			ifname := fmt.Sprintf("eth%d", i)
+			sw.Interfaces[ifname] = addie.Interface{Name: ifname, PacketConductor: pc}
+
+			name := fmt.Sprintf("%s-l%d", s.Name, i)
+			err = claim(name)
+			if err != nil {
+				return nil, err
+			}
+			l := addie.Link{Id: id(name), PacketConductor: pc}
+			l.Endpoints = [2]addie.NetIfRef{end, addie.NetIfRef{Id: sw.Id, IfName: ifname}}
+			links = append(links, l)
+		}
+		nodes = append(nodes, sw)
+	}
+
+	return append(nodes, links...), nil
+
+}
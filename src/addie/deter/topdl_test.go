@@ -0,0 +1,112 @@
+package deter
+
+import (
+	"addie"
+	"addie/topo"
+	"encoding/xml"
+	"testing"
+)
+
+/*
+These tests do not build in this tree while spi_test.go imports the database
+package from its old github.com/cycps/addie/db path, set it aside to run them
+*/
+func TestImportTopDL(t *testing.T) {
+
+	dsg := addie.EmptyDesign("oban")
+	es, err := topo.Generate(
+		topo.Spec{Shape: "star", Nodes: 3, Capacity: 100, Latency: 3}, "oban", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es {
+		dsg.Elements[e.Identify()] = e
+	}
+
+	id := func(n string) addie.Id { return addie.Id{Name: n, Sys: "root", Design: "oban"} }
+	c := dsg.Elements[id("star-c0")].(addie.Computer)
+	c.OS = "Ubuntu1604-STD"
+	c.Attributes = addie.Attributes{"hardware": "pc3000", "topdl:color": "red"}
+	c.Interfaces["eth1"] = addie.Interface{Name: "eth1",
+		PacketConductor: addie.PacketConductor{Capacity: 10, Latency: 20}}
+	dsg.Elements[c.Id] = c
+
+	r := addie.Router{}
+	r.NetHost = addie.NetHost{Id: id("gw"), Interfaces: map[string]addie.Interface{
+		"eth0": addie.Interface{Name: "eth0",
+			PacketConductor: addie.PacketConductor{Capacity: 10, Latency: 20}}}}
+	dsg.Elements[r.Id] = r
+
+	l := addie.Link{Id: id("uplink"),
+		PacketConductor: addie.PacketConductor{Capacity: 10, Latency: 20}}
+	l.Endpoints = [2]addie.NetIfRef{{Id: c.Id, IfName: "eth1"}, {Id: r.Id, IfName: "eth0"}}
+	dsg.Elements[l.Id] = l
+
+	src, err := xml.Marshal(DesignClusterTopDL(&dsg, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elements, err := ImportTopDL(src, "oban", "imported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := make(map[string]addie.Identify)
+	for _, e := range elements {
+		imported[e.Identify().Name] = e
+		if e.Identify().Sys != "imported" {
+			t.Fatalf("%s imported into the wrong system", e.Identify())
+		}
+	}
+
+	for _, skipped := range []string{"kry0", "kry1", "dns", "krynet", "dnslink"} {
+		if _, ok := imported[skipped]; ok {
+			t.Fatalf("synthetic %s was imported", skipped)
+		}
+	}
+
+	c0, ok := imported["star-c0"].(addie.Computer)
+	if !ok {
+		t.Fatalf("star-c0 is not a computer %#v", imported["star-c0"])
+	}
+	if c0.OS != "Ubuntu1604-STD" || len(c0.Interfaces) != 2 ||
+		c0.Interfaces["eth1"].Capacity != 10 || c0.Interfaces["eth1"].Latency != 20 {
+		t.Fatalf("bad star-c0 %#v", c0)
+	}
+	if !c0.Attributes.Equals(addie.Attributes{"hardware": "pc3000", "topdl:color": "red"}) {
+		t.Fatalf("bad star-c0 attributes %v", c0.Attributes)
+	}
+
+	//the first router is also on the dns link, that interface is dropped
+	gw, ok := imported["gw"].(addie.Router)
+	if !ok || len(gw.Interfaces) != 1 || gw.Attributes != nil {
+		t.Fatalf("bad gw %#v", imported["gw"])
+	}
+
+	//the endpoints come in the order the computers appear
+	up, ok := imported["uplink"].(addie.Link)
+	ends := map[string]bool{up.Endpoints[0].Name: true, up.Endpoints[1].Name: true}
+	if !ok || up.Capacity != 10 || up.Latency != 20 || !ends["star-c0"] || !ends["gw"] {
+		t.Fatalf("bad uplink %#v", imported["uplink"])
+	}
+
+	sw, ok := imported["star-sw"].(addie.Switch)
+	if !ok || sw.Capacity != 100 || sw.Latency != 3 || len(sw.Interfaces) != 3 {
+		t.Fatalf("bad star-sw %#v", imported["star-sw"])
+	}
+	links := 0
+	for _, e := range elements {
+		if l, ok := e.(addie.Link); ok && l.Endpoints[1].Id == sw.Id {
+			links++
+		}
+	}
+	if links != 3 {
+		t.Fatalf("expected 3 links to star-sw got %d", links)
+	}
+
+	_, err = ImportTopDL([]byte("<experiment><elements>"), "oban", "root")
+	if err == nil {
+		t.Fatal("expected an error for bad xml")
+	}
+
+}
@@ -0,0 +1,137 @@
+/*
+This file contains the code for comparing versions of a model
+*/
+package addie
+
+import (
+	"strings"
+)
+
+/*
+A DiffLine is one line of a line by line difference, Op is "+" for a line
+only in the newer text, "-" for a line only in the older text and " " for a
+line both have.
+*/
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type ModelDiff struct {
+	Equations     []DiffLine `json:"equations"`
+	AddedParams   []string   `json:"addedParams"`
+	RemovedParams []string   `json:"removedParams"`
+}
+
+func (d ModelDiff) Changed() bool {
+
+	if len(d.AddedParams) > 0 || len(d.RemovedParams) > 0 {
+		return true
+	}
+	for _, l := range d.Equations {
+		if l.Op != " " {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+/*
+DiffModels compares the equations and parameters of two versions of a model
+*/
+func DiffModels(from, to Model) ModelDiff {
+
+	var d ModelDiff
+	d.Equations = DiffLines(lines(from.Equations), lines(to.Equations))
+
+	fps, tps := paramSet(from.Params), paramSet(to.Params)
+	for _, p := range strings.Split(strings.Replace(to.Params, " ", "", -1), ",") {
+		if p != "" && !fps[p] {
+			d.AddedParams = append(d.AddedParams, p)
+		}
+	}
+	for _, p := range strings.Split(strings.Replace(from.Params, " ", "", -1), ",") {
+		if p != "" && !tps[p] {
+			d.RemovedParams = append(d.RemovedParams, p)
+		}
+	}
+
+	return d
+
+}
+
+/*
+DiffLines computes a line by line difference from the longest common
+subsequence of the two texts
+*/
+func DiffLines(a, b []string) []DiffLine {
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var result []DiffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, DiffLine{" ", a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, DiffLine{"-", a[i]})
+			i++
+		default:
+			result = append(result, DiffLine{"+", b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, DiffLine{"-", a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, DiffLine{"+", b[j]})
+	}
+
+	return result
+
+}
+
+func lines(s string) []string {
+
+	var result []string
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			result = append(result, l)
+		}
+	}
+
+	return result
+
+}
+
+func paramSet(params string) map[string]bool {
+
+	result := make(map[string]bool)
+	for _, p := range strings.Split(strings.Replace(params, " ", "", -1), ",") {
+		result[p] = true
+	}
+
+	return result
+
+}
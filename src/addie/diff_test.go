@@ -0,0 +1,31 @@
+package addie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffModels(t *testing.T) {
+
+	v1 := Model{Name: "Rotor", Params: "H",
+		Equations: "w' = tau - H*w^2\ntheta' = w"}
+	v2 := Model{Name: "Rotor", Params: "H, D",
+		Equations: "w' = (tau - D*w)/H\ntheta' = w"}
+
+	d := DiffModels(v1, v2)
+	expected := []DiffLine{
+		{"-", "w' = tau - H*w^2"},
+		{"+", "w' = (tau - D*w)/H"},
+		{" ", "theta' = w"},
+	}
+	if !reflect.DeepEqual(d.Equations, expected) {
+		t.Fatalf("bad equation diff %v", d.Equations)
+	}
+	if !reflect.DeepEqual(d.AddedParams, []string{"D"}) || len(d.RemovedParams) != 0 {
+		t.Fatalf("bad parameter diff %+v", d)
+	}
+	if !d.Changed() || DiffModels(v2, v2).Changed() {
+		t.Fatal("bad change detection")
+	}
+
+}
@@ -0,0 +1,182 @@
+/*
+This file contains the Docker Compose exporter
+*/
+package emu
+
+import (
+	"addie"
+	"addie/sim"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+The image the simulation nodes run the compiled simulation in
+*/
+var SimImage = "ubuntu:22.04"
+
+/*
+The network the saxes and simulation nodes share, as on the testbed
+*/
+const kryNet = "krynet"
+
+func yamlList(items []string) string {
+
+	quoted := make([]string, len(items))
+	for i, s := range items {
+		quoted[i] = strconv.Quote(s)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+
+}
+
+type service struct {
+	name, image string
+	entrypoint  []string
+	command     []string
+	workdir     string
+	volumes     []string
+	networks    []string
+}
+
+func (s *service) write(buf *bytes.Buffer) {
+
+	fmt.Fprintf(buf, "  %s:\n", strconv.Quote(s.name))
+	fmt.Fprintf(buf, "    image: %s\n", strconv.Quote(s.image))
+	fmt.Fprintf(buf, "    hostname: %s\n", strconv.Quote(s.name))
+	if len(s.entrypoint) > 0 {
+		fmt.Fprintf(buf, "    entrypoint: %s\n", yamlList(s.entrypoint))
+	}
+	if len(s.command) > 0 {
+		fmt.Fprintf(buf, "    command: %s\n", yamlList(s.command))
+	}
+	if s.workdir != "" {
+		fmt.Fprintf(buf, "    working_dir: %s\n", strconv.Quote(s.workdir))
+	}
+	if len(s.volumes) > 0 {
+		fmt.Fprintf(buf, "    volumes: %s\n", yamlList(s.volumes))
+	}
+	if len(s.networks) > 0 {
+		fmt.Fprintf(buf, "    networks: %s\n", yamlList(s.networks))
+	}
+
+}
+
+/*
+Compose exports a design as a Docker Compose project that runs its computers,
+saxes and simulation nodes as containers on a single machine. Every group of
+switches linked to each other and every link that does not end on a switch is a
+bridge network. The start script of a computer is its entrypoint, the
+containers without one idle. There are kryNodes simulation nodes, kry0 and on,
+each running the rcomp0 of the .cypk the design compiles to with the
+simulation settings s, the .cypk directories go next to the compose file. Routers are left out, so containers only reach
+the containers they share a network with.
+*/
+func Compose(dsg *addie.Design, s addie.SimSettings, kryNodes int) (Files, error) {
+
+	if kryNodes < 1 {
+		kryNodes = 1
+	}
+
+	t, err := plan(dsg)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := make(map[*host][]string)
+	var join = func(h *host, network string) {
+		for _, n := range networks[h] {
+			if n == network {
+				return
+			}
+		}
+		networks[h] = append(networks[h], network)
+	}
+
+	//switches linked to each other are one network, named after one of them
+	bridged := make(map[*host]*host)
+	var network func(h *host) *host
+	network = func(h *host) *host {
+		p, ok := bridged[h]
+		if !ok || p == h {
+			return h
+		}
+		root := network(p)
+		bridged[h] = root
+		return root
+	}
+	for _, w := range t.wires {
+		if w.a.host.kind == switchKind && w.b.host.kind == switchKind {
+			a, b := network(w.a.host), network(w.b.host)
+			if a != b {
+				bridged[b] = a
+			}
+		}
+	}
+
+	var nets []string
+	for _, h := range t.hosts {
+		if h.kind == switchKind && network(h) == h {
+			nets = append(nets, h.name)
+		}
+	}
+	for _, w := range t.wires {
+		switch {
+		case w.a.host.kind == switchKind && w.b.host.kind == switchKind:
+			//merged above
+		case w.a.host.kind == switchKind:
+			join(w.b.host, network(w.a.host).name)
+		case w.b.host.kind == switchKind:
+			join(w.a.host, network(w.b.host).name)
+		default:
+			nets = append(nets, w.name)
+			join(w.a.host, w.name)
+			join(w.b.host, w.name)
+		}
+	}
+	nets = append(nets, kryNet)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "# Docker Compose project of the design %s\n", t.name)
+	buf.WriteString("services:\n")
+
+	for _, h := range t.hosts {
+		if h.kind != hostKind {
+			continue
+		}
+		svc := service{name: h.name, image: h.image, networks: networks[h]}
+		if _, ok := dsg.Elements[h.id].(addie.Sax); ok {
+			svc.networks = append(svc.networks, kryNet)
+		}
+		if h.start != "" {
+			svc.entrypoint = []string{"/bin/sh", "-c", h.start}
+		} else {
+			svc.command = []string{"sleep", "infinity"}
+		}
+		svc.write(buf)
+	}
+
+	args := sim.RuntimeArgs(s)
+	for i := 0; i < kryNodes; i++ {
+		cypk := sim.SimName(t.name, i, kryNodes) + ".cypk"
+		svc := service{
+			name:     fmt.Sprintf("kry%d", i),
+			image:    SimImage,
+			command:  append([]string{"./rcomp0"}, args...),
+			workdir:  "/cypk",
+			volumes:  []string{"./" + cypk + ":/cypk"},
+			networks: []string{kryNet},
+		}
+		svc.write(buf)
+	}
+
+	buf.WriteString("networks:\n")
+	for _, n := range nets {
+		fmt.Fprintf(buf, "  %s:\n    driver: bridge\n", strconv.Quote(n))
+	}
+
+	return Files{"docker-compose.yml": buf.Bytes()}, nil
+
+}
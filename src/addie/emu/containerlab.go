@@ -0,0 +1,132 @@
+/*
+This file contains the containerlab exporter
+*/
+package emu
+
+import (
+	"addie"
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+/*
+clabIf names the interfaces of containerlab nodes. eth0 of a container is its
+management interface so the design's interfaces start at eth1, the ends of a
+bridge are interfaces on the machine itself and are named after the bridge.
+*/
+func clabIf(e end) string {
+
+	if e.host.kind == switchKind {
+		return fmt.Sprintf("%s-e%d", e.host.short, e.i)
+	}
+	return fmt.Sprintf("eth%d", e.i+1)
+
+}
+
+/*
+netem returns the arguments of containerlab tools netem that give an
+interface the capacity and latency of a link, nothing when the link has
+neither. Rates are in kbps.
+*/
+func netem(w wire) string {
+
+	args := ""
+	if w.latency > 0 {
+		args += fmt.Sprintf(" --delay %dms", w.latency)
+	}
+	if w.capacity > 0 {
+		args += fmt.Sprintf(" --rate %d", w.capacity*1000)
+	}
+	return args
+
+}
+
+const frrDaemons = `zebra=yes
+ospf6d=yes
+vtysh_enable=yes
+`
+
+/*
+Containerlab exports a design as a containerlab topology. Along with the
+topology come the FRR configuration of every router with links and a deploy.sh
+that creates the Linux bridges the switches need, deploys the lab and shapes
+the links with netem. Only the host ends of a link are shaped, so traffic from
+a switch to a host is not. Every name in deploy.sh is quoted.
+*/
+func Containerlab(dsg *addie.Design) (Files, error) {
+
+	t, err := plan(dsg)
+	if err != nil {
+		return nil, err
+	}
+	files := make(Files)
+	topo := t.name + ".clab.yml"
+
+	y := new(bytes.Buffer)
+	fmt.Fprintf(y, "# containerlab topology of the design %s, run deploy.sh to deploy it\n",
+		t.name)
+	fmt.Fprintf(y, "name: %s\n", strconv.Quote(t.name))
+	y.WriteString("topology:\n  nodes:\n")
+
+	for _, h := range t.hosts {
+		fmt.Fprintf(y, "    %s:\n", strconv.Quote(h.name))
+		if h.kind == switchKind {
+			y.WriteString("      kind: bridge\n")
+			continue
+		}
+		y.WriteString("      kind: linux\n")
+		fmt.Fprintf(y, "      image: %s\n", strconv.Quote(h.image))
+		if h.kind != routerKind {
+			continue
+		}
+		cfg, ok := routerConfig(t, h, clabIf)
+		if !ok {
+			continue
+		}
+		dir := "routers/" + h.name
+		files[dir+"/daemons"] = []byte(frrDaemons)
+		files[dir+"/frr.conf"] = []byte(cfg.ZebraConf + "!\n" + cfg.Ospf6Conf)
+		y.WriteString("      binds:\n")
+		fmt.Fprintf(y, "        - %s\n", strconv.Quote(dir+"/daemons:/etc/frr/daemons"))
+		fmt.Fprintf(y, "        - %s\n", strconv.Quote(dir+"/frr.conf:/etc/frr/frr.conf"))
+	}
+
+	if len(t.wires) > 0 {
+		y.WriteString("  links:\n")
+	}
+	for _, w := range t.wires {
+		fmt.Fprintf(y, "    - endpoints: [%s, %s]\n",
+			strconv.Quote(w.a.host.name+":"+clabIf(w.a)),
+			strconv.Quote(w.b.host.name+":"+clabIf(w.b)))
+	}
+	files[topo] = y.Bytes()
+
+	sh := new(bytes.Buffer)
+	sh.WriteString("#!/bin/sh\nset -e\n\n")
+	for _, h := range t.hosts {
+		if h.kind == switchKind {
+			fmt.Fprintf(sh, "ip link show %[1]s >/dev/null 2>&1 || "+
+				"ip link add %[1]s type bridge\nip link set %[1]s up\n\n",
+				shellQuote(h.name))
+		}
+	}
+	fmt.Fprintf(sh, "containerlab deploy -t %s\n\n", shellQuote(topo))
+	for _, w := range t.wires {
+		args := netem(w)
+		if args == "" {
+			continue
+		}
+		for _, e := range []end{w.a, w.b} {
+			if e.host.kind == switchKind {
+				continue
+			}
+			fmt.Fprintf(sh, "containerlab tools netem set -n %s -i %s%s\n",
+				shellQuote("clab-"+t.name+"-"+e.host.name), shellQuote(clabIf(e)), args)
+		}
+	}
+	files["deploy.sh"] = sh.Bytes()
+
+	return files, nil
+
+}
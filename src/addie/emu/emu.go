@@ -0,0 +1,300 @@
+/*
+The emu package exports designs for emulation on a single Linux machine, for
+those without access to the testbed. Computers and saxes become hosts, routers
+become FRR routers and switches become bridges. The capacity and latency of a
+link shape the interfaces on its ends.
+*/
+package emu
+
+import (
+	"addie"
+	"addie/param"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"github.com/cycps/xptools/routec"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+Files maps the paths of the files an exporter writes, relative to the directory
+they are written to, to their contents
+*/
+type Files map[string][]byte
+
+/*
+Paths returns the paths of the files in order
+*/
+func (fs Files) Paths() []string {
+
+	paths := make([]string, 0, len(fs))
+	for p := range fs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+
+}
+
+/*
+Zip packs the files into a zip archive. Scripts and executables, the files
+that start with #! or are ELF binaries, can be run once they are unpacked.
+*/
+func (fs Files) Zip() ([]byte, error) {
+
+	buf := new(bytes.Buffer)
+	z := zip.NewWriter(buf)
+
+	for _, p := range fs.Paths() {
+		hdr := &zip.FileHeader{Name: p, Method: zip.Deflate}
+		hdr.SetMode(0644)
+		if bytes.HasPrefix(fs[p], []byte("#!")) || bytes.HasPrefix(fs[p], []byte("\x7fELF")) {
+			hdr.SetMode(0755)
+		}
+		f, err := z.CreateHeader(hdr)
+		if err != nil {
+			return nil, err
+		}
+		_, err = f.Write(fs[p])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := z.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+
+}
+
+/*
+The image a node runs can be chosen with this attribute
+*/
+const ImageAttribute = "image"
+
+/*
+The images nodes run when they do not choose one
+*/
+var (
+	HostImage   = "ubuntu:22.04"
+	RouterImage = "quay.io/frrouting/frr:9.1.0"
+)
+
+/*
+The longest name that keeps the interface names of a node, like <name>-eth12,
+within the 15 characters Linux allows. Nodes with longer names get a short
+name for their interfaces, switches are Linux bridges so their names are
+always short.
+*/
+const maxName = 9
+
+const (
+	hostKind   = "host"
+	routerKind = "router"
+	switchKind = "switch"
+)
+
+type host struct {
+	id                addie.Id
+	name, short, kind string
+	image             string
+	//the interfaces handed out so far
+	ifs int
+	//computers only
+	start string
+}
+
+type end struct {
+	host *host
+	//the index of the interface on the host
+	i int
+}
+
+type wire struct {
+	name              string
+	a, b              end
+	capacity, latency int
+}
+
+type topology struct {
+	name  string
+	hosts []*host
+	wires []wire
+}
+
+/*
+shellQuote quotes s as a single word for sh
+*/
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+/*
+checkPath makes sure a name can be a file or directory name in the exported
+files and in the paths the exported scripts make
+*/
+func checkPath(what, name string) error {
+
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") ||
+		strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("the %s name %q can not be used as a path", what, name)
+	}
+	return nil
+
+}
+
+func sortedIds(dsg *addie.Design) []addie.Id {
+
+	ids := make([]addie.Id, 0, len(dsg.Elements))
+	for id := range dsg.Elements {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
+
+}
+
+/*
+plan collects the hosts of a design and the wires between them. Every host
+and wire gets names no other has, and interfaces are handed out in the order
+the links attach. Links with an end that is not a host are left out. The
+design and its routers name files, so their names must be usable as paths.
+*/
+func plan(dsg *addie.Design) (*topology, error) {
+
+	dsg, _ = param.Resolve(dsg)
+	t := &topology{name: dsg.Name}
+	err := checkPath("design", t.name)
+	if err != nil {
+		return nil, err
+	}
+	ids := sortedIds(dsg)
+
+	taken := make(map[string]bool)
+	next := 0
+	var claim = func(long bool, candidates ...string) string {
+		for _, n := range candidates {
+			if n != "" && (long || len(n) <= maxName) && !taken[n] {
+				taken[n] = true
+				return n
+			}
+		}
+		for ; ; next++ {
+			n := fmt.Sprintf("n%d", next)
+			if !taken[n] {
+				taken[n] = true
+				return n
+			}
+		}
+	}
+
+	hosts := make(map[addie.Id]*host)
+	for _, id := range ids {
+		e := dsg.Elements[id]
+		var h *host
+		switch e.(type) {
+		case addie.Computer:
+			c := e.(addie.Computer)
+			h = &host{kind: hostKind, image: HostImage, start: c.Start_script}
+		case addie.Sax:
+			h = &host{kind: hostKind, image: HostImage}
+		case addie.Router:
+			h = &host{kind: routerKind, image: RouterImage}
+		case addie.Switch:
+			h = &host{kind: switchKind}
+		default:
+			continue
+		}
+		h.id = id
+		h.name = claim(h.kind != switchKind, id.Name, id.Name+"-"+id.Sys)
+		if h.kind == routerKind {
+			err = checkPath("router", h.name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		h.short = h.name
+		if len(h.short) > maxName {
+			h.short = claim(false)
+		}
+		if img, ok := addie.ElementAttributes(e)[ImageAttribute]; ok && h.kind != switchKind {
+			h.image = img
+		}
+		hosts[id] = h
+		t.hosts = append(t.hosts, h)
+	}
+
+	var attach = func(h *host) end {
+		h.ifs++
+		return end{h, h.ifs - 1}
+	}
+
+	for _, id := range ids {
+		l, ok := dsg.Elements[id].(addie.Link)
+		if !ok {
+			continue
+		}
+		a, aok := hosts[l.Endpoints[0].Id]
+		b, bok := hosts[l.Endpoints[1].Id]
+		if !aok || !bok {
+			continue
+		}
+		t.wires = append(t.wires, wire{name: claim(true, id.Name, id.Name+"-"+id.Sys),
+			a: attach(a), b: attach(b),
+			capacity: l.Capacity, latency: l.Latency})
+	}
+
+	return t, nil
+
+}
+
+/*
+routerConfig generates the quagga configuration the testbed routers get from
+routec for the router r, the interface names are known up front here rather
+than resolved on the router. Routers without links have no configuration.
+*/
+func routerConfig(t *topology, r *host, ifname func(end) string) (routec.RouterConfig, bool) {
+
+	var id uint32
+	for _, h := range t.hosts {
+		if h.kind == routerKind {
+			id++
+		}
+		if h == r {
+			break
+		}
+	}
+
+	var cfg routec.RouterConfig
+	for _, w := range t.wires {
+		mine, other := w.a, w.b
+		if w.b.host == r {
+			mine, other = w.b, w.a
+		}
+		if mine.host != r {
+			continue
+		}
+		if other.host.kind == routerKind {
+			cfg.PeerInterfaces = append(cfg.PeerInterfaces, ifname(mine))
+		} else {
+			cfg.DownstreamInterfaces = append(cfg.DownstreamInterfaces, ifname(mine))
+		}
+	}
+	if len(cfg.PeerInterfaces)+len(cfg.DownstreamInterfaces) == 0 {
+		return cfg, false
+	}
+
+	cfg.CoreSubnet = "2001:cc::/32"
+	cfg.BasePrefix = fmt.Sprintf("2001:cc:%04x:%04x", id>>16, id&0xffff)
+	cfg.BaseAddr = cfg.BasePrefix + "::1"
+	cfg.DownstreamSubnet = cfg.BasePrefix + "::/64"
+	cfg.GenZebraConf()
+	cfg.GenOspf6Conf()
+
+	return cfg, true
+
+}
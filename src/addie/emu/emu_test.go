@@ -0,0 +1,275 @@
+package emu
+
+import (
+	"addie"
+	"addie/topo"
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+/*
+lab is what the exporters have to name and wire: a shaped star, a router, a
+name too long for interface names and two computers with the same name in
+different systems, one of which runs its own image
+*/
+func lab(t *testing.T) *addie.Design {
+
+	dsg := addie.EmptyDesign("ardbeg")
+	es, err := topo.Generate(
+		topo.Spec{Shape: "star", Nodes: 2, Capacity: 100, Latency: 3}, "ardbeg", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es {
+		dsg.Elements[e.Identify()] = e
+	}
+
+	pc := addie.PacketConductor{Capacity: 10, Latency: 20}
+	eth0 := func() map[string]addie.Interface {
+		return map[string]addie.Interface{"eth0": {Name: "eth0", PacketConductor: pc}}
+	}
+
+	var r addie.Router
+	r.NetHost = addie.NetHost{Id: addie.Id{Name: "gw", Sys: "root", Design: "ardbeg"},
+		Interfaces: eth0()}
+	dsg.Elements[r.Id] = r
+
+	var historian, c0 addie.Computer
+	historian.NetHost = addie.NetHost{Interfaces: eth0(),
+		Id: addie.Id{Name: "historian", Sys: "wan", Design: "ardbeg"}}
+	historian.Attributes = addie.Attributes{ImageAttribute: "alpine:3"}
+	c0.NetHost = addie.NetHost{Interfaces: eth0(),
+		Id: addie.Id{Name: "star-c0", Sys: "wan", Design: "ardbeg"}}
+	c0.Attributes = addie.Attributes{ImageAttribute: "alpine:3"}
+	dsg.Elements[historian.Id] = historian
+	dsg.Elements[c0.Id] = c0
+
+	l := addie.Link{Id: addie.Id{Name: "uplink", Sys: "root", Design: "ardbeg"},
+		PacketConductor: pc}
+	l.Endpoints = [2]addie.NetIfRef{{Id: r.Id, IfName: "eth0"},
+		{Id: historian.Id, IfName: "eth0"}}
+	dsg.Elements[l.Id] = l
+
+	return &dsg
+
+}
+
+func expect(t *testing.T, what, out string, wants ...string) {
+
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Fatalf("%s is missing %s\n%s", what, want, out)
+		}
+	}
+
+}
+
+func TestPlan(t *testing.T) {
+
+	tp, err := plan(lab(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]*host)
+	for _, h := range tp.hosts {
+		names[h.name] = h
+	}
+	for _, n := range []string{"star-c0", "star-c0-wan", "historian", "star-sw", "gw"} {
+		if _, ok := names[n]; !ok {
+			t.Fatalf("no host named %s in %v", n, names)
+		}
+	}
+	if names["historian"].short != "historian" || names["star-c0-wan"].short != "n0" {
+		t.Fatalf("bad short names %s %s",
+			names["historian"].short, names["star-c0-wan"].short)
+	}
+	if names["historian"].image != "alpine:3" || names["gw"].image != RouterImage {
+		t.Fatal("bad images")
+	}
+	if len(tp.wires) != 3 {
+		t.Fatalf("expected 3 wires got %d", len(tp.wires))
+	}
+
+}
+
+func TestContainerlab(t *testing.T) {
+
+	files, err := Containerlab(lab(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "topology", string(files["ardbeg.clab.yml"]),
+		`name: "ardbeg"`,
+		"    \"star-sw\":\n      kind: bridge\n",
+		"    \"historian\":\n      kind: linux\n      image: \"alpine:3\"\n",
+		`- "routers/gw/frr.conf:/etc/frr/frr.conf"`,
+		`- endpoints: ["gw:eth1", "historian:eth1"]`,
+		`"star-sw:star-sw-e0"`,
+	)
+
+	deploy := string(files["deploy.sh"])
+	expect(t, "deploy.sh", deploy,
+		"ip link add 'star-sw' type bridge",
+		"containerlab deploy -t 'ardbeg.clab.yml'",
+		"containerlab tools netem set -n 'clab-ardbeg-gw' -i 'eth1' --delay 20ms --rate 10000",
+		"containerlab tools netem set -n 'clab-ardbeg-star-c0' -i 'eth1' "+
+			"--delay 3ms --rate 100000",
+	)
+	if strings.Contains(deploy, "clab-ardbeg-star-sw") {
+		t.Fatalf("bridge ends are shaped\n%s", deploy)
+	}
+
+	expect(t, "frr.conf", string(files["routers/gw/frr.conf"]),
+		"interface eth1\n", "router ospf6", "ipv6 nd prefix 2001:cc:0000:0001::/64")
+
+	z, err := files.Zip()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(z), int64(len(z)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(files) || zr.File[0].Name != files.Paths()[0] {
+		t.Fatal("bad zip")
+	}
+	for _, f := range zr.File {
+		if f.Name == "deploy.sh" && f.Mode().Perm() != 0755 {
+			t.Fatalf("deploy.sh is not executable %v", f.Mode())
+		}
+	}
+
+}
+
+func TestMininet(t *testing.T) {
+
+	py, err := Mininet(lab(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(py)
+
+	expect(t, "mininet script", out,
+		`DESIGN = "ardbeg"`,
+		`ROUTERS = ["gw"]`,
+		`SWITCHES = ["star-sw"]`,
+		`("gw", "historian", "gw-eth0", "historian-eth0", {'bw': 10, 'delay': '20ms'}),`,
+		`"gw": "hostname r\n`,
+		"if __name__ == '__main__':",
+	)
+
+}
+
+func TestCompose(t *testing.T) {
+
+	dsg := lab(t)
+	id := addie.Id{Name: "valve", Sys: "root", Design: "ardbeg"}
+	sax := addie.Sax{}
+	sax.NetHost = addie.NetHost{Id: id}
+	dsg.Elements[id] = sax
+
+	c := dsg.Elements[addie.Id{Name: "star-c0", Sys: "root", Design: "ardbeg"}].(addie.Computer)
+	c.Start_script = "/opt/plc/start.sh --fast"
+	dsg.Elements[c.Id] = c
+
+	s := addie.SimSettings{Begin: 0, End: 10, MaxStep: 1e-3}
+	files, err := Compose(dsg, s, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(files["docker-compose.yml"])
+
+	expect(t, "compose file", out,
+		"  \"star-c0\":\n    image: \"ubuntu:22.04\"\n    hostname: \"star-c0\"\n"+
+			"    entrypoint: [\"/bin/sh\", \"-c\", \"/opt/plc/start.sh --fast\"]\n"+
+			"    networks: [\"star-sw\"]\n",
+		"  \"star-c1\":\n    image: \"ubuntu:22.04\"\n    hostname: \"star-c1\"\n"+
+			"    command: [\"sleep\", \"infinity\"]\n",
+		"    networks: [\"uplink\"]\n",
+		"  \"valve\":\n    image: \"ubuntu:22.04\"\n    hostname: \"valve\"\n"+
+			"    command: [\"sleep\", \"infinity\"]\n    networks: [\"krynet\"]\n",
+		"    command: [\"./rcomp0\", \"0e+00\", \"1e+01\", \"1e-03\"]\n",
+		"    volumes: [\"./ardbeg_kry0.cypk:/cypk\"]\n",
+		"    volumes: [\"./ardbeg_kry1.cypk:/cypk\"]\n",
+		"networks:\n  \"star-sw\":\n    driver: bridge\n",
+		"  \"uplink\":\n    driver: bridge\n",
+		"  \"krynet\":\n    driver: bridge\n",
+	)
+
+	//routers are not run
+	if strings.Contains(out, `"gw":`) {
+		t.Fatalf("router gw has a service\n%s", out)
+	}
+
+}
+
+func TestComposeSwitchTree(t *testing.T) {
+
+	//the hosts of a tree hang off different switches joined by switch links
+	dsg := addie.EmptyDesign("laphroaig")
+	es, err := topo.Generate(topo.Spec{Shape: "tree", Fanout: 2, Depth: 2},
+		"laphroaig", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es {
+		dsg.Elements[e.Identify()] = e
+	}
+
+	files, err := Compose(&dsg, addie.SimSettings{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(files["docker-compose.yml"])
+	for _, c := range []string{"tree-c0", "tree-c1", "tree-c2", "tree-c3"} {
+		expect(t, "compose file", out, "  \""+c+"\":\n    image: \"ubuntu:22.04\"\n"+
+			"    hostname: \""+c+"\"\n    command: [\"sleep\", \"infinity\"]\n"+
+			"    networks: [\"tree-s0-0\"]\n")
+	}
+	if strings.Count(out, "driver: bridge") != 2 {
+		t.Fatalf("the switches are not one network\n%s", out)
+	}
+
+}
+
+func TestUnsafeNames(t *testing.T) {
+
+	//names from imports and templates are not checked when they are made
+	dsg := lab(t)
+	sw := dsg.Elements[addie.Id{Name: "star-sw", Sys: "root", Design: "ardbeg"}].(addie.Switch)
+	delete(dsg.Elements, sw.Id)
+	sw.Id.Name = "sw;reboot"
+	dsg.Elements[sw.Id] = sw
+	for id, e := range dsg.Elements {
+		if l, ok := e.(addie.Link); ok {
+			dsg.Elements[id] = addie.Relabel(l,
+				map[addie.Id]addie.Id{{Name: "star-sw", Sys: "root", Design: "ardbeg"}: sw.Id})
+		}
+	}
+
+	files, err := Containerlab(dsg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "deploy.sh", string(files["deploy.sh"]),
+		"ip link add 'sw;reboot' type bridge")
+
+	r := dsg.Elements[addie.Id{Name: "gw", Sys: "root", Design: "ardbeg"}].(addie.Router)
+	delete(dsg.Elements, r.Id)
+	r.Id.Name = "../gw"
+	dsg.Elements[r.Id] = r
+	if _, err := Containerlab(dsg); err == nil {
+		t.Fatal("a router name that leaves its directory was accepted")
+	}
+
+	dsg = lab(t)
+	dsg.Name = "a/b"
+	if _, err := Mininet(dsg); err == nil {
+		t.Fatal("a design name with a slash was accepted")
+	}
+
+}
@@ -0,0 +1,146 @@
+/*
+This file contains the Mininet exporter
+*/
+package emu
+
+import (
+	"addie"
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+func mnIf(e end) string {
+	return fmt.Sprintf("%s-eth%d", e.host.short, e.i)
+}
+
+/*
+pyDict writes a python dict of strings
+*/
+func pyDict(buf *bytes.Buffer, name string, keys []string, values map[string]string) {
+
+	fmt.Fprintf(buf, "%s = {\n", name)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "    %s: %s,\n", strconv.Quote(k), strconv.Quote(values[k]))
+	}
+	buf.WriteString("}\n\n")
+
+}
+
+const mnRun = `def run():
+    net = Mininet(link=TCLink, switch=OVSBridge, controller=None)
+    nodes = {}
+    for name in HOSTS + ROUTERS:
+        nodes[name] = net.addHost(name)
+    for i, name in enumerate(SWITCHES):
+        nodes[name] = net.addSwitch(name, dpid='%016x' % (i + 1))
+    for a, b, ifa, ifb, opts in LINKS:
+        net.addLink(nodes[a], nodes[b], intfName1=ifa, intfName2=ifb, **opts)
+    net.start()
+
+    for name in ROUTERS:
+        r = nodes[name]
+        r.cmd('sysctl -w net.ipv6.conf.all.forwarding=1')
+        if name not in ZEBRA:
+            continue
+        d = '/tmp/%s/%s' % (DESIGN, name)
+        r.cmd('mkdir -p %s %s' % (quote(d), quote('/var/run/frr/' + name)))
+        for daemon, conf in (('zebra', ZEBRA), ('ospf6d', OSPF6)):
+            path = '%s/%s.conf' % (d, daemon)
+            with open(path, 'w') as f:
+                f.write(conf[name])
+            r.cmd('%s -d -u root -g root -N %s -f %s' %
+                  (quote('%s/%s' % (FRR, daemon)), quote(name), quote(path)))
+
+    CLI(net)
+
+    for name in ROUTERS:
+        nodes[name].cmd('pkill -f -- %s' % quote('-N %s ' % re.escape(name)))
+    net.stop()
+
+
+if __name__ == '__main__':
+    setLogLevel('info')
+    run()
+`
+
+/*
+Mininet exports a design as a Mininet python script. Links are traffic
+controlled links with the bandwidth and delay of the design's links, switches
+are Open vSwitch bridges and routers are hosts that forward and run the FRR
+daemons the testbed routers run, from the configurations generated for them.
+*/
+func Mininet(dsg *addie.Design) ([]byte, error) {
+
+	t, err := plan(dsg)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "#!/usr/bin/env python3\n# Mininet emulation of the design %s\n\n",
+		t.name)
+	buf.WriteString("from mininet.net import Mininet\n" +
+		"from mininet.link import TCLink\n" +
+		"from mininet.node import OVSBridge\n" +
+		"from mininet.cli import CLI\n" +
+		"from mininet.log import setLogLevel\n" +
+		"from shlex import quote\n" +
+		"import re\n\n")
+
+	fmt.Fprintf(buf, "DESIGN = %s\nFRR = '/usr/lib/frr'\n\n", strconv.Quote(t.name))
+
+	lists := map[string][]string{}
+	zebra := make(map[string]string)
+	ospf6 := make(map[string]string)
+	var configured []string
+	for _, h := range t.hosts {
+		lists[h.kind] = append(lists[h.kind], h.name)
+		if h.kind != routerKind {
+			continue
+		}
+		if cfg, ok := routerConfig(t, h, mnIf); ok {
+			configured = append(configured, h.name)
+			zebra[h.name] = cfg.ZebraConf
+			ospf6[h.name] = cfg.Ospf6Conf
+		}
+	}
+	for _, l := range []struct{ name, kind string }{
+		{"HOSTS", hostKind}, {"ROUTERS", routerKind}, {"SWITCHES", switchKind}} {
+		fmt.Fprintf(buf, "%s = [", l.name)
+		for i, n := range lists[l.kind] {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(strconv.Quote(n))
+		}
+		buf.WriteString("]\n")
+	}
+	buf.WriteString("\n")
+
+	buf.WriteString("LINKS = [\n")
+	for _, w := range t.wires {
+		opts := ""
+		if w.capacity > 0 {
+			opts += fmt.Sprintf("'bw': %d", w.capacity)
+		}
+		if w.latency > 0 {
+			if opts != "" {
+				opts += ", "
+			}
+			opts += fmt.Sprintf("'delay': '%dms'", w.latency)
+		}
+		fmt.Fprintf(buf, "    (%s, %s, %s, %s, {%s}),\n",
+			strconv.Quote(w.a.host.name), strconv.Quote(w.b.host.name),
+			strconv.Quote(mnIf(w.a)), strconv.Quote(mnIf(w.b)), opts)
+	}
+	buf.WriteString("]\n\n")
+
+	pyDict(buf, "ZEBRA", configured, zebra)
+	pyDict(buf, "OSPF6", configured, ospf6)
+
+	buf.WriteString("\n" + mnRun)
+
+	return buf.Bytes(), nil
+
+}
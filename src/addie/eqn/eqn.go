@@ -0,0 +1,292 @@
+/*
+The eqn package parses and evaluates the equations of addie models. Models
+are written as explicit equations, x' = f(...) defines the derivative of the
+state x and y = g(...) defines the variable y.
+*/
+package eqn
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/*
+An Env holds the values of variables, derivatives are stored under the state
+name followed by a quote
+*/
+type Env map[string]float64
+
+type Expr interface {
+	Eval(env Env) (float64, error)
+	String() string
+}
+
+type Num float64
+
+type Var string
+
+/*
+A Der is the derivative of a state variable, x' in model equations
+*/
+type Der string
+
+type Unary struct {
+	Op byte
+	X  Expr
+}
+
+type Binary struct {
+	Op   byte
+	L, R Expr
+}
+
+type Call struct {
+	Fn   string
+	Args []Expr
+}
+
+var constants = map[string]float64{"pi": math.Pi, "e": math.E}
+
+var funcs = map[string]func(xs ...float64) float64{
+	"sin":   func(xs ...float64) float64 { return math.Sin(xs[0]) },
+	"cos":   func(xs ...float64) float64 { return math.Cos(xs[0]) },
+	"tan":   func(xs ...float64) float64 { return math.Tan(xs[0]) },
+	"asin":  func(xs ...float64) float64 { return math.Asin(xs[0]) },
+	"acos":  func(xs ...float64) float64 { return math.Acos(xs[0]) },
+	"atan":  func(xs ...float64) float64 { return math.Atan(xs[0]) },
+	"sinh":  func(xs ...float64) float64 { return math.Sinh(xs[0]) },
+	"cosh":  func(xs ...float64) float64 { return math.Cosh(xs[0]) },
+	"tanh":  func(xs ...float64) float64 { return math.Tanh(xs[0]) },
+	"exp":   func(xs ...float64) float64 { return math.Exp(xs[0]) },
+	"log":   func(xs ...float64) float64 { return math.Log(xs[0]) },
+	"sqrt":  func(xs ...float64) float64 { return math.Sqrt(xs[0]) },
+	"abs":   func(xs ...float64) float64 { return math.Abs(xs[0]) },
+	"sign":  func(xs ...float64) float64 { return sign(xs[0]) },
+	"min":   func(xs ...float64) float64 { return math.Min(xs[0], xs[1]) },
+	"max":   func(xs ...float64) float64 { return math.Max(xs[0], xs[1]) },
+	"pow":   func(xs ...float64) float64 { return math.Pow(xs[0], xs[1]) },
+	"atan2": func(xs ...float64) float64 { return math.Atan2(xs[0], xs[1]) },
+}
+
+var arity = map[string]int{"min": 2, "max": 2, "pow": 2, "atan2": 2}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+/*
+IsFunc reports whether name is one of the functions equations may call
+*/
+func IsFunc(name string) bool {
+	_, ok := funcs[name]
+	return ok
+}
+
+func (n Num) Eval(env Env) (float64, error) { return float64(n), nil }
+func (n Num) String() string                { return strconv.FormatFloat(float64(n), 'g', -1, 64) }
+
+func (v Var) Eval(env Env) (float64, error) {
+	if x, ok := env[string(v)]; ok {
+		return x, nil
+	}
+	if x, ok := constants[string(v)]; ok {
+		return x, nil
+	}
+	return 0, fmt.Errorf("%s has no value", string(v))
+}
+func (v Var) String() string { return string(v) }
+
+func (d Der) Eval(env Env) (float64, error) {
+	if x, ok := env[string(d)+"'"]; ok {
+		return x, nil
+	}
+	return 0, fmt.Errorf("%s' has no value", string(d))
+}
+func (d Der) String() string { return string(d) + "'" }
+
+func (u Unary) Eval(env Env) (float64, error) {
+	x, err := u.X.Eval(env)
+	return -x, err
+}
+func (u Unary) String() string { return "-" + u.X.String() }
+
+func (b Binary) Eval(env Env) (float64, error) {
+
+	l, err := b.L.Eval(env)
+	if err != nil {
+		return 0, err
+	}
+	r, err := b.R.Eval(env)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.Op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	case '/':
+		return l / r, nil
+	case '^':
+		return math.Pow(l, r), nil
+	}
+
+	return 0, fmt.Errorf("unknown operator %c", b.Op)
+
+}
+func (b Binary) String() string {
+	return "(" + b.L.String() + string(b.Op) + b.R.String() + ")"
+}
+
+func (c Call) Eval(env Env) (float64, error) {
+
+	xs := make([]float64, len(c.Args))
+	for i, a := range c.Args {
+		x, err := a.Eval(env)
+		if err != nil {
+			return 0, err
+		}
+		xs[i] = x
+	}
+
+	return funcs[c.Fn](xs...), nil
+
+}
+func (c Call) String() string {
+	var as []string
+	for _, a := range c.Args {
+		as = append(as, a.String())
+	}
+	return c.Fn + "(" + strings.Join(as, ",") + ")"
+}
+
+/*
+Vars returns the variables an expression refers to in order of first
+appearance, derivatives are returned with their quote and constants are left
+out
+*/
+func Vars(e Expr) []string {
+
+	var result []string
+	seen := make(map[string]bool)
+
+	var walk func(e Expr)
+	walk = func(e Expr) {
+		switch x := e.(type) {
+		case Var:
+			if _, ok := constants[string(x)]; !ok && !seen[string(x)] {
+				seen[string(x)] = true
+				result = append(result, string(x))
+			}
+		case Der:
+			if !seen[x.String()] {
+				seen[x.String()] = true
+				result = append(result, x.String())
+			}
+		case Unary:
+			walk(x.X)
+		case Binary:
+			walk(x.L)
+			walk(x.R)
+		case Call:
+			for _, a := range x.Args {
+				walk(a)
+			}
+		}
+	}
+	walk(e)
+
+	return result
+
+}
+
+/*
+An Equation is one line of a model, Src is the text it was parsed from
+*/
+type Equation struct {
+	Lhs, Rhs Expr
+	Src      string
+}
+
+/*
+ParseEquations parses the equations of a model, one per line
+*/
+func ParseEquations(src string) ([]Equation, error) {
+
+	var result []Equation
+	for _, l := range strings.Split(src, "\n") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		e, err := ParseEquation(l)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+
+	return result, nil
+
+}
+
+func ParseEquation(src string) (Equation, error) {
+
+	src = strings.TrimSpace(src)
+	parts := strings.Split(src, "=")
+	if len(parts) != 2 {
+		return Equation{}, fmt.Errorf("'%s' is not an equation", src)
+	}
+
+	l, err := Parse(parts[0])
+	if err != nil {
+		return Equation{}, fmt.Errorf("'%s': %v", src, err)
+	}
+	r, err := Parse(parts[1])
+	if err != nil {
+		return Equation{}, fmt.Errorf("'%s': %v", src, err)
+	}
+
+	return Equation{Lhs: l, Rhs: r, Src: src}, nil
+
+}
+
+/*
+Rename returns a copy of an expression with its variables and derivatives
+renamed by f, constants and time keep their names
+*/
+func Rename(e Expr, f func(string) string) Expr {
+
+	switch x := e.(type) {
+	case Var:
+		if _, ok := constants[string(x)]; ok || x == "time" {
+			return x
+		}
+		return Var(f(string(x)))
+	case Der:
+		return Der(f(string(x)))
+	case Unary:
+		return Unary{x.Op, Rename(x.X, f)}
+	case Binary:
+		return Binary{x.Op, Rename(x.L, f), Rename(x.R, f)}
+	case Call:
+		args := make([]Expr, len(x.Args))
+		for i, a := range x.Args {
+			args[i] = Rename(a, f)
+		}
+		return Call{x.Fn, args}
+	}
+
+	return e
+
+}
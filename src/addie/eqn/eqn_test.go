@@ -0,0 +1,79 @@
+package eqn
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	env := Env{"x": 2, "y": 3}
+	cases := map[string]float64{
+		"1 + 2*3":         7,
+		"-x^2":            -4,
+		"2^3^2":           512,
+		"(x + y)/2":       2.5,
+		"1e-3*x":          0.002,
+		"max(x, y) - 1":   2,
+		"sqrt(abs(-x*8))": 4,
+		"cos(pi)":         -1,
+	}
+
+	for src, expected := range cases {
+		e, err := Parse(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		v, err := e.Eval(env)
+		if err != nil || math.Abs(v-expected) > 1e-12 {
+			t.Fatalf("%s = %g, expected %g (%v)", src, v, expected, err)
+		}
+	}
+
+	for _, src := range []string{"1 +", "foo(x)", "max(x)", "(x", "x $ y"} {
+		if _, err := Parse(src); err == nil {
+			t.Fatalf("%s parsed", src)
+		}
+	}
+
+	e, _ := Parse("w' + H*w - tau*e")
+	if !reflect.DeepEqual(Vars(e), []string{"w'", "H", "w", "tau"}) {
+		t.Fatalf("bad variables %v", Vars(e))
+	}
+
+}
+
+func TestIntegrate(t *testing.T) {
+
+	eqs, err := ParseEquations("x' = v\nv' = -k*x\nE = 0.5*(k*x^2 + v^2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSystem(eqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Inputs(), []string{"k"}) {
+		t.Fatalf("bad inputs %v", s.Inputs())
+	}
+
+	env := Env{"k": 1, "x": 1, "v": 0}
+	err = s.Integrate(env, 0, math.Pi, 1e-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(env["x"]+1) > 1e-6 || math.Abs(env["E"]-0.5) > 1e-6 {
+		t.Fatalf("bad oscillator state %v", env)
+	}
+
+	eqs, _ = ParseEquations("a = b + 1\nb = a*2")
+	if _, err := NewSystem(eqs); err == nil {
+		t.Fatal("algebraic loop was not detected")
+	}
+	eqs, _ = ParseEquations("x*y = 1")
+	if _, err := NewSystem(eqs); err == nil {
+		t.Fatal("implicit equation was accepted")
+	}
+
+}
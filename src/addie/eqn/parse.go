@@ -0,0 +1,252 @@
+/*
+This file contains the expression parser, it is a recursive descent parser
+over the usual arithmetic precedence with ^ binding tightest and associating
+to the right
+*/
+package eqn
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type parser struct {
+	src string
+	pos int
+}
+
+/*
+Parse parses an expression
+*/
+func Parse(src string) (Expr, error) {
+
+	p := &parser{src: src}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	p.space()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected '%s'", p.src[p.pos:])
+	}
+
+	return e, nil
+
+}
+
+func (p *parser) space() {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	p.space()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) sum() (Expr, error) {
+
+	l, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		l = Binary{op, l, r}
+	}
+
+}
+
+func (p *parser) product() (Expr, error) {
+
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return l, nil
+		}
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = Binary{op, l, r}
+	}
+
+}
+
+func (p *parser) unary() (Expr, error) {
+
+	switch p.peek() {
+	case '-':
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Unary{'-', x}, nil
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+
+	return p.power()
+
+}
+
+func (p *parser) power() (Expr, error) {
+
+	b, err := p.atom()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() != '^' {
+		return b, nil
+	}
+	p.pos++
+
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	return Binary{'^', b, x}, nil
+
+}
+
+func isAlpha(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func (p *parser) atom() (Expr, error) {
+
+	c := p.peek()
+	switch {
+
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+
+	case c == '(':
+		p.pos++
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return e, nil
+
+	case isDigit(c) || c == '.':
+		return p.number()
+
+	case isAlpha(c):
+		begin := p.pos
+		for p.pos < len(p.src) && (isAlpha(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		name := p.src[begin:p.pos]
+
+		if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+			p.pos++
+			return Der(name), nil
+		}
+
+		if p.peek() != '(' {
+			return Var(name), nil
+		}
+		if !IsFunc(name) {
+			return nil, fmt.Errorf("unknown function %s", name)
+		}
+		p.pos++
+		return p.call(name)
+
+	}
+
+	return nil, fmt.Errorf("unexpected '%c'", c)
+
+}
+
+func (p *parser) number() (Expr, error) {
+
+	begin := p.pos
+	for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		end := p.pos + 1
+		if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+			end++
+		}
+		if end < len(p.src) && isDigit(p.src[end]) {
+			p.pos = end
+			for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+				p.pos++
+			}
+		}
+	}
+
+	x, err := strconv.ParseFloat(p.src[begin:p.pos], 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad number '%s'", p.src[begin:p.pos])
+	}
+
+	return Num(x), nil
+
+}
+
+func (p *parser) call(fn string) (Expr, error) {
+
+	var args []Expr
+	if p.peek() != ')' {
+		for {
+			a, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.peek() != ')' {
+		return nil, fmt.Errorf("missing ) in call to %s", fn)
+	}
+	p.pos++
+
+	n, ok := arity[fn]
+	if !ok {
+		n = 1
+	}
+	if len(args) != n {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", fn, n, len(args))
+	}
+
+	return Call{fn, args}, nil
+
+}
@@ -0,0 +1,221 @@
+/*
+This file contains the built in integrator, it integrates the explicit
+equations of a model with the classic fourth order Runge-Kutta method
+*/
+package eqn
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+A System is the explicit form of a model. Each state has an expression for
+its derivative and the algebraic variables are kept in an order where each
+one only depends on the ones before it.
+*/
+type System struct {
+	States     []string
+	Derivs     []Expr
+	Algebraics []string
+	AlgExprs   []Expr
+}
+
+/*
+NewSystem sorts the equations of a model into a System. Equations that are
+not of the form x' = ... or y = ... and cycles between algebraic variables
+can not be integrated explicitly and are reported as errors.
+*/
+func NewSystem(eqs []Equation) (*System, error) {
+
+	s := &System{}
+	algs := make(map[string]Expr)
+	var order []string
+
+	for _, e := range eqs {
+		switch l := e.Lhs.(type) {
+		case Der:
+			for _, x := range s.States {
+				if x == string(l) {
+					return nil, fmt.Errorf("%s' is defined twice", x)
+				}
+			}
+			s.States = append(s.States, string(l))
+			s.Derivs = append(s.Derivs, e.Rhs)
+		case Var:
+			if _, ok := algs[string(l)]; ok {
+				return nil, fmt.Errorf("%s is defined twice", string(l))
+			}
+			algs[string(l)] = e.Rhs
+			order = append(order, string(l))
+		default:
+			return nil, fmt.Errorf("'%s' is an implicit equation, only equations "+
+				"of the form x' = ... or y = ... can be integrated", e.Src)
+		}
+	}
+
+	//order the algebraic variables by their dependencies
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var visit func(v string) error
+	visit = func(v string) error {
+		switch state[v] {
+		case visiting:
+			return fmt.Errorf("the algebraic variable %s depends on itself", v)
+		case done:
+			return nil
+		}
+		state[v] = visiting
+		for _, d := range Vars(algs[v]) {
+			if _, ok := algs[d]; ok {
+				err := visit(d)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		state[v] = done
+		s.Algebraics = append(s.Algebraics, v)
+		s.AlgExprs = append(s.AlgExprs, algs[v])
+		return nil
+	}
+	for _, v := range order {
+		err := visit(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+
+}
+
+/*
+Inputs returns the variables the system needs values for that it does not
+define itself, besides time
+*/
+func (s *System) Inputs() []string {
+
+	defined := map[string]bool{"time": true}
+	for _, x := range s.States {
+		defined[x] = true
+		defined[x+"'"] = true
+	}
+	for _, y := range s.Algebraics {
+		defined[y] = true
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, es := range [][]Expr{s.Derivs, s.AlgExprs} {
+		for _, e := range es {
+			for _, v := range Vars(e) {
+				if !defined[v] && !seen[v] {
+					seen[v] = true
+					result = append(result, v)
+				}
+			}
+		}
+	}
+
+	return result
+
+}
+
+/*
+Update computes the algebraic variables and the state derivatives from the
+states held in env
+*/
+func (s *System) Update(env Env) error {
+
+	for i, y := range s.Algebraics {
+		v, err := s.AlgExprs[i].Eval(env)
+		if err != nil {
+			return err
+		}
+		env[y] = v
+	}
+
+	for i, x := range s.States {
+		v, err := s.Derivs[i].Eval(env)
+		if err != nil {
+			return err
+		}
+		env[x+"'"] = v
+	}
+
+	return nil
+
+}
+
+/*
+Integrate advances the states in env from time t0 to t1 in steps of at most
+h. On return env holds the states, algebraic variables and derivatives at t1.
+*/
+func (s *System) Integrate(env Env, t0, t1, h float64) error {
+
+	if h <= 0 {
+		return fmt.Errorf("the step size must be positive")
+	}
+
+	n := len(s.States)
+	x0 := make([]float64, n)
+	k := make([][]float64, 4)
+	for i := range k {
+		k[i] = make([]float64, n)
+	}
+
+	//evaluates the derivatives at time t with the states x0 + a*dk
+	var deriv = func(t, a float64, dk, out []float64) error {
+		env["time"] = t
+		for i, x := range s.States {
+			env[x] = x0[i]
+			if dk != nil {
+				env[x] += a * dk[i]
+			}
+		}
+		err := s.Update(env)
+		if err != nil {
+			return err
+		}
+		for i, x := range s.States {
+			out[i] = env[x+"'"]
+		}
+		return nil
+	}
+
+	t := t0
+	for t < t1 {
+		dt := math.Min(h, t1-t)
+		for i, x := range s.States {
+			x0[i] = env[x]
+		}
+
+		steps := []struct {
+			t, a float64
+			dk   []float64
+		}{{t, 0, nil}, {t + dt/2, dt / 2, k[0]}, {t + dt/2, dt / 2, k[1]}, {t + dt, dt, k[2]}}
+		for j, st := range steps {
+			err := deriv(st.t, st.a, st.dk, k[j])
+			if err != nil {
+				return err
+			}
+		}
+
+		for i, x := range s.States {
+			env[x] = x0[i] + dt/6*(k[0][i]+2*k[1][i]+2*k[2][i]+k[3][i])
+			if math.IsNaN(env[x]) || math.IsInf(env[x], 0) {
+				return fmt.Errorf("%s diverged at time %g", x, t+dt)
+			}
+		}
+		t += dt
+	}
+
+	env["time"] = t1
+	return s.Update(env)
+
+}
@@ -0,0 +1,66 @@
+/*
+This file contains the Graphviz DOT writer
+*/
+package graph
+
+import (
+	"addie"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+func quote(s string) string {
+	return `"` + strings.Replace(strings.Replace(s, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+func (c *cluster) dot(buf *bytes.Buffer, indent string) {
+
+	fmt.Fprintf(buf, "%ssubgraph %s {\n", indent, quote("cluster_"+c.name))
+	fmt.Fprintf(buf, "%s  label=%s;\n", indent, quote(c.name))
+
+	for _, n := range c.nodes {
+		fmt.Fprintf(buf, "%s  %s [label=%s, tooltip=%s, shape=%s, fillcolor=%s];\n",
+			indent, quote(n.id.String()), quote(n.id.Name), quote(n.typ),
+			n.style.shape, n.style.color)
+	}
+	for _, ch := range c.children {
+		ch.dot(buf, indent+"  ")
+	}
+
+	fmt.Fprintf(buf, "%s}\n", indent)
+
+}
+
+/*
+DOT writes a design as an undirected Graphviz graph. Links are solid edges
+labeled with their capacity and latency, plinks are dashed edges labeled with
+their bindings.
+*/
+func DOT(dsg *addie.Design, systems addie.SystemTree) []byte {
+
+	t := build(dsg, systems)
+	buf := new(bytes.Buffer)
+
+	fmt.Fprintf(buf, "graph %s {\n", quote(t.name))
+	buf.WriteString("  node [style=filled, fontname=Helvetica];\n")
+	buf.WriteString("  edge [fontname=Helvetica, fontsize=10];\n")
+
+	for _, c := range t.roots {
+		c.dot(buf, "  ")
+	}
+
+	for _, e := range t.edges {
+		style := "solid"
+		if e.plink {
+			style = "dashed"
+		}
+		fmt.Fprintf(buf, "  %s -- %s [label=%s, style=%s];\n",
+			quote(e.a.String()), quote(e.b.String()), quote(e.label), style)
+	}
+
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
+
+}
@@ -0,0 +1,189 @@
+/*
+The graph package exports the topology of a design as a Graphviz DOT file or
+a GraphML file. The nodes are the elements of the design styled by whether
+they are cyber, cyber-physical or physical, links and plinks are the edges and
+every system becomes a cluster nested like the systems are.
+*/
+package graph
+
+import (
+	"addie"
+	"addie/param"
+	"fmt"
+	"sort"
+)
+
+/*
+The formats Export knows
+*/
+var Formats = []string{"dot", "graphml"}
+
+type style struct {
+	kind, shape, color string
+}
+
+var (
+	cyber         = style{"cyber", "box", "lightblue"}
+	cyberPhysical = style{"cyberPhysical", "hexagon", "palegreen"}
+	physical      = style{"physical", "ellipse", "orange"}
+)
+
+func nodeStyle(e addie.Identify) (style, bool) {
+
+	switch e.(type) {
+	case addie.Computer, addie.Switch, addie.Router:
+		return cyber, true
+	case addie.Sax, addie.Sensor, addie.Actuator:
+		return cyberPhysical, true
+	case addie.Phyo, addie.Trace:
+		return physical, true
+	}
+	return style{}, false
+
+}
+
+type node struct {
+	id    addie.Id
+	typ   string
+	style style
+}
+
+type edge struct {
+	id    addie.Id
+	plink bool
+	a, b  addie.Id
+	label string
+	//links only, in Mbps and ms
+	capacity, latency int
+}
+
+type cluster struct {
+	name     string
+	nodes    []node
+	children []*cluster
+}
+
+type topology struct {
+	name  string
+	roots []*cluster
+	edges []edge
+}
+
+func typeName(e addie.Identify) string {
+	return fmt.Sprintf("%T", e)[len("addie."):]
+}
+
+func linkLabel(capacity, latency int) string {
+	return fmt.Sprintf("%d Mbps, %d ms", capacity, latency)
+}
+
+/*
+build collects the nodes, edges and clusters of a design. Parameter references
+are resolved so labels show values, systems the tree does not know are nested
+in the root system.
+*/
+func build(dsg *addie.Design, systems addie.SystemTree) *topology {
+
+	dsg, _ = param.Resolve(dsg)
+	t := &topology{name: dsg.Name}
+
+	clusters := make(map[string]*cluster)
+	var get = func(name string) *cluster {
+		c, ok := clusters[name]
+		if !ok {
+			c = &cluster{name: name}
+			clusters[name] = c
+		}
+		return c
+	}
+	for s := range systems {
+		get(s)
+	}
+
+	ids := make([]addie.Id, 0, len(dsg.Elements))
+	for id := range dsg.Elements {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	for _, id := range ids {
+		e := dsg.Elements[id]
+		if s, ok := nodeStyle(e); ok {
+			c := get(id.Sys)
+			c.nodes = append(c.nodes, node{id: id, typ: typeName(e), style: s})
+			continue
+		}
+		switch e.(type) {
+		case addie.Link:
+			l := e.(addie.Link)
+			t.edges = append(t.edges, edge{id: id,
+				a: l.Endpoints[0].Id, b: l.Endpoints[1].Id,
+				label:    linkLabel(l.Capacity, l.Latency),
+				capacity: l.Capacity, latency: l.Latency})
+		case addie.Plink:
+			p := e.(addie.Plink)
+			t.edges = append(t.edges, edge{id: id, plink: true,
+				a: p.Endpoints[0], b: p.Endpoints[1],
+				label: p.Bindings[0] + " = " + p.Bindings[1]})
+		}
+	}
+
+	//systems the tree does not know, or whose parent it does not know, are
+	//nested in the root system
+	parents := make(map[string]string)
+	for s := range clusters {
+		sys, known := systems[s]
+		_, parentKnown := systems[sys.Parent]
+		switch {
+		case s == addie.RootSystem || (known && (sys.Parent == "" || sys.Parent == s)):
+			parents[s] = ""
+		case known && parentKnown:
+			parents[s] = sys.Parent
+		default:
+			parents[s] = addie.RootSystem
+		}
+	}
+	for _, p := range parents {
+		if p == addie.RootSystem {
+			get(p)
+		}
+	}
+	if _, ok := clusters[addie.RootSystem]; ok {
+		parents[addie.RootSystem] = ""
+	}
+
+	names := make([]string, 0, len(clusters))
+	for s := range clusters {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+
+	for _, s := range names {
+		if parents[s] == "" {
+			t.roots = append(t.roots, clusters[s])
+			continue
+		}
+		p := clusters[parents[s]]
+		p.children = append(p.children, clusters[s])
+	}
+
+	return t
+
+}
+
+/*
+Export writes a design in one of the Formats
+*/
+func Export(dsg *addie.Design, systems addie.SystemTree, format string) ([]byte, error) {
+
+	switch format {
+	case "dot":
+		return DOT(dsg, systems), nil
+	case "graphml":
+		return GraphML(dsg, systems)
+	}
+
+	return nil, fmt.Errorf("unknown export format %s, it must be one of %v",
+		format, Formats)
+
+}
@@ -0,0 +1,149 @@
+package graph
+
+import (
+	"addie"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+/*
+everyKind has a node of every kind, a system nested in root, a system the tree
+does not know and a link whose capacity is a design parameter
+*/
+func everyKind() *addie.Design {
+
+	dsg := addie.EmptyDesign("talisker")
+	dsg.Params["bw"] = 100
+
+	var c addie.Computer
+	c.Id = addie.Id{Name: "ctl", Sys: "root", Design: dsg.Name}
+	var r addie.Router
+	r.Id = addie.Id{Name: "gw", Sys: "wan", Design: dsg.Name}
+	var s addie.Sax
+	s.Id = addie.Id{Name: "valve", Sys: "root", Design: dsg.Name}
+	var p addie.Phyo
+	p.Id = addie.Id{Name: "boiler", Sys: "plant", Design: dsg.Name}
+
+	var l addie.Link
+	l.Id = addie.Id{Name: "l0", Sys: "root", Design: dsg.Name}
+	l.Endpoints[0].Id = c.Id
+	l.Endpoints[1].Id = r.Id
+	l.CapacityExpr = "$bw"
+	l.Latency = 7
+
+	var pl addie.Plink
+	pl.Id = addie.Id{Name: "pl0", Sys: "root", Design: dsg.Name}
+	pl.Endpoints = [2]addie.Id{s.Id, p.Id}
+	pl.Bindings = [2]string{"y", "T"}
+
+	for _, e := range []addie.Identify{c, r, s, p, l, pl} {
+		dsg.Elements[e.Identify()] = e
+	}
+
+	return &dsg
+
+}
+
+var tree = addie.NewSystemTree([]addie.System{
+	{Name: "root"}, {Name: "wan", Parent: "root"}})
+
+func TestDOT(t *testing.T) {
+
+	out := string(DOT(everyKind(), tree))
+
+	for _, want := range []string{
+		`graph "talisker" {`,
+		`subgraph "cluster_root" {`,
+		`    subgraph "cluster_wan" {`,
+		`    subgraph "cluster_plant" {`,
+		`"ctl.root.talisker" [label="ctl", tooltip="Computer", shape=box, fillcolor=lightblue];`,
+		`"valve.root.talisker" [label="valve", tooltip="Sax", shape=hexagon`,
+		`"boiler.plant.talisker" [label="boiler", tooltip="Phyo", shape=ellipse`,
+		`"ctl.root.talisker" -- "gw.wan.talisker" [label="100 Mbps, 7 ms", style=solid];`,
+		`"valve.root.talisker" -- "boiler.plant.talisker" [label="y = T", style=dashed];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %s in\n%s", want, out)
+		}
+	}
+
+	if strings.Count(out, "cluster_root") != 1 {
+		t.Fatalf("root cluster written more than once\n%s", out)
+	}
+
+	if string(DOT(everyKind(), tree)) != out {
+		t.Fatal("DOT output is not stable")
+	}
+
+}
+
+func TestGraphML(t *testing.T) {
+
+	out, err := Export(everyKind(), tree, "graphml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc graphML
+	err = xml.Unmarshal(out, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Graph.Nodes) != 1 || doc.Graph.Nodes[0].Id != "cluster:root" {
+		t.Fatalf("expected the root system as the only top level node %v", doc.Graph.Nodes)
+	}
+	if len(doc.Graph.Edges) != 2 {
+		t.Fatalf("expected 2 edges got %d", len(doc.Graph.Edges))
+	}
+
+	data := func(ds []gmlData, key string) string {
+		for _, d := range ds {
+			if d.Key == key {
+				return d.Value
+			}
+		}
+		return ""
+	}
+
+	for _, e := range doc.Graph.Edges {
+		switch data(e.Data, "edgeKind") {
+		case "link":
+			if data(e.Data, "capacity") != "100" || data(e.Data, "latency") != "7" {
+				t.Fatalf("bad link data %v", e.Data)
+			}
+		case "plink":
+			if e.Source != "valve.root.talisker" {
+				t.Fatalf("bad plink source %s", e.Source)
+			}
+		default:
+			t.Fatalf("bad edge kind %v", e.Data)
+		}
+	}
+
+	var kinds = make(map[string]string)
+	var walk func(g *gmlGraph)
+	walk = func(g *gmlGraph) {
+		for _, n := range g.Nodes {
+			if n.Graph != nil {
+				walk(n.Graph)
+				continue
+			}
+			kinds[n.Id] = data(n.Data, "kind")
+		}
+	}
+	walk(&doc.Graph)
+
+	if kinds["gw.wan.talisker"] != "cyber" ||
+		kinds["valve.root.talisker"] != "cyberPhysical" ||
+		kinds["boiler.plant.talisker"] != "physical" {
+		t.Fatalf("bad node kinds %v", kinds)
+	}
+
+	_, err = Export(everyKind(), tree, "svg")
+	if err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+
+}
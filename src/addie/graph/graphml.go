@@ -0,0 +1,129 @@
+/*
+This file contains the GraphML writer
+*/
+package graph
+
+import (
+	"addie"
+	"encoding/xml"
+	"strconv"
+)
+
+type gmlKey struct {
+	Id   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type gmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type gmlNode struct {
+	Id    string    `xml:"id,attr"`
+	Data  []gmlData `xml:"data"`
+	Graph *gmlGraph `xml:"graph,omitempty"`
+}
+
+type gmlEdge struct {
+	Id     string    `xml:"id,attr"`
+	Source string    `xml:"source,attr"`
+	Target string    `xml:"target,attr"`
+	Data   []gmlData `xml:"data"`
+}
+
+type gmlGraph struct {
+	Id          string    `xml:"id,attr"`
+	EdgeDefault string    `xml:"edgedefault,attr"`
+	Nodes       []gmlNode `xml:"node"`
+	Edges       []gmlEdge `xml:"edge"`
+}
+
+type graphML struct {
+	XMLName xml.Name `xml:"graphml"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Keys    []gmlKey `xml:"key"`
+	Graph   gmlGraph `xml:"graph"`
+}
+
+var gmlKeys = []gmlKey{
+	{"name", "node", "name", "string"},
+	{"type", "node", "type", "string"},
+	{"kind", "node", "kind", "string"},
+	{"shape", "node", "shape", "string"},
+	{"color", "node", "color", "string"},
+	{"label", "edge", "label", "string"},
+	{"edgeKind", "edge", "kind", "string"},
+	{"capacity", "edge", "capacity", "int"},
+	{"latency", "edge", "latency", "int"},
+}
+
+/*
+gml returns a system as a node holding a nested graph, GraphML has no clusters
+*/
+func (c *cluster) gml() gmlNode {
+
+	g := &gmlGraph{Id: "system:" + c.name, EdgeDefault: "undirected"}
+
+	for _, n := range c.nodes {
+		g.Nodes = append(g.Nodes, gmlNode{Id: n.id.String(), Data: []gmlData{
+			{"name", n.id.Name},
+			{"type", n.typ},
+			{"kind", n.style.kind},
+			{"shape", n.style.shape},
+			{"color", n.style.color},
+		}})
+	}
+	for _, ch := range c.children {
+		g.Nodes = append(g.Nodes, ch.gml())
+	}
+
+	return gmlNode{Id: "cluster:" + c.name,
+		Data:  []gmlData{{"name", c.name}, {"type", "System"}},
+		Graph: g}
+
+}
+
+/*
+GraphML writes a design as a GraphML document. Systems are nodes with nested
+graphs, the kind, shape and color data of a node and the kind of an edge say
+how to style it.
+*/
+func GraphML(dsg *addie.Design, systems addie.SystemTree) ([]byte, error) {
+
+	t := build(dsg, systems)
+
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  gmlKeys,
+		Graph: gmlGraph{Id: t.name, EdgeDefault: "undirected"},
+	}
+
+	for _, c := range t.roots {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, c.gml())
+	}
+
+	for _, e := range t.edges {
+		ge := gmlEdge{Id: e.id.String(), Source: e.a.String(), Target: e.b.String(),
+			Data: []gmlData{{"label", e.label}}}
+		if e.plink {
+			ge.Data = append(ge.Data, gmlData{"edgeKind", "plink"})
+		} else {
+			ge.Data = append(ge.Data,
+				gmlData{"edgeKind", "link"},
+				gmlData{"capacity", strconv.Itoa(e.capacity)},
+				gmlData{"latency", strconv.Itoa(e.latency)})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, ge)
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+
+}
@@ -0,0 +1,305 @@
+/*
+This file contains the graph layout algorithms, they place the nodes of an
+undirected graph in the plane with neighbouring nodes about a spacing apart
+*/
+package layout
+
+import (
+	"math"
+	"sort"
+)
+
+type point struct{ x, y float64 }
+
+type graph struct {
+	n   int
+	adj [][]int
+}
+
+func newGraph(n int, edges [][2]int) *graph {
+
+	g := &graph{n: n, adj: make([][]int, n)}
+	for _, e := range edges {
+		if e[0] == e[1] {
+			continue
+		}
+		g.adj[e[0]] = append(g.adj[e[0]], e[1])
+		g.adj[e[1]] = append(g.adj[e[1]], e[0])
+	}
+	for i := range g.adj {
+		sort.Ints(g.adj[i])
+	}
+
+	return g
+
+}
+
+/*
+components returns the connected components of the graph, each in ascending
+node order and ordered by their first node
+*/
+func (g *graph) components() [][]int {
+
+	seen := make([]bool, g.n)
+	var result [][]int
+	for i := 0; i < g.n; i++ {
+		if seen[i] {
+			continue
+		}
+		c := []int{i}
+		seen[i] = true
+		for k := 0; k < len(c); k++ {
+			for _, j := range g.adj[c[k]] {
+				if !seen[j] {
+					seen[j] = true
+					c = append(c, j)
+				}
+			}
+		}
+		sort.Ints(c)
+		result = append(result, c)
+	}
+
+	return result
+
+}
+
+/*
+sub returns the subgraph induced by the nodes ns, node i of the subgraph is
+node ns[i] of the graph
+*/
+func (g *graph) sub(ns []int) *graph {
+
+	index := make(map[int]int)
+	for i, n := range ns {
+		index[n] = i
+	}
+
+	s := &graph{n: len(ns), adj: make([][]int, len(ns))}
+	for i, n := range ns {
+		for _, j := range g.adj[n] {
+			if k, ok := index[j]; ok {
+				s.adj[i] = append(s.adj[i], k)
+			}
+		}
+	}
+
+	return s
+
+}
+
+/*
+normalize shifts points so the smallest coordinates are 0 and returns the
+width and height they span
+*/
+func normalize(ps []point) (float64, float64) {
+
+	if len(ps) == 0 {
+		return 0, 0
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range ps {
+		minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+		maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+	}
+	for i := range ps {
+		ps[i].x -= minX
+		ps[i].y -= minY
+	}
+
+	return maxX - minX, maxY - minY
+
+}
+
+/*
+place lays out every connected component on its own with the algorithm and
+lines the components up left to right
+*/
+func (g *graph) place(algorithm func(*graph, float64, int) []point,
+	spacing float64, iterations int) ([]point, float64, float64) {
+
+	result := make([]point, g.n)
+	x, height := 0.0, 0.0
+
+	for _, c := range g.components() {
+		ps := algorithm(g.sub(c), spacing, iterations)
+		w, h := normalize(ps)
+		for i, n := range c {
+			result[n] = point{ps[i].x + x, ps[i].y}
+		}
+		x += w + spacing
+		height = math.Max(height, h)
+	}
+
+	if g.n == 0 {
+		return result, 0, 0
+	}
+
+	return result, x - spacing, height
+
+}
+
+/*
+force is the Fruchterman-Reingold layout, neighbours attract and every pair of
+nodes repels until the layout cools down. The nodes start on a circle so the
+result is deterministic.
+*/
+func force(g *graph, spacing float64, iterations int) []point {
+
+	ps := make([]point, g.n)
+	if g.n < 2 {
+		return ps
+	}
+
+	r := math.Max(spacing, spacing*float64(g.n)/(2*math.Pi))
+	for i := range ps {
+		a := 2 * math.Pi * float64(i) / float64(g.n)
+		ps[i] = point{r * math.Cos(a), r * math.Sin(a)}
+	}
+
+	k := spacing
+	temperature := r / 2
+	disp := make([]point, g.n)
+
+	for it := 0; it < iterations; it++ {
+		for i := range disp {
+			disp[i] = point{}
+		}
+
+		for i := 0; i < g.n; i++ {
+			for j := i + 1; j < g.n; j++ {
+				dx, dy := ps[i].x-ps[j].x, ps[i].y-ps[j].y
+				d := math.Max(math.Hypot(dx, dy), 0.01)
+				f := k * k / d
+				disp[i].x += dx / d * f
+				disp[i].y += dy / d * f
+				disp[j].x -= dx / d * f
+				disp[j].y -= dy / d * f
+			}
+		}
+
+		for i := 0; i < g.n; i++ {
+			for _, j := range g.adj[i] {
+				if j < i {
+					continue
+				}
+				dx, dy := ps[i].x-ps[j].x, ps[i].y-ps[j].y
+				d := math.Max(math.Hypot(dx, dy), 0.01)
+				f := d * d / k
+				disp[i].x -= dx / d * f
+				disp[i].y -= dy / d * f
+				disp[j].x += dx / d * f
+				disp[j].y += dy / d * f
+			}
+		}
+
+		for i := range ps {
+			d := math.Hypot(disp[i].x, disp[i].y)
+			if d == 0 {
+				continue
+			}
+			step := math.Min(d, temperature)
+			ps[i].x += disp[i].x / d * step
+			ps[i].y += disp[i].y / d * step
+		}
+
+		temperature *= 1 - 1/float64(iterations)
+	}
+
+	return ps
+
+}
+
+/*
+layered puts the nodes in breadth first layers from the node with the most
+neighbours and orders each layer by the mean position of the neighbours in the
+layers around it to cut down on crossings
+*/
+func layered(g *graph, spacing float64, iterations int) []point {
+
+	ps := make([]point, g.n)
+	if g.n == 0 {
+		return ps
+	}
+
+	root := 0
+	for i := range g.adj {
+		if len(g.adj[i]) > len(g.adj[root]) {
+			root = i
+		}
+	}
+
+	depth := make([]int, g.n)
+	for i := range depth {
+		depth[i] = -1
+	}
+	depth[root] = 0
+	layers := [][]int{{root}}
+	for d := 0; d < len(layers); d++ {
+		var next []int
+		for _, i := range layers[d] {
+			for _, j := range g.adj[i] {
+				if depth[j] < 0 {
+					depth[j] = d + 1
+					next = append(next, j)
+				}
+			}
+		}
+		if len(next) > 0 {
+			layers = append(layers, next)
+		}
+	}
+
+	order := make([]float64, g.n)
+	var index = func() {
+		for _, l := range layers {
+			for k, i := range l {
+				order[i] = float64(k) - float64(len(l)-1)/2
+			}
+		}
+	}
+	index()
+
+	var sweep = func(d, from int) {
+		bary := make(map[int]float64)
+		for _, i := range layers[d] {
+			sum, n := 0.0, 0
+			for _, j := range g.adj[i] {
+				if depth[j] == from {
+					sum += order[j]
+					n++
+				}
+			}
+			bary[i] = order[i]
+			if n > 0 {
+				bary[i] = sum / float64(n)
+			}
+		}
+		sort.SliceStable(layers[d], func(a, b int) bool {
+			return bary[layers[d][a]] < bary[layers[d][b]]
+		})
+		index()
+	}
+
+	passes := iterations
+	if passes > 8 {
+		passes = 8
+	}
+	for p := 0; p < passes; p++ {
+		for d := 1; d < len(layers); d++ {
+			sweep(d, d-1)
+		}
+		for d := len(layers) - 2; d >= 0; d-- {
+			sweep(d, d+1)
+		}
+	}
+
+	for i := range ps {
+		ps[i] = point{order[i] * spacing, float64(depth[i]) * spacing}
+	}
+
+	return ps
+
+}
@@ -0,0 +1,389 @@
+/*
+The layout package computes positions for the elements of a design and
+orthogonal paths for its links. Every system is laid out in its own box with
+the boxes of its subsystems below its own elements. Inside a system the cyber
+elements, the saxes and the physical elements are kept in separate bands from
+left to right.
+*/
+package layout
+
+import (
+	"addie"
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+The layout algorithms, the first is the default
+*/
+var Algorithms = []string{"force", "layered"}
+
+type Options struct {
+	Algorithm string  `json:"algorithm"`
+	Spacing   float64 `json:"spacing"`
+	//the force iterations or the layered ordering passes
+	Iterations int `json:"iterations"`
+}
+
+/*
+The most force iterations a layout runs, each costs time quadratic in the
+elements of a system
+*/
+const maxIterations = 2000
+
+/*
+A Result holds the computed position of every positioned element and the bend
+points of every link
+*/
+type Result struct {
+	Positions map[addie.Id]addie.Position
+	Paths     map[addie.Id][]addie.Position
+}
+
+const (
+	cyber = iota
+	cyberPhysical
+	physical
+)
+
+/*
+band returns the band an element is laid out in and whether it has a position
+at all
+*/
+func band(e addie.Identify) (int, bool) {
+
+	switch e.(type) {
+	case addie.Computer, addie.Switch, addie.Router:
+		return cyber, true
+	case addie.Sax, addie.Sensor, addie.Actuator:
+		return cyberPhysical, true
+	case addie.Phyo, addie.Trace:
+		return physical, true
+	}
+	return 0, false
+
+}
+
+type box struct {
+	w, h      float64
+	positions map[addie.Id]point
+}
+
+type layouter struct {
+	dsg       *addie.Design
+	opts      Options
+	algorithm func(*graph, float64, int) []point
+	members   map[string][]addie.Id
+	children  map[string][]string
+	neighbors map[addie.Id][]addie.Id
+}
+
+/*
+Layout lays out a design whose systems are nested as in systems, systems the
+tree does not know sit directly in the root system
+*/
+func Layout(dsg *addie.Design, systems addie.SystemTree, opts Options) (*Result, error) {
+
+	l := &layouter{dsg: dsg, opts: opts,
+		members:   make(map[string][]addie.Id),
+		children:  make(map[string][]string),
+		neighbors: make(map[addie.Id][]addie.Id),
+	}
+
+	switch opts.Algorithm {
+	case "", "force":
+		l.algorithm = force
+	case "layered":
+		l.algorithm = layered
+	default:
+		return nil, fmt.Errorf("unknown layout algorithm %s", opts.Algorithm)
+	}
+	if l.opts.Spacing <= 0 {
+		l.opts.Spacing = 100
+	}
+	if l.opts.Iterations <= 0 {
+		l.opts.Iterations = 300
+	}
+	if l.opts.Iterations > maxIterations {
+		l.opts.Iterations = maxIterations
+	}
+
+	//every system the design uses, nested according to the tree
+	known := make(map[string]bool)
+	for s := range systems {
+		known[s] = true
+	}
+	for id, e := range dsg.Elements {
+		if _, ok := band(e); ok {
+			l.members[id.Sys] = append(l.members[id.Sys], id)
+			known[id.Sys] = true
+		}
+		switch e.(type) {
+		case addie.Link:
+			x := e.(addie.Link)
+			l.connect(x.Endpoints[0].Id, x.Endpoints[1].Id)
+		case addie.Plink:
+			x := e.(addie.Plink)
+			l.connect(x.Endpoints[0], x.Endpoints[1])
+		}
+	}
+
+	var tops []string
+	for s := range known {
+		parent := systems[s].Parent
+		if s == addie.RootSystem || parent == s {
+			parent = ""
+		} else if !known[parent] {
+			parent = addie.RootSystem
+		}
+		if parent == "" {
+			tops = append(tops, s)
+			continue
+		}
+		l.children[parent] = append(l.children[parent], s)
+	}
+	if !known[addie.RootSystem] && len(l.children[addie.RootSystem]) > 0 {
+		tops = append(tops, addie.RootSystem)
+	}
+	sort.Strings(tops)
+	for s := range l.children {
+		sort.Strings(l.children[s])
+	}
+
+	result := &Result{
+		Positions: make(map[addie.Id]addie.Position),
+		Paths:     make(map[addie.Id][]addie.Position),
+	}
+
+	x := 0.0
+	for _, s := range tops {
+		b := l.system(s, make(map[string]bool))
+		for id, p := range b.positions {
+			result.Positions[id] = addie.Position{X: float32(p.x + x), Y: float32(p.y)}
+		}
+		x += b.w + 2*l.opts.Spacing
+	}
+
+	for id, e := range dsg.Elements {
+		if link, ok := e.(addie.Link); ok {
+			a, aok := result.Positions[link.Endpoints[0].Id]
+			b, bok := result.Positions[link.Endpoints[1].Id]
+			if aok && bok {
+				result.Paths[id] = orthogonal(a, b)
+			}
+		}
+	}
+
+	return result, nil
+
+}
+
+func (l *layouter) connect(a, b addie.Id) {
+
+	l.neighbors[a] = append(l.neighbors[a], b)
+	l.neighbors[b] = append(l.neighbors[b], a)
+
+}
+
+/*
+system lays out the elements of a system in their bands and the boxes of its
+subsystems in a row beneath them, positions are relative to the box
+*/
+func (l *layouter) system(sys string, visiting map[string]bool) box {
+
+	visiting[sys] = true
+	result := box{positions: make(map[addie.Id]point)}
+	spacing := l.opts.Spacing
+
+	bands := make([][]addie.Id, 3)
+	for _, id := range l.members[sys] {
+		b, _ := band(l.dsg.Elements[id])
+		bands[b] = append(bands[b], id)
+	}
+
+	x := 0.0
+	for _, ids := range bands {
+		if len(ids) == 0 {
+			continue
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i].Name < ids[j].Name })
+
+		index := make(map[addie.Id]int)
+		for i, id := range ids {
+			index[id] = i
+		}
+		var edges [][2]int
+		for i, id := range ids {
+			for _, n := range l.neighbors[id] {
+				if j, ok := index[n]; ok && i < j {
+					edges = append(edges, [2]int{i, j})
+				}
+			}
+		}
+
+		ps, w, h := newGraph(len(ids), edges).place(l.algorithm, spacing,
+			l.opts.Iterations)
+		for i, id := range ids {
+			result.positions[id] = point{ps[i].x + x, ps[i].y}
+		}
+		x += w + spacing
+		result.w = x - spacing
+		result.h = math.Max(result.h, h)
+	}
+
+	y := 0.0
+	if len(result.positions) > 0 {
+		y = result.h + 2*spacing
+	}
+	cx := 0.0
+	for _, c := range l.children[sys] {
+		if visiting[c] {
+			continue
+		}
+		b := l.system(c, visiting)
+		for id, p := range b.positions {
+			result.positions[id] = point{p.x + cx, p.y + y}
+		}
+		cx += b.w + 2*spacing
+		result.w = math.Max(result.w, cx-2*spacing)
+		result.h = math.Max(result.h, y+b.h)
+	}
+
+	return result
+
+}
+
+/*
+orthogonal returns the bend points of a link drawn with horizontal and vertical
+segments, it leaves the source vertically and turns halfway to the target
+*/
+func orthogonal(a, b addie.Position) []addie.Position {
+
+	if a.X == b.X || a.Y == b.Y {
+		return []addie.Position{}
+	}
+
+	y := (a.Y + b.Y) / 2
+	return []addie.Position{{X: a.X, Y: y, Z: a.Z}, {X: b.X, Y: y, Z: b.Z}}
+
+}
+
+/*
+Apply returns copies of the elements of a design that a layout moves, with
+their new positions and paths. The design itself is not changed.
+*/
+func Apply(dsg *addie.Design, r *Result) []addie.Identify {
+
+	var result []addie.Identify
+
+	ids := make([]addie.Id, 0, len(dsg.Elements))
+	for id := range dsg.Elements {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	for _, id := range ids {
+		e := dsg.Elements[id]
+		if path, ok := r.Paths[id]; ok {
+			l := e.(addie.Link)
+			if !samePath(l.Path, path) {
+				l.Path = path
+				result = append(result, l)
+			}
+			continue
+		}
+		p, ok := r.Positions[id]
+		if !ok {
+			continue
+		}
+		if moved, ok := withPosition(e, p); ok {
+			result = append(result, moved)
+		}
+	}
+
+	return result
+
+}
+
+func samePath(a, b []addie.Position) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+
+}
+
+/*
+move moves a position to p in the plane keeping its height, it reports
+whether the position changed
+*/
+func move(pos *addie.Position, p addie.Position) bool {
+
+	p.Z = pos.Z
+	if *pos == p {
+		return false
+	}
+	*pos = p
+	return true
+
+}
+
+/*
+withPosition returns a copy of a positioned element moved to p, it reports
+false when the element does not move
+*/
+func withPosition(e addie.Identify, p addie.Position) (addie.Identify, bool) {
+
+	switch e.(type) {
+	case addie.Computer:
+		x := e.(addie.Computer)
+		if move(&x.Position, p) {
+			return x, true
+		}
+	case addie.Switch:
+		x := e.(addie.Switch)
+		if move(&x.Position, p) {
+			return x, true
+		}
+	case addie.Router:
+		x := e.(addie.Router)
+		if move(&x.Position, p) {
+			return x, true
+		}
+	case addie.Phyo:
+		x := e.(addie.Phyo)
+		if move(&x.Position, p) {
+			return x, true
+		}
+	case addie.Trace:
+		x := e.(addie.Trace)
+		if move(&x.Position, p) {
+			return x, true
+		}
+	case addie.Sax:
+		x := e.(addie.Sax)
+		if move(&x.Position, p) {
+			return x, true
+		}
+	case addie.Sensor:
+		x := e.(addie.Sensor)
+		if move(&x.Position, p) {
+			return x, true
+		}
+	case addie.Actuator:
+		x := e.(addie.Actuator)
+		if move(&x.Position, p) {
+			return x, true
+		}
+	}
+
+	return nil, false
+
+}
@@ -0,0 +1,139 @@
+package layout
+
+import (
+	"addie"
+	"addie/topo"
+	"math"
+	"testing"
+)
+
+/*
+banded has elements in every band, a cyber star in root and a ring in wan
+which nests in root, then a sax controlling a phyo
+*/
+func banded(t *testing.T) *addie.Design {
+
+	dsg := addie.EmptyDesign("laphroaig")
+
+	for _, g := range []struct {
+		spec topo.Spec
+		sys  string
+	}{
+		{topo.Spec{Shape: "star", Nodes: 4}, "root"},
+		{topo.Spec{Shape: "ring", Nodes: 4}, "wan"},
+	} {
+		es, err := topo.Generate(g.spec, dsg.Name, g.sys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range es {
+			dsg.Elements[e.Identify()] = e
+		}
+	}
+
+	var s addie.Sax
+	s.Id = addie.Id{Name: "valve", Sys: "root", Design: dsg.Name}
+	var p addie.Phyo
+	p.Id = addie.Id{Name: "boiler", Sys: "root", Design: dsg.Name}
+	var pl addie.Plink
+	pl.Id = addie.Id{Name: "pl", Sys: "root", Design: dsg.Name}
+	pl.Endpoints = [2]addie.Id{s.Id, p.Id}
+	for _, e := range []addie.Identify{s, p, pl} {
+		dsg.Elements[e.Identify()] = e
+	}
+
+	return &dsg
+
+}
+
+func TestLayout(t *testing.T) {
+
+	tree := addie.NewSystemTree([]addie.System{
+		{Name: "root"}, {Name: "wan", Parent: "root"}})
+
+	for _, algorithm := range Algorithms {
+		dsg := banded(t)
+		res, err := Layout(dsg, tree, Options{Algorithm: algorithm, Spacing: 50})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var cyberX, saxX, physX float32 = -1, 0, 0
+		var rootBottom, wanTop float32 = 0, math.MaxFloat32
+		for id, e := range dsg.Elements {
+			p, ok := res.Positions[id]
+			if _, positioned := band(e); positioned != ok {
+				t.Fatalf("%s: %s positioned %v", algorithm, id, ok)
+			}
+			if !ok {
+				continue
+			}
+			if id.Sys == "wan" {
+				wanTop = float32(math.Min(float64(wanTop), float64(p.Y)))
+				continue
+			}
+			rootBottom = float32(math.Max(float64(rootBottom), float64(p.Y)))
+			switch e.(type) {
+			case addie.Sax:
+				saxX = p.X
+			case addie.Phyo:
+				physX = p.X
+			default:
+				cyberX = float32(math.Max(float64(cyberX), float64(p.X)))
+			}
+		}
+
+		//cyber, cyber-physical and physical bands from left to right
+		if !(cyberX < saxX && saxX < physX) {
+			t.Fatalf("%s: bands out of order %v %v %v", algorithm, cyberX, saxX, physX)
+		}
+		//the wan subsystem sits below the root system
+		if !(rootBottom < wanTop) {
+			t.Fatalf("%s: subsystem overlaps its parent %v %v", algorithm, rootBottom, wanTop)
+		}
+
+		//link paths are made of horizontal and vertical segments
+		for id, path := range res.Paths {
+			l := dsg.Elements[id].(addie.Link)
+			pts := append([]addie.Position{res.Positions[l.Endpoints[0].Id]}, path...)
+			pts = append(pts, res.Positions[l.Endpoints[1].Id])
+			for i := 1; i < len(pts); i++ {
+				if pts[i].X != pts[i-1].X && pts[i].Y != pts[i-1].Y {
+					t.Fatalf("%s: link %s is not orthogonal %v", algorithm, id.Name, pts)
+				}
+			}
+		}
+
+		moved := Apply(dsg, res)
+		for _, e := range moved {
+			dsg.Elements[e.Identify()] = e
+		}
+		if len(moved) == 0 || len(Apply(dsg, res)) != 0 {
+			t.Fatalf("%s: applying a layout is not idempotent", algorithm)
+		}
+	}
+
+	if _, err := Layout(banded(t), tree, Options{Algorithm: "spiral"}); err == nil {
+		t.Fatal("an unknown algorithm was accepted")
+	}
+
+	//a huge iteration count is clamped rather than run
+	if _, err := Layout(banded(t), tree, Options{Iterations: 1 << 40}); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestForceSpacing(t *testing.T) {
+
+	//the nodes of a path end up roughly a spacing apart
+	g := newGraph(3, [][2]int{{0, 1}, {1, 2}})
+	ps, _, _ := g.place(force, 100, 300)
+	for _, e := range [][2]int{{0, 1}, {1, 2}} {
+		d := math.Hypot(ps[e[0]].x-ps[e[1]].x, ps[e[0]].y-ps[e[1]].y)
+		if d < 50 || d > 200 {
+			t.Fatalf("neighbours are %v apart", d)
+		}
+	}
+
+}
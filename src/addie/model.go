@@ -5,6 +5,9 @@ package addie
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 )
 
 type Id struct {
@@ -32,6 +35,7 @@ type Identify interface {
 type NetHost struct {
 	Id
 	Interfaces map[string]Interface `json:"interfaces"`
+	Attributes Attributes           `json:"attributes"`
 }
 
 func (h *NetHost) Equals(x *NetHost) bool {
@@ -50,7 +54,7 @@ func (h *NetHost) Equals(x *NetHost) bool {
 		}
 	}
 
-	return true
+	return h.Attributes.Equals(x.Attributes)
 }
 
 type Interface struct {
@@ -82,9 +86,16 @@ func (c *Computer) SSHC(user, dsg string) string {
 	return cmd
 }
 
+/*
+A PacketConductor carries packets at Capacity with a Latency. When an
+expression over the design parameters is given it takes the place of the
+value at compile time.
+*/
 type PacketConductor struct {
-	Capacity int `json:"capacity"`
-	Latency  int `json:"latency"`
+	Capacity     int    `json:"capacity"`
+	Latency      int    `json:"latency"`
+	CapacityExpr string `json:"capacityExpr"`
+	LatencyExpr  string `json:"latencyExpr"`
 }
 
 type Switch struct {
@@ -123,6 +134,25 @@ func (r *Router) Equals(x *Router) bool {
 		r.Position == x.Position
 }
 
+/*
+A NodeProfile is a kind of node from the catalog the testbed offers, with its
+hardware type, operating system image, container template and startup script.
+Computers, routers and saxes choose a profile with their profile attribute and
+the OSID of every profile is an image a computer may name as its OS.
+*/
+type NodeProfile struct {
+	Name      string `json:"name"`
+	Hardware  string `json:"hardware"`
+	OSID      string `json:"osid"`
+	Container string `json:"container"`
+	Startup   string `json:"startup"`
+}
+
+/*
+The attribute that names the node profile of a computer, router or sax
+*/
+const ProfileAttribute = "profile"
+
 type NetIfRef struct {
 	Id
 	IfName string `json:"ifname"`
@@ -132,7 +162,8 @@ type Link struct {
 	Id
 	Path []Position `json:"path"`
 	PacketConductor
-	Endpoints [2]NetIfRef `json:"endpoints"`
+	Endpoints  [2]NetIfRef `json:"endpoints"`
+	Attributes Attributes  `json:"attributes"`
 }
 
 func (l Link) Identify() Id { return l.Id }
@@ -141,41 +172,220 @@ func (l *Link) Equals(x *Link) bool {
 
 	return l.Id == x.Id &&
 		l.PacketConductor == x.PacketConductor &&
-		l.Endpoints == x.Endpoints
+		l.Endpoints == x.Endpoints &&
+		l.Attributes.Equals(x.Attributes)
 
 }
 
 //Physical---------------------------------------------------------------------
 
 type Model struct {
-	Name      string `json:"name"`
-	Equations string `json:"equations"`
-	Params    string `json:"params"`
-	Icon      string `json:"icon"`
+	Name      string      `json:"name"`
+	Equations string      `json:"equations"`
+	Params    string      `json:"params"`
+	Icon      string      `json:"icon"`
+	Tests     []ModelTest `json:"tests"`
+}
+
+/*
+A ModelTest is a small test case that is run against a model whenever it is
+saved. The model is integrated from the initial state Init for Duration
+seconds with the parameters Params and the inputs held at the values in
+Inputs, the values of variables at given times are checked against Expect.
+*/
+type ModelTest struct {
+	Name     string             `json:"name"`
+	Params   map[string]float64 `json:"params"`
+	Init     map[string]float64 `json:"init"`
+	Inputs   map[string]float64 `json:"inputs"`
+	Duration float64            `json:"duration"`
+	//the integration step, 0 selects one thousandth of the duration
+	Step   float64       `json:"step"`
+	Expect []Expectation `json:"expect"`
+}
+
+type Expectation struct {
+	Var   string  `json:"var"`
+	Time  float64 `json:"time"`
+	Value float64 `json:"value"`
+	Tol   float64 `json:"tol"`
 }
 
 func (m Model) Identify() Id { return Id{Name: m.Name, Sys: "", Design: ""} }
 
+var stateRx = regexp.MustCompile("([a-zA-Z_][a-zA-Z0-9_]*)'")
+
+/*
+States returns the state variables of a model, these are the variables whose
+derivatives appear in its equations, in order of first appearance.
+*/
+func (m *Model) States() []string {
+
+	var xs []string
+	seen := make(map[string]bool)
+	for _, s := range stateRx.FindAllStringSubmatch(m.Equations, -1) {
+		if !seen[s[1]] {
+			seen[s[1]] = true
+			xs = append(xs, s[1])
+		}
+	}
+
+	return xs
+
+}
+
+/*
+A ModelVersion is an immutable snapshot of a model taken each time the model
+is saved, versions are numbered from 1 in the order they were saved.
+*/
+type ModelVersion struct {
+	Model
+	Version int       `json:"version"`
+	Author  string    `json:"author"`
+	Saved   time.Time `json:"saved"`
+}
+
+/*
+A ModelUsage records that Phyos phyos of a design use a model. Compiled is the
+version the design was last compiled with, 0 if it was not compiled since it
+started using the model.
+*/
+type ModelUsage struct {
+	Design   string `json:"design"`
+	Phyos    int    `json:"phyos"`
+	Compiled int    `json:"compiled"`
+}
+
+/*
+A ModelRef refers to a model another user published, it is written as
+scope/name@version in the Model of a phyo. A reference without a version
+follows the latest published version of the model.
+*/
+type ModelRef struct {
+	Scope   string `json:"scope"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+/*
+ParseModelRef parses a published model reference, plain model names from the
+user's own library are not references.
+*/
+func ParseModelRef(s string) (ModelRef, bool) {
+
+	var r ModelRef
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return r, false
+	}
+
+	r.Scope, r.Name = s[:i], s[i+1:]
+	if j := strings.Index(r.Name, "@"); j >= 0 {
+		r.Name, r.Version = r.Name[:j], r.Name[j+1:]
+	}
+
+	return r, r.Scope != "" && r.Name != ""
+
+}
+
+func (r ModelRef) String() string {
+	if r.Version == "" {
+		return r.Scope + "/" + r.Name
+	}
+	return r.Scope + "/" + r.Name + "@" + r.Version
+}
+
+func (r ModelRef) Pinned() bool { return r.Version != "" }
+
+var nonIdentRx = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+/*
+ModelIdent returns the identifier a model is simulated and exported under.
+Published model references like team/Rotor@1.0 are not identifiers, they are
+named after the reference instead.
+*/
+func ModelIdent(name string) string {
+
+	if _, ok := ParseModelRef(name); ok {
+		return nonIdentRx.ReplaceAllString(name, "_")
+	}
+
+	return name
+
+}
+
+/*
+A PublishedModel is an immutable version of a model published to a scope.
+The global scope is readable by every user, any other scope is a team whose
+members may read and publish to it.
+*/
+type PublishedModel struct {
+	Model
+	Scope     string    `json:"scope"`
+	Version   string    `json:"version"`
+	Publisher string    `json:"publisher"`
+	Published time.Time `json:"published"`
+}
+
+func (p PublishedModel) Ref() ModelRef {
+	return ModelRef{Scope: p.Scope, Name: p.Name, Version: p.Version}
+}
+
 type Phyo struct {
 	Id
-	Position Position `json:"position"`
-	Model    string   `json:"model"`
-	Args     string   `json:"args"`
-	Init     string   `json:"init"`
+	Position   Position   `json:"position"`
+	Model      string     `json:"model"`
+	Args       string     `json:"args"`
+	Init       string     `json:"init"`
+	Attributes Attributes `json:"attributes"`
 }
 
 func (p Phyo) Identify() Id { return p.Id }
 
+/*
+An InitSet is a named set of initial conditions for the physical objects of a
+design, like a cold start or a steady state. Choosing a set at compile or run
+time replaces the initial values in the Init of the referenced phyos.
+*/
+type InitSet struct {
+	Name  string     `json:"name"`
+	Inits []PhyoInit `json:"inits"`
+}
+
+type PhyoInit struct {
+	Phyo   Id                 `json:"phyo"`
+	Values map[string]float64 `json:"values"`
+}
+
 type Binding [2]string
 
 type Plink struct {
 	Id
-	Endpoints [2]Id     `json:"endpoints"`
-	Bindings  [2]string `json:"bindings"`
+	Endpoints  [2]Id      `json:"endpoints"`
+	Bindings   [2]string  `json:"bindings"`
+	Attributes Attributes `json:"attributes"`
 }
 
 func (p Plink) Identify() Id { return p.Id }
 
+/*
+A Trace replays recorded plant measurements into a simulation in place of a
+simulated physical object. The first of the comma separated Columns is the
+time column, the remaining columns are the signals a plink may bind to.
+*/
+type Trace struct {
+	Id
+	Position      Position   `json:"position"`
+	File          string     `json:"file"`
+	Format        string     `json:"format"`
+	Columns       string     `json:"columns"`
+	Interpolation string     `json:"interpolation"`
+	Loop          bool       `json:"loop"`
+	Attributes    Attributes `json:"attributes"`
+}
+
+func (t Trace) Identify() Id { return t.Id }
+
 //Cyber-Physical---------------------------------------------------------------
 
 type Target struct {
@@ -185,9 +395,10 @@ type Target struct {
 
 type Sensor struct {
 	Id
-	Position Position `json:"position"`
-	Target   Target   `json:"target"`
-	Rate     uint     `json:"rate"`
+	Position   Position   `json:"position"`
+	Target     Target     `json:"target"`
+	Rate       uint       `json:"rate"`
+	Attributes Attributes `json:"attributes"`
 }
 
 func (s Sensor) Identify() Id { return s.Id }
@@ -196,9 +407,10 @@ type Bound struct{ Min, Max float64 }
 type Actuator struct {
 	Id
 	Position     Position
-	Target       Target `json:"target"`
-	StaticLimit  Bound  `json:"static_limit"`
-	DynamicLimit Bound  `json:"dynamic_limit"`
+	Target       Target     `json:"target"`
+	StaticLimit  Bound      `json:"static_limit"`
+	DynamicLimit Bound      `json:"dynamic_limit"`
+	Attributes   Attributes `json:"attributes"`
 }
 
 func (a Actuator) Identify() Id { return a.Id }
@@ -221,6 +433,8 @@ func (s *Sax) SSHC(user, dsg string) string {
 type Design struct {
 	Name     string          `json:"name"`
 	Elements map[Id]Identify `json:"elements"`
+	//the design parameters element fields refer to as $name
+	Params map[string]float64 `json:"params"`
 }
 
 func (d *Design) String() string {
@@ -238,6 +452,7 @@ func EmptyDesign(name string) Design {
 	var m Design
 	m.Name = name
 	m.Elements = make(map[Id]Identify)
+	m.Params = make(map[string]float64)
 	return m
 }
 
@@ -258,8 +473,39 @@ func (d *Design) Routers() []*Router {
 
 //Settings---------------------------------------------------------------------
 
+/*
+The integrators the Cypress simulation runtime provides, the first is the
+default
+*/
+var Solvers = []string{"ida", "cvode", "rk45", "euler"}
+
 type SimSettings struct {
-	Begin   float64 `json:"begin"`
-	End     float64 `json:"end"`
-	MaxStep float64 `json:"maxStep"`
+	Begin          float64 `json:"begin"`
+	End            float64 `json:"end"`
+	MaxStep        float64 `json:"maxStep"`
+	ClusterSize    int     `json:"clusterSize"`
+	Solver         string  `json:"solver"`
+	AbsTol         float64 `json:"absTol"`
+	RelTol         float64 `json:"relTol"`
+	SampleInterval float64 `json:"sampleInterval"`
+	Record         string  `json:"record"`
+	RealTime       bool    `json:"realTime"`
+	RealTimeRatio  float64 `json:"realTimeRatio"`
+	//simulation seconds between state snapshots, 0 disables checkpointing
+	CheckpointInterval float64 `json:"checkpointInterval"`
+	//the initial condition set compiled into the simulation, if any
+	InitSet string `json:"initSet"`
+}
+
+/*
+A SimRun records one execution of a design's simulation. Runs that were
+started from a checkpoint record it in ResumedFrom along with any parameters
+that were changed for the run.
+*/
+type SimRun struct {
+	Id          int                `json:"id"`
+	Started     time.Time          `json:"started"`
+	ResumedFrom string             `json:"resumedFrom"`
+	Params      map[string]float64 `json:"params"`
+	InitSet     string             `json:"initSet"`
 }
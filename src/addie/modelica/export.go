@@ -0,0 +1,295 @@
+/*
+The modelica package translates between addie physical models and Modelica.
+This file contains the exporter, which turns user models into Modelica model
+classes and the physical half of a design into a Modelica system model.
+*/
+package modelica
+
+import (
+	"addie"
+	"addie/sema"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var identRx = regexp.MustCompile("[a-zA-Z_][a-zA-Z0-9_]*(\\s*\\()?")
+var derRx = regexp.MustCompile("([a-zA-Z_][a-zA-Z0-9_]*)'")
+
+/*
+Names that are part of the equation language rather than model variables
+*/
+var builtins = map[string]bool{"time": true, "pi": true, "e": true}
+
+/*
+Vars classifies the variables of a model. States have their derivative
+defined by an equation, Algebraics are defined by an equation of the form
+y = ..., and Inputs are everything else that is neither a parameter nor
+defined by the model, these are driven from outside through plinks.
+*/
+type Vars struct {
+	Params     []string
+	States     []string
+	Algebraics []string
+	Inputs     []string
+}
+
+func equations(m *addie.Model) []string {
+
+	var es []string
+	for _, e := range strings.Split(m.Equations, "\n") {
+		e = strings.TrimSpace(e)
+		if e != "" {
+			es = append(es, e)
+		}
+	}
+
+	return es
+
+}
+
+/*
+The ModelVars function classifies the variables of a model, every list is in
+order of first appearance.
+*/
+func ModelVars(m *addie.Model) Vars {
+
+	var v Vars
+	v.Params = sema.ModelParams(*m)
+	v.States = m.States()
+
+	known := make(map[string]bool)
+	for _, x := range v.Params {
+		known[x] = true
+	}
+	for _, x := range v.States {
+		known[x] = true
+	}
+
+	es := equations(m)
+
+	for _, e := range es {
+		lhs := strings.TrimSpace(strings.SplitN(e, "=", 2)[0])
+		if identRx.FindString(lhs) == lhs && !known[lhs] && !builtins[lhs] {
+			known[lhs] = true
+			v.Algebraics = append(v.Algebraics, lhs)
+		}
+	}
+
+	for _, e := range es {
+		for _, ix := range identRx.FindAllStringIndex(e, -1) {
+			id := e[ix[0]:ix[1]]
+			//exponents of numeric literals like 1e-3 are not identifiers
+			if ix[0] > 0 && strings.ContainsAny(e[ix[0]-1:ix[0]], "0123456789.") {
+				continue
+			}
+			if strings.HasSuffix(id, "(") || known[id] || builtins[id] {
+				continue
+			}
+			known[id] = true
+			v.Inputs = append(v.Inputs, id)
+		}
+	}
+
+	return v
+
+}
+
+/*
+The Equation function translates a single addie equation into Modelica
+*/
+func Equation(e string) string {
+	return derRx.ReplaceAllString(strings.TrimSpace(e), "der($1)") + ";"
+}
+
+/*
+The ExportModel function translates a user model into a Modelica model class
+*/
+func ExportModel(m *addie.Model) string {
+
+	v := ModelVars(m)
+
+	src := "model " + addie.ModelIdent(m.Name) + "\n"
+	for _, p := range v.Params {
+		src += "  parameter Real " + p + ";\n"
+	}
+	for _, x := range v.States {
+		src += "  Real " + x + ";\n"
+	}
+	for _, x := range v.Algebraics {
+		src += "  Real " + x + ";\n"
+	}
+	for _, x := range v.Inputs {
+		src += "  input Real " + x + ";\n"
+	}
+
+	src += "equation\n"
+	for _, e := range equations(m) {
+		src += "  " + Equation(e) + "\n"
+	}
+	src += "end " + addie.ModelIdent(m.Name) + ";\n"
+
+	return src
+
+}
+
+func modifiers(args, init string) string {
+
+	var ms []string
+	for _, a := range strings.Split(strings.Replace(args, " ", "", -1), ",") {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) == 2 {
+			ms = append(ms, kv[0]+" = "+kv[1])
+		}
+	}
+	for _, a := range strings.Split(strings.Replace(init, " ", "", -1), ",") {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) == 2 {
+			ms = append(ms, kv[0]+"(start = "+kv[1]+", fixed = true)")
+		}
+	}
+
+	if len(ms) == 0 {
+		return ""
+	}
+
+	return "(" + strings.Join(ms, ", ") + ")"
+
+}
+
+func splitBindings(b string) []string {
+	return strings.Split(strings.Replace(b, " ", "", -1), ",")
+}
+
+/*
+The ExportDesign function translates the physical half of a design into a
+Modelica package holding the models the design uses and a system model named
+after the design. Phyos become component instances and plinks between phyos
+become equations. Plinks to sensors become outputs of the system model and
+plinks from actuators and traces become inputs, so the cyber half of the
+design can be attached from Modelica tools.
+*/
+func ExportDesign(dsg *addie.Design, models []addie.Model) string {
+
+	mdls := make(map[string]*addie.Model)
+	for i, m := range models {
+		mdls[m.Name] = &models[i]
+	}
+
+	var phyos []addie.Phyo
+	var plinks []addie.Plink
+	for _, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Phyo:
+			phyos = append(phyos, e.(addie.Phyo))
+		case addie.Plink:
+			plinks = append(plinks, e.(addie.Plink))
+		}
+	}
+	sort.Slice(phyos, func(i, j int) bool { return phyos[i].Name < phyos[j].Name })
+	sort.Slice(plinks, func(i, j int) bool { return plinks[i].Name < plinks[j].Name })
+
+	src := "package " + dsg.Name + "_pkg\n\n"
+
+	var used []string
+	seen := make(map[string]bool)
+	for _, p := range phyos {
+		if !seen[p.Model] {
+			seen[p.Model] = true
+			used = append(used, p.Model)
+		}
+	}
+	sort.Strings(used)
+	for _, u := range used {
+		m, ok := mdls[u]
+		if !ok {
+			continue
+		}
+		src += ExportModel(m) + "\n"
+	}
+
+	var decls, eqtns []string
+	for _, p := range phyos {
+		decls = append(decls, "  "+addie.ModelIdent(p.Model)+" "+p.Name+modifiers(p.Args, p.Init)+";")
+	}
+
+	for _, pl := range plinks {
+		ae, aok := dsg.Elements[pl.Endpoints[0]]
+		be, bok := dsg.Elements[pl.Endpoints[1]]
+		if !aok || !bok {
+			continue
+		}
+		as, bs := splitBindings(pl.Bindings[0]), splitBindings(pl.Bindings[1])
+		for i := 0; i < len(as) && i < len(bs); i++ {
+			d, e := plinkEquation(ae, as[i], be, bs[i])
+			if d != "" {
+				decls = append(decls, d)
+			}
+			if e != "" {
+				eqtns = append(eqtns, e)
+			}
+		}
+	}
+
+	src += "model " + dsg.Name + "\n"
+	for _, d := range decls {
+		src += d + "\n"
+	}
+	src += "equation\n"
+	for _, e := range eqtns {
+		src += e + "\n"
+	}
+	src += "end " + dsg.Name + ";\n\n"
+
+	src += "end " + dsg.Name + "_pkg;\n"
+
+	return src
+
+}
+
+/*
+The connector function returns the name of the system model variable a plink
+binding refers to, along with its causality when it is a connector of the
+system model itself
+*/
+func connector(e addie.Identify, v string) (string, string) {
+
+	switch e.(type) {
+	case addie.Sax:
+		s := e.(addie.Sax)
+		sensors, _ := sema.ExtractSensorData(s)
+		if _, ok := sensors[v]; ok {
+			return s.Name + "_" + v, "output"
+		}
+		return s.Name + "_" + v, "input"
+	case addie.Trace:
+		t := e.(addie.Trace)
+		return t.Name + "_" + v, "input"
+	}
+
+	return e.Identify().Name + "." + v, ""
+
+}
+
+func plinkEquation(ae addie.Identify, a string, be addie.Identify,
+	b string) (string, string) {
+
+	an, ak := connector(ae, a)
+	bn, bk := connector(be, b)
+
+	decl := ""
+	switch {
+	case ak != "":
+		decl = "  " + ak + " Real " + an + ";"
+	case bk != "":
+		decl = "  " + bk + " Real " + bn + ";"
+	}
+
+	//outputs are defined by the plant, everything else drives the plant
+	if ak == "output" || (ak == "" && bk == "input") {
+		return decl, "  " + an + " = " + bn + ";"
+	}
+
+	return decl, "  " + bn + " = " + an + ";"
+
+}
@@ -0,0 +1,84 @@
+package modelica
+
+import (
+	"addie"
+	"strings"
+	"testing"
+)
+
+var rotor = addie.Model{
+	Name:      "Rotor",
+	Params:    "H, c",
+	Equations: "w' = tau - H*w^2\ntheta' = w\nP = c*tau*w",
+}
+
+func TestExportModel(t *testing.T) {
+
+	v := ModelVars(&rotor)
+	if strings.Join(v.States, ",") != "w,theta" ||
+		strings.Join(v.Algebraics, ",") != "P" ||
+		strings.Join(v.Inputs, ",") != "tau" {
+		t.Fatalf("bad model variables %+v", v)
+	}
+
+	src := ExportModel(&rotor)
+	expected := "model Rotor\n" +
+		"  parameter Real H;\n" +
+		"  parameter Real c;\n" +
+		"  Real w;\n" +
+		"  Real theta;\n" +
+		"  Real P;\n" +
+		"  input Real tau;\n" +
+		"equation\n" +
+		"  der(w) = tau - H*w^2;\n" +
+		"  der(theta) = w;\n" +
+		"  P = c*tau*w;\n" +
+		"end Rotor;\n"
+
+	if src != expected {
+		t.Fatalf("bad model export\n%s", src)
+	}
+
+}
+
+func TestExportDesign(t *testing.T) {
+
+	dsg := addie.EmptyDesign("glenlivet")
+
+	p := addie.Phyo{}
+	p.Id = addie.Id{Name: "rtr", Sys: "root", Design: "glenlivet"}
+	p.Model = "Rotor"
+	p.Args = "H=2.5, c=1"
+	p.Init = "w=0"
+	dsg.Elements[p.Id] = p
+
+	s := addie.Sax{}
+	s.Id = addie.Id{Name: "ctl", Sys: "root", Design: "glenlivet"}
+	s.Sense = "w(10)"
+	s.Actuate = "tau(5,1)"
+	dsg.Elements[s.Id] = s
+
+	l := addie.Plink{}
+	l.Id = addie.Id{Name: "pl0", Sys: "root", Design: "glenlivet"}
+	l.Endpoints = [2]addie.Id{p.Id, s.Id}
+	l.Bindings = [2]string{"w,tau", "w,tau"}
+	dsg.Elements[l.Id] = l
+
+	src := ExportDesign(&dsg, []addie.Model{rotor})
+
+	for _, x := range []string{
+		"package glenlivet_pkg\n",
+		"model Rotor\n",
+		"  Rotor rtr(H = 2.5, c = 1, w(start = 0, fixed = true));\n",
+		"  output Real ctl_w;\n",
+		"  input Real ctl_tau;\n",
+		"  ctl_w = rtr.w;\n",
+		"  rtr.tau = ctl_tau;\n",
+		"end glenlivet;\n",
+	} {
+		if !strings.Contains(src, x) {
+			t.Fatalf("design export is missing %q\n%s", x, src)
+		}
+	}
+
+}
@@ -0,0 +1,345 @@
+/*
+This file contains the importer, which reads the subset of Modelica addie
+models can express: model classes holding parameters, Real variables and
+equations with der() and connect() between variables of the same model.
+*/
+package modelica
+
+import (
+	"addie"
+	"addie/sema"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	commentRx     = regexp.MustCompile("(?s)//[^\n]*|/\\*.*?\\*/")
+	stringRx      = regexp.MustCompile("\"[^\"]*\"")
+	annotRx       = regexp.MustCompile("(?s)\\bannotation\\s*\\(")
+	classRx       = regexp.MustCompile("^(?:partial\\s+)?(model|block|class|package)\\s+([a-zA-Z_][a-zA-Z0-9_]*)\\s*")
+	endRx         = regexp.MustCompile("^end\\s+([a-zA-Z_][a-zA-Z0-9_]*)$")
+	sectionRx     = regexp.MustCompile("^(initial\\s+equation|initial\\s+algorithm|equation|algorithm|public|protected)\\b\\s*")
+	declRx        = regexp.MustCompile("^((?:(?:parameter|constant|input|output|discrete|flow|stream)\\s+)*)([a-zA-Z_][a-zA-Z0-9_.]*)\\s+(.+)$")
+	itemRx        = regexp.MustCompile("^([a-zA-Z_][a-zA-Z0-9_]*)\\s*(\\(.*\\))?\\s*(?:=\\s*(.+))?$")
+	varRx         = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+	connectRx     = regexp.MustCompile("^connect\\s*\\((.*),(.*)\\)$")
+	derCallRx     = regexp.MustCompile("\\bder\\s*\\(\\s*([^()]*?)\\s*\\)")
+	derRestRx     = regexp.MustCompile("\\bder\\s*\\(")
+	blockRx       = regexp.MustCompile("^(if|when|for|while)\\b")
+	controlRx     = regexp.MustCompile("^(assert|reinit|terminate)\\b")
+	unsupportedRx = regexp.MustCompile("^(extends|import|replaceable|redeclare|within)\\b")
+)
+
+type importer struct {
+	ds      sema.Diagnostics
+	models  []addie.Model
+	current *addie.Model
+	params  []string
+	section string
+	//depth of the unsupported if, when and for blocks being skipped
+	skip int
+}
+
+func (im *importer) diag(level, format string, args ...interface{}) {
+	im.ds.Elements = append(im.ds.Elements,
+		sema.Diagnostic{Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+/*
+The Import function translates the models of a Modelica source into addie
+models. Models may be nested in packages, every model class becomes one addie
+model named after the class. Constructs outside of the supported subset are
+reported as diagnostics, errors for constructs that change the meaning of a
+model and warnings for information addie models have no place for, like
+parameter defaults and start values.
+*/
+func Import(src string) ([]addie.Model, sema.Diagnostics) {
+
+	im := &importer{}
+
+	src = commentRx.ReplaceAllString(src, " ")
+	src = stringRx.ReplaceAllString(src, " ")
+	src = stripAnnotations(src)
+
+	for _, st := range strings.Split(src, ";") {
+		im.statement(strings.Join(strings.Fields(st), " "))
+	}
+
+	if im.current != nil {
+		im.diag("error", "model %s is missing its end statement", im.current.Name)
+	}
+
+	if len(im.models) == 0 && !im.ds.Fatal() {
+		im.diag("error", "the source does not contain any models")
+	}
+
+	return im.models, im.ds
+
+}
+
+/*
+Annotations only carry graphics and tool hints, they are removed along with
+their balanced parentheses before parsing
+*/
+func stripAnnotations(src string) string {
+
+	for {
+		loc := annotRx.FindStringIndex(src)
+		if loc == nil {
+			return src
+		}
+
+		depth, end := 1, loc[1]
+		for ; end < len(src) && depth > 0; end++ {
+			switch src[end] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+
+		src = src[:loc[0]] + " " + src[end:]
+	}
+
+}
+
+func (im *importer) statement(st string) {
+
+	for st != "" {
+
+		if m := classRx.FindStringSubmatch(st); m != nil {
+			st = st[len(m[0]):]
+			if m[1] == "package" {
+				continue
+			}
+			if im.current != nil {
+				im.diag("error", "model %s is nested in model %s, nested models are "+
+					"not supported", m[2], im.current.Name)
+			}
+			im.current = &addie.Model{Name: m[2]}
+			im.params = nil
+			im.section = "declarations"
+			continue
+		}
+
+		if m := endRx.FindStringSubmatch(st); m != nil {
+			if blockRx.MatchString(m[1]) {
+				if im.skip > 0 {
+					im.skip--
+				}
+				return
+			}
+			if im.current != nil && im.current.Name == m[1] {
+				im.finish()
+			}
+			return
+		}
+
+		if m := sectionRx.FindStringSubmatch(st); m != nil {
+			st = st[len(m[0]):]
+			switch strings.Join(strings.Fields(m[1]), " ") {
+			case "equation":
+				im.section = "equations"
+			case "public", "protected":
+				im.section = "declarations"
+			default:
+				im.section = "unsupported"
+				if im.current != nil {
+					im.diag("error", "model %s: %s sections are not supported and "+
+						"were not imported", im.current.Name, m[1])
+				}
+			}
+			continue
+		}
+
+		break
+
+	}
+
+	if st == "" {
+		return
+	}
+
+	if im.current == nil {
+		if m := unsupportedRx.FindStringSubmatch(st); m != nil && m[1] == "within" {
+			return
+		}
+		im.diag("error", "'%s' is outside of a model and was not imported", st)
+		return
+	}
+
+	if m := unsupportedRx.FindStringSubmatch(st); m != nil {
+		im.diag("error", "model %s: '%s' is not supported", im.current.Name, st)
+		return
+	}
+
+	switch im.section {
+	case "declarations":
+		im.declaration(st)
+	case "equations":
+		im.equation(st)
+	}
+
+}
+
+func (im *importer) finish() {
+
+	m := im.current
+	m.Params = strings.Join(im.params, ",")
+	m.Equations = strings.TrimSuffix(m.Equations, "\n")
+
+	if m.Equations == "" {
+		im.diag("warning", "model %s has no equations", m.Name)
+	}
+
+	im.models = append(im.models, *m)
+	im.current = nil
+
+}
+
+func (im *importer) declaration(st string) {
+
+	name := im.current.Name
+
+	m := declRx.FindStringSubmatch(st)
+	if m == nil {
+		im.diag("error", "model %s: could not understand declaration '%s'", name, st)
+		return
+	}
+	prefixes, typ := strings.Fields(m[1]), m[2]
+
+	if typ != "Real" {
+		im.diag("error", "model %s: '%s' declares a %s, only Real variables "+
+			"and parameters are supported", name, st, typ)
+		return
+	}
+
+	parameter := false
+	for _, p := range prefixes {
+		switch p {
+		case "parameter", "constant":
+			parameter = true
+		case "flow", "stream":
+			im.diag("error", "model %s: %s variables are not supported in '%s'",
+				name, p, st)
+			return
+		}
+	}
+
+	for _, item := range splitTop(m[3]) {
+
+		it := itemRx.FindStringSubmatch(strings.TrimSpace(item))
+		if it == nil {
+			im.diag("error", "model %s: could not understand declaration '%s'",
+				name, item)
+			continue
+		}
+		v, mods, value := it[1], it[2], it[3]
+
+		if parameter {
+			im.params = append(im.params, v)
+			if value != "" {
+				im.diag("warning", "model %s: the value %s of parameter %s was not "+
+					"imported, set it in the args of each phyo", name, value, v)
+			}
+			continue
+		}
+
+		if mods != "" {
+			im.diag("warning", "model %s: the modifiers %s of %s were not "+
+				"imported, set start values in the init of each phyo", name, mods, v)
+		}
+		if value != "" {
+			im.current.Equations += v + " = " + value + "\n"
+		}
+
+	}
+
+}
+
+func (im *importer) equation(st string) {
+
+	name := im.current.Name
+
+	if im.skip > 0 {
+		if blockRx.MatchString(st) {
+			im.skip++
+		}
+		return
+	}
+
+	if blockRx.MatchString(st) {
+		im.skip++
+		im.diag("error", "model %s: '%s' is not supported, the block was not "+
+			"imported", name, st)
+		return
+	}
+
+	if controlRx.MatchString(st) {
+		im.diag("error", "model %s: '%s' is not supported", name, st)
+		return
+	}
+
+	if m := connectRx.FindStringSubmatch(st); m != nil {
+		a, b := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+		if !varRx.MatchString(a) || !varRx.MatchString(b) {
+			im.diag("error", "model %s: '%s' connects components, only connections "+
+				"between variables of the model are supported, use plinks to "+
+				"connect phyos", name, st)
+			return
+		}
+		im.current.Equations += a + " = " + b + "\n"
+		return
+	}
+
+	if !strings.Contains(st, "=") {
+		im.diag("error", "model %s: '%s' is not an equation", name, st)
+		return
+	}
+
+	ok := true
+	eq := derCallRx.ReplaceAllStringFunc(st, func(d string) string {
+		x := derCallRx.FindStringSubmatch(d)[1]
+		if !varRx.MatchString(x) {
+			ok = false
+			return d
+		}
+		return x + "'"
+	})
+	if !ok || derRestRx.MatchString(eq) {
+		im.diag("error", "model %s: '%s' takes the derivative of an expression, "+
+			"only derivatives of variables are supported", name, st)
+		return
+	}
+
+	im.current.Equations += eq + "\n"
+
+}
+
+/*
+The splitTop function splits a declaration list on the commas that are not
+nested in a modifier
+*/
+func splitTop(s string) []string {
+
+	var items []string
+	depth, begin := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, s[begin:i])
+				begin = i + 1
+			}
+		}
+	}
+
+	return append(items, s[begin:])
+
+}
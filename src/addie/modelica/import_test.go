@@ -0,0 +1,109 @@
+package modelica
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+
+	src := `
+within Plants;
+package Plants "plant models"
+  model Rotor "a spinning mass"
+    parameter Real H = 2.5 "inertia";
+    parameter Real c;
+    Real w(start = 0), theta;
+    input Real tau;
+    Real P = c*tau*w;
+    annotation(Icon(graphics = {Line(points = {{0, 0}, {1, 1}})}));
+  equation
+    // the swing equation
+    der(w) = tau - H*w^2;
+    der(theta) = w;
+  end Rotor;
+
+  model Pipe
+    Real qin, qout;
+  equation
+    connect(qin, qout);
+  end Pipe;
+end Plants;
+`
+
+	ms, ds := Import(src)
+	if ds.Fatal() {
+		t.Fatalf("import failed %v", ds)
+	}
+	if len(ms) != 2 {
+		t.Fatalf("expected 2 models, got %d", len(ms))
+	}
+
+	if ms[0].Name != "Rotor" || ms[0].Params != "H,c" ||
+		ms[0].Equations != "P = c*tau*w\nw' = tau - H*w^2\ntheta' = w" {
+		t.Fatalf("bad rotor import %+v", ms[0])
+	}
+	if ms[1].Name != "Pipe" || ms[1].Equations != "qin = qout" {
+		t.Fatalf("bad pipe import %+v", ms[1])
+	}
+
+	//the parameter default and the start value have no place in an addie model
+	warnings := 0
+	for _, d := range ds.Elements {
+		if d.Level == "warning" {
+			warnings++
+		}
+	}
+	if warnings != 2 {
+		t.Fatalf("expected 2 warnings, got %v", ds)
+	}
+
+	//an exported model imports back to itself
+	back, ds := Import(ExportModel(&rotor))
+	if ds.Fatal() || len(back) != 1 || back[0].Equations != rotor.Equations {
+		t.Fatalf("bad round trip %+v %v", back, ds)
+	}
+
+}
+
+func TestImportUnsupported(t *testing.T) {
+
+	src := `
+model Plant
+  extends Base;
+  Integer n;
+  Motor m;
+  Real x, y;
+equation
+  connect(m.shaft, x);
+  if x > 1 then
+    y = 1;
+  else
+    y = x;
+  end if;
+  der(x*y) = 1;
+  der(x) = -x;
+end Plant;
+`
+
+	ms, ds := Import(src)
+	if !ds.Fatal() {
+		t.Fatal("unsupported constructs were not reported")
+	}
+
+	errs := 0
+	for _, d := range ds.Elements {
+		if d.Level == "error" {
+			errs++
+		}
+	}
+	//extends, Integer, Motor, connect, if and der(x*y)
+	if errs != 6 {
+		t.Fatalf("expected 6 errors, got %v", ds)
+	}
+
+	if len(ms) != 1 || strings.TrimSpace(ms[0].Equations) != "x' = -x" {
+		t.Fatalf("bad partial import %+v", ms)
+	}
+
+}
@@ -0,0 +1,281 @@
+/*
+The param package resolves references to the parameters of a design. Element
+fields refer to a parameter as $name and may combine references in an
+expression, like 2*$link_latency. The references are resolved when a design
+is compiled so a sweep only has to change the value of a parameter.
+*/
+package param
+
+import (
+	"addie"
+	"addie/eqn"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var nameRx = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+var refRx = regexp.MustCompile(`\$([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+/*
+CheckName checks that a parameter name can be referred to
+*/
+func CheckName(name string) error {
+
+	if !nameRx.MatchString(name) {
+		return fmt.Errorf("'%s' is not a valid parameter name", name)
+	}
+
+	return nil
+
+}
+
+/*
+Refs returns the names of the parameters a field refers to in order of first
+appearance
+*/
+func Refs(s string) []string {
+
+	var result []string
+	seen := make(map[string]bool)
+	for _, m := range refRx.FindAllStringSubmatch(s, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			result = append(result, m[1])
+		}
+	}
+
+	return result
+
+}
+
+/*
+Eval evaluates an expression over the parameters params. Every variable of the
+expression must be written as a parameter reference.
+*/
+func Eval(expr string, params map[string]float64) (float64, error) {
+
+	e, err := eqn.Parse(refRx.ReplaceAllString(expr, "$1"))
+	if err != nil {
+		return 0, fmt.Errorf("'%s': %v", expr, err)
+	}
+
+	refs := make(map[string]bool)
+	for _, r := range Refs(expr) {
+		if _, ok := params[r]; !ok {
+			return 0, fmt.Errorf("'%s' refers to the parameter %s which the design "+
+				"does not have", expr, r)
+		}
+		refs[r] = true
+	}
+	for _, v := range eqn.Vars(e) {
+		if !refs[v] {
+			return 0, fmt.Errorf("'%s' refers to %s, parameters are written $%s",
+				expr, v, v)
+		}
+	}
+
+	x, err := e.Eval(eqn.Env(params))
+	if err != nil {
+		return 0, fmt.Errorf("'%s': %v", expr, err)
+	}
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0, fmt.Errorf("'%s' does not evaluate to a number", expr)
+	}
+
+	return x, nil
+
+}
+
+/*
+format writes a value without an exponent, the simulation description only
+reads plain decimals
+*/
+func format(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+/*
+expand evaluates the values that refer to parameters in a list of values
+separated by sep, values of the form key=value have only their value
+evaluated. Values without references are kept as they are.
+*/
+func expand(s, sep string, params map[string]float64) (string, error) {
+
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	parts := strings.Split(s, sep)
+	for i, p := range parts {
+		if !strings.Contains(p, "$") {
+			continue
+		}
+		key, value := "", p
+		if j := strings.Index(p, "="); j >= 0 {
+			key, value = p[:j+1], p[j+1:]
+		}
+		x, err := Eval(value, params)
+		if err != nil {
+			return s, err
+		}
+		parts[i] = key + format(x)
+	}
+
+	return strings.Join(parts, sep), nil
+
+}
+
+var ioRx = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)\(([^\)]*)\)`)
+
+/*
+expandIO evaluates the parameter references in the arguments of the sensors
+or actuators of a sax, like level($rate)
+*/
+func expandIO(s string, params map[string]float64) (string, error) {
+
+	var err error
+	result := ioRx.ReplaceAllStringFunc(s, func(m string) string {
+		sm := ioRx.FindStringSubmatch(m)
+		args, _err := expand(sm[2], ",", params)
+		if _err != nil && err == nil {
+			err = _err
+		}
+		return sm[1] + "(" + args + ")"
+	})
+
+	return result, err
+
+}
+
+/*
+conductor resolves the capacity and latency expressions of a packet conductor
+*/
+func conductor(p *addie.PacketConductor, params map[string]float64) error {
+
+	if p.CapacityExpr != "" {
+		x, err := Eval(p.CapacityExpr, params)
+		if err != nil {
+			return fmt.Errorf("capacity %v", err)
+		}
+		p.Capacity = int(math.Round(x))
+	}
+	if p.LatencyExpr != "" {
+		x, err := Eval(p.LatencyExpr, params)
+		if err != nil {
+			return fmt.Errorf("latency %v", err)
+		}
+		p.Latency = int(math.Round(x))
+	}
+
+	return nil
+
+}
+
+func interfaces(h *addie.NetHost, params map[string]float64) error {
+
+	ifs := make(map[string]addie.Interface)
+	for k, i := range h.Interfaces {
+		err := conductor(&i.PacketConductor, params)
+		if err != nil {
+			return fmt.Errorf("interface %s %v", k, err)
+		}
+		ifs[k] = i
+	}
+	h.Interfaces = ifs
+
+	return nil
+
+}
+
+/*
+ResolveElement returns a copy of an element with its parameter references
+resolved against params, the element is returned unchanged when a reference
+can not be resolved
+*/
+func ResolveElement(e addie.Identify, params map[string]float64) (addie.Identify, error) {
+
+	var err error
+	original := e
+
+	switch e.(type) {
+	case addie.Computer:
+		x := e.(addie.Computer)
+		err = interfaces(&x.NetHost, params)
+		e = x
+	case addie.Switch:
+		x := e.(addie.Switch)
+		err = interfaces(&x.NetHost, params)
+		if err == nil {
+			err = conductor(&x.PacketConductor, params)
+		}
+		e = x
+	case addie.Router:
+		x := e.(addie.Router)
+		err = interfaces(&x.NetHost, params)
+		if err == nil {
+			err = conductor(&x.PacketConductor, params)
+		}
+		e = x
+	case addie.Link:
+		x := e.(addie.Link)
+		err = conductor(&x.PacketConductor, params)
+		e = x
+	case addie.Phyo:
+		x := e.(addie.Phyo)
+		x.Args, err = expand(x.Args, ",", params)
+		if err == nil {
+			x.Init, err = expand(x.Init, ",", params)
+		}
+		e = x
+	case addie.Sax:
+		x := e.(addie.Sax)
+		err = interfaces(&x.NetHost, params)
+		if err == nil {
+			x.Sense, err = expandIO(x.Sense, params)
+		}
+		if err == nil {
+			x.Actuate, err = expandIO(x.Actuate, params)
+		}
+		e = x
+	}
+
+	if err != nil {
+		return original, err
+	}
+
+	return e, nil
+
+}
+
+/*
+Resolve returns a copy of a design with the parameter references of its
+elements resolved. Elements that can not be resolved are copied as they are
+and an error is returned for each of them.
+*/
+func Resolve(dsg *addie.Design) (*addie.Design, []error) {
+
+	result := addie.EmptyDesign(dsg.Name)
+	result.Params = dsg.Params
+
+	ids := make([]addie.Id, 0, len(dsg.Elements))
+	for id := range dsg.Elements {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+
+	var errs []error
+	for _, id := range ids {
+		e, err := ResolveElement(dsg.Elements[id], dsg.Params)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("[%v] %v", id, err))
+		}
+		result.Elements[id] = e
+	}
+
+	return &result, errs
+
+}
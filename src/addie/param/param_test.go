@@ -0,0 +1,100 @@
+package param
+
+import (
+	"addie"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+
+	params := map[string]float64{"link_latency": 5, "motor_H": 2.5}
+
+	x, err := Eval("2*$link_latency + $motor_H", params)
+	if err != nil || x != 12.5 {
+		t.Fatalf("expected 12.5, got %v %v", x, err)
+	}
+
+	for expr, fragment := range map[string]string{
+		"$motor_J":            "does not have",
+		"2*link_latency":      "parameters are written $link_latency",
+		"$motor_H +":          "'$motor_H +'",
+		"$link_latency/(1-1)": "does not evaluate to a number",
+	} {
+		_, err := Eval(expr, params)
+		if err == nil || !strings.Contains(err.Error(), fragment) {
+			t.Fatalf("%s: expected an error containing '%s', got %v", expr, fragment, err)
+		}
+	}
+
+	if CheckName("motor_H") != nil || CheckName("2fast") == nil {
+		t.Fatal("bad parameter name check")
+	}
+
+}
+
+func TestResolve(t *testing.T) {
+
+	dsg := addie.EmptyDesign("talisker")
+	dsg.Params["link_latency"] = 5
+	dsg.Params["motor_H"] = 2.5
+	dsg.Params["rate"] = 20
+
+	id := func(n string) addie.Id { return addie.Id{Name: n, Sys: "root", Design: "talisker"} }
+
+	l := addie.Link{}
+	l.Id = id("wan")
+	l.Capacity = 1000
+	l.LatencyExpr = "2*$link_latency"
+	dsg.Elements[l.Id] = l
+
+	r := addie.Router{}
+	r.Id = id("gw")
+	r.Interfaces = map[string]addie.Interface{
+		"eth0": {Name: "eth0", PacketConductor: addie.PacketConductor{
+			LatencyExpr: "$link_latency"}}}
+	dsg.Elements[r.Id] = r
+
+	p := addie.Phyo{}
+	p.Id = id("motor")
+	p.Args = "H=$motor_H,D=0.1"
+	p.Init = "w=$motor_H*10"
+	dsg.Elements[p.Id] = p
+
+	s := addie.Sax{}
+	s.Id = id("ctl")
+	s.Sense = "w($rate);theta(10)"
+	s.Actuate = "tau($motor_H,1)"
+	dsg.Elements[s.Id] = s
+
+	res, errs := Resolve(&dsg)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	if x := res.Elements[l.Id].(addie.Link); x.Latency != 10 || x.Capacity != 1000 {
+		t.Fatalf("bad link %+v", x)
+	}
+	if x := res.Elements[r.Id].(addie.Router); x.Interfaces["eth0"].Latency != 5 {
+		t.Fatalf("bad router %+v", x)
+	}
+	if x := res.Elements[p.Id].(addie.Phyo); x.Args != "H=2.5,D=0.1" || x.Init != "w=25" {
+		t.Fatalf("bad phyo %+v", x)
+	}
+	if x := res.Elements[s.Id].(addie.Sax); x.Sense != "w(20);theta(10)" ||
+		x.Actuate != "tau(2.5,1)" {
+		t.Fatalf("bad sax %+v", x)
+	}
+
+	//the design itself keeps its references
+	if dsg.Elements[r.Id].(addie.Router).Interfaces["eth0"].Latency != 0 {
+		t.Fatal("resolving changed the design")
+	}
+
+	delete(dsg.Params, "rate")
+	res, errs = Resolve(&dsg)
+	if len(errs) != 1 || res.Elements[s.Id].(addie.Sax).Sense != s.Sense {
+		t.Fatalf("an unresolved sax was not kept as it was %v", errs)
+	}
+
+}
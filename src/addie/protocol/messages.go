@@ -2,8 +2,10 @@ package protocol
 
 import (
 	"addie"
+	"addie/topo"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 )
@@ -37,10 +39,178 @@ type NewXP struct {
 	Name string
 }
 
+type Resume struct {
+	Checkpoint string             `json:"checkpoint"`
+	Params     map[string]float64 `json:"params"`
+}
+
+/*
+Copies a standard library model into the user's model library, under the name
+As when it is given
+*/
+type LibraryCopy struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	As      string `json:"as"`
+}
+
+/*
+Publishes a model from the user's model library to a scope under a version
+*/
+type Publish struct {
+	Model   string `json:"model"`
+	Scope   string `json:"scope"`
+	Version string `json:"version"`
+}
+
+/*
+Restores a saved version of a model, the restored content is saved as a new
+version so the history is never rewritten
+*/
+type ModelRollback struct {
+	Model   string `json:"model"`
+	Version int    `json:"version"`
+}
+
+/*
+Requests the steady state and linearization analysis of either a user model
+or a set of phyos of the design. Point, when given, is the operating point to
+linearize around instead of a computed equilibrium.
+*/
+type Analysis struct {
+	Model  string             `json:"model"`
+	Phyos  []string           `json:"phyos"`
+	Params map[string]float64 `json:"params"`
+	Inputs map[string]float64 `json:"inputs"`
+	Guess  map[string]float64 `json:"guess"`
+	Point  map[string]float64 `json:"point"`
+}
+
+/*
+Creates, renames, nests or deletes the system Name. Parent is the system a
+created or nested system goes under, As is the new name of a renamed system.
+*/
+type SystemOp struct {
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+	As     string `json:"as"`
+}
+
+/*
+Moves elements into the system Sys, the links and plinks that reference them
+are rewired to their new ids
+*/
+type Move struct {
+	Elements []addie.Id `json:"elements"`
+	Sys      string     `json:"sys"`
+}
+
+/*
+Saves elements of the design as a template. Params are the template
+parameters with their default values, elements refer to them as ${name}.
+*/
+type TemplateSave struct {
+	Name     string            `json:"name"`
+	Params   map[string]string `json:"params"`
+	Elements []addie.Id        `json:"elements"`
+}
+
+/*
+Instantiates a template Count times into the system Sys. Instance i, counting
+from Start, names its elements with Pattern where {name} is the name of the
+template element and {i} is i. Params override the template parameter
+defaults, {i} in a value is replaced by i as well.
+*/
+type Instantiate struct {
+	Template string            `json:"template"`
+	Count    int               `json:"count"`
+	Start    int               `json:"start"`
+	Pattern  string            `json:"pattern"`
+	Sys      string            `json:"sys"`
+	Params   map[string]string `json:"params"`
+}
+
+/*
+Generates a network topology into the system Sys of the design
+*/
+type Generate struct {
+	topo.Spec
+	Sys string `json:"sys"`
+}
+
+/*
+Sets the design parameter Name to Value, or deletes it
+*/
+type Param struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
 type UserDesigns struct {
 	Designs []string `json:"designs"`
 }
 
+/*
+DecodeElement decodes a design element of the named type
+*/
+func DecodeElement(typ string, raw json.RawMessage) (addie.Identify, error) {
+
+	var e addie.Identify
+	var err error
+
+	switch typ {
+	case "Computer":
+		var x addie.Computer
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Switch":
+		var x addie.Switch
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Router":
+		var x addie.Router
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Link":
+		var x addie.Link
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Phyo":
+		var x addie.Phyo
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Plink":
+		var x addie.Plink
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Sax":
+		var x addie.Sax
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Trace":
+		var x addie.Trace
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Sensor":
+		var x addie.Sensor
+		err = json.Unmarshal(raw, &x)
+		e = x
+	case "Actuator":
+		var x addie.Actuator
+		err = json.Unmarshal(raw, &x)
+		e = x
+	default:
+		return nil, fmt.Errorf("unknown element type %s", typ)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+
+}
+
 func Unpack(r *http.Request, x interface{}) error {
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(r.Body)
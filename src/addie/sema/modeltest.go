@@ -0,0 +1,152 @@
+/*
+This file contains the code for running the test cases attached to models
+with the built in integrator
+*/
+package sema
+
+import (
+	"addie"
+	"addie/eqn"
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+CheckModelTests runs the test cases of a model. Every failed expectation is an
+error, a model whose equations the built in integrator can not handle is a
+warning since the Cypress toolchain may still simulate it.
+*/
+func CheckModelTests(m addie.Model) Diagnostics {
+
+	var ds Diagnostics
+
+	if len(m.Tests) == 0 {
+		return ds
+	}
+
+	var diag = func(level, format string, args ...interface{}) {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{level, fmt.Sprintf("[Model %s] ", m.Name) +
+				fmt.Sprintf(format, args...)})
+	}
+
+	eqs, err := eqn.ParseEquations(m.Equations)
+	if err != nil {
+		diag("error", "the equations could not be parsed: %v", err)
+		return ds
+	}
+	sys, err := eqn.NewSystem(eqs)
+	if err != nil {
+		diag("warning", "the tests were not run, %v", err)
+		return ds
+	}
+
+	for _, t := range m.Tests {
+		_ds := runModelTest(m, sys, t)
+		ds.Merge(&_ds)
+	}
+
+	return ds
+
+}
+
+/*
+The most integration steps a test may take, tests run every time a model is
+saved
+*/
+const maxTestSteps = 100000
+
+func runModelTest(m addie.Model, sys *eqn.System, t addie.ModelTest) Diagnostics {
+
+	var ds Diagnostics
+
+	var diag = func(level, format string, args ...interface{}) {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{level, fmt.Sprintf("[Model %s] test %s: ", m.Name, t.Name) +
+				fmt.Sprintf(format, args...)})
+	}
+
+	env := make(eqn.Env)
+	for _, p := range ModelParams(m) {
+		v, ok := t.Params[p]
+		if !ok {
+			diag("error", "the parameter %s has no value", p)
+			continue
+		}
+		env[p] = v
+	}
+	for _, x := range sys.States {
+		env[x] = t.Init[x]
+	}
+	for _, u := range sys.Inputs() {
+		if _, ok := env[u]; ok {
+			continue
+		}
+		v, ok := t.Inputs[u]
+		if !ok {
+			diag("error", "the input %s has no value", u)
+			continue
+		}
+		env[u] = v
+	}
+	if ds.Fatal() {
+		return ds
+	}
+
+	step := t.Step
+	if step <= 0 {
+		step = t.Duration / 1000
+	}
+	if step <= 0 {
+		diag("error", "the duration [%g] must be positive", t.Duration)
+		return ds
+	}
+	if !(t.Duration/step <= maxTestSteps) {
+		diag("error", "the duration [%g] takes more than %d steps of [%g]",
+			t.Duration, maxTestSteps, step)
+		return ds
+	}
+
+	expect := make([]addie.Expectation, len(t.Expect))
+	copy(expect, t.Expect)
+	sort.SliceStable(expect, func(i, j int) bool { return expect[i].Time < expect[j].Time })
+
+	now := 0.0
+	err := sys.Update(env)
+	for _, x := range expect {
+		if err != nil {
+			break
+		}
+		if x.Time < 0 || x.Time > t.Duration {
+			diag("error", "the expectation on %s at time %g is outside of the test",
+				x.Var, x.Time)
+			continue
+		}
+		err = sys.Integrate(env, now, x.Time, step)
+		if err != nil {
+			break
+		}
+		now = x.Time
+
+		v, ok := env[x.Var]
+		if !ok {
+			diag("error", "%s is not a variable of the model", x.Var)
+			continue
+		}
+		if math.Abs(v-x.Value) > x.Tol {
+			diag("error", "%s at time %g is %g, expected %g ± %g",
+				x.Var, x.Time, v, x.Value, x.Tol)
+		}
+	}
+	if err != nil {
+		diag("error", "the integration failed, %v", err)
+	}
+
+	if !ds.Fatal() {
+		diag("info", "passed")
+	}
+
+	return ds
+
+}
@@ -0,0 +1,55 @@
+package sema
+
+import (
+	"addie"
+	"strings"
+	"testing"
+)
+
+func TestCheckModelTests(t *testing.T) {
+
+	m := addie.Model{
+		Name:      "Tank",
+		Params:    "A",
+		Equations: "h' = (qin - qout)/A\nqout = 0.5*h",
+		Tests: []addie.ModelTest{{
+			Name:     "fill",
+			Params:   map[string]float64{"A": 2},
+			Init:     map[string]float64{"h": 0},
+			Inputs:   map[string]float64{"qin": 1},
+			Duration: 40,
+			Expect: []addie.Expectation{
+				{Var: "h", Time: 40, Value: 2, Tol: 1e-3},
+				{Var: "qout", Time: 0, Value: 0, Tol: 1e-9},
+			},
+		}},
+	}
+
+	ds := CheckModelTests(m)
+	if ds.Fatal() || len(ds.Elements) != 1 || ds.Elements[0].Level != "info" {
+		t.Fatalf("a passing test failed %v", ds)
+	}
+
+	//a broken edit to the outflow equation
+	m.Equations = "h' = (qin - qout)/A\nqout = 0.25*h"
+	ds = CheckModelTests(m)
+	if !ds.Fatal() || !strings.Contains(ds.Elements[0].Message, "h at time 40") {
+		t.Fatalf("a failing test passed %v", ds)
+	}
+
+	m.Tests[0].Inputs = nil
+	ds = CheckModelTests(m)
+	if !ds.Fatal() || !strings.Contains(ds.Elements[0].Message, "qin has no value") {
+		t.Fatalf("a missing input was not reported %v", ds)
+	}
+
+	//a test that would hold up the save is refused rather than run
+	m.Tests[0].Inputs = map[string]float64{"qin": 1}
+	m.Tests[0].Duration = 1e9
+	m.Tests[0].Step = 1e-6
+	ds = CheckModelTests(m)
+	if !ds.Fatal() || !strings.Contains(ds.Elements[0].Message, "more than 100000 steps") {
+		t.Fatalf("a test over the step budget was run %v", ds)
+	}
+
+}
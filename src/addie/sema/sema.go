@@ -6,6 +6,9 @@ package sema
 
 import (
 	"addie"
+	"addie/eqn"
+	"addie/param"
+	"addie/trace"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -45,6 +48,60 @@ func (ds *Diagnostics) ApplySource(s string) {
 
 func Check(dsg *addie.Design) Diagnostics {
 
+	ds := CheckParams(dsg)
+	dsg, _ = param.Resolve(dsg)
+
+	_ds := checkDesign(dsg)
+	ds.Merge(&_ds)
+	ds.conclude()
+
+	return ds
+
+}
+
+/*
+CheckCompile performs every check a design has to pass before it is compiled,
+this includes the checks that need the user models, simulation settings and
+the node profile catalog. The design is checked with its parameter references
+resolved.
+*/
+func CheckCompile(dsg *addie.Design, s addie.SimSettings,
+	models []addie.Model, init *addie.InitSet,
+	profiles []addie.NodeProfile) Diagnostics {
+
+	ds := CheckParams(dsg)
+	_ds := CheckProfiles(dsg, profiles)
+	ds.Merge(&_ds)
+	dsg, _ = param.Resolve(dsg)
+
+	_ds = checkDesign(dsg)
+	ds.Merge(&_ds)
+
+	_ds = CheckSimSettings(s, dsg, models)
+	ds.Merge(&_ds)
+
+	_ds = CheckModelRefs(dsg, models)
+	ds.Merge(&_ds)
+
+	_ds = CheckPlinkDirections(dsg, models)
+	ds.Merge(&_ds)
+
+	_ds = CheckStructure(dsg, models)
+	ds.Merge(&_ds)
+
+	if init != nil {
+		_ds = CheckInitSet(*init, dsg, models)
+		ds.Merge(&_ds)
+	}
+
+	ds.conclude()
+
+	return ds
+
+}
+
+func checkDesign(dsg *addie.Design) Diagnostics {
+
 	var ds Diagnostics
 
 	/*
@@ -55,13 +112,51 @@ func Check(dsg *addie.Design) Diagnostics {
 	_ds := CheckPlinks(dsg)
 	ds.Merge(&_ds)
 
+	_ds = CheckActuatorBindings(dsg)
+	ds.Merge(&_ds)
+
+	_ds = CheckTraces(dsg)
+	ds.Merge(&_ds)
+
+	return ds
+
+}
+
+/*
+CheckParams checks the names of the design parameters and that every
+parameter reference of the elements resolves
+*/
+func CheckParams(dsg *addie.Design) Diagnostics {
+
+	var ds Diagnostics
+
+	for k, _ := range dsg.Params {
+		err := param.CheckName(k)
+		if err != nil {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error", fmt.Sprintf("[Param] %v", err)})
+		}
+	}
+
+	for id, e := range dsg.Elements {
+		_, err := param.ResolveElement(e, dsg.Params)
+		if err != nil {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error", fmt.Sprintf("[Param][%v] %v", id, err)})
+		}
+	}
+
+	return ds
+
+}
+
+func (ds *Diagnostics) conclude() {
+
 	if !ds.Fatal() {
 		ds.Elements = append(ds.Elements,
 			Diagnostic{"success", "Design check succeeded"})
 	}
 
-	return ds
-
 }
 
 func CheckPlinks(dsg *addie.Design) Diagnostics {
@@ -113,21 +208,219 @@ func CheckPlink(p addie.Plink, dsg *addie.Design) Diagnostics {
 	_ds.ApplySource(fmt.Sprintf("[Plink][%v]", p.Id))
 	ds.Merge(&_ds)
 
+	//the bindings of the two endpoints are paired up in order
+	as, bs := BindingList(p.Bindings[0]), BindingList(p.Bindings[1])
+	if len(as) != len(bs) {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error",
+				fmt.Sprintf("[Plink][%v] binds %d variables of [%s] to %d variables "+
+					"of [%s], both endpoints must bind the same number of variables",
+					p.Id, len(as), e0.Identify().Name, len(bs), e1.Identify().Name)})
+	}
+
+	_, aSax := e0.(addie.Sax)
+	_, bSax := e1.(addie.Sax)
+	if aSax && bSax {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error",
+				fmt.Sprintf("[Plink][%v] connects two saxes, sensors and actuators "+
+					"must be bound to the variables of a phyo", p.Id)})
+	}
+
+	return ds
+}
+
+/*
+BindingList splits the comma separated bindings of a plink endpoint
+*/
+func BindingList(bindings string) []string {
+
+	bindings = strings.TrimSuffix(strings.Replace(bindings, " ", "", -1), ",")
+	return strings.Split(bindings, ",")
+
+}
+
+/*
+CheckActuatorBindings checks that every actuator channel of every sax is
+bound by exactly one plink
+*/
+func CheckActuatorBindings(dsg *addie.Design) Diagnostics {
+
+	var ds Diagnostics
+
+	bound := make(map[addie.Id]map[string]int)
+	for _, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Plink:
+			p := e.(addie.Plink)
+			for i, end := range p.Endpoints {
+				x, ok := dsg.Elements[end]
+				if !ok {
+					continue
+				}
+				switch x.(type) {
+				case addie.Sax:
+					if bound[end] == nil {
+						bound[end] = make(map[string]int)
+					}
+					for _, b := range BindingList(p.Bindings[i]) {
+						bound[end][b]++
+					}
+				}
+			}
+		}
+	}
+
+	for _, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Sax:
+			s := e.(addie.Sax)
+			ad, _ := ExtractActuatorData(s)
+			for a := range ad {
+				n := bound[s.Id][a]
+				if n == 0 {
+					ds.Elements = append(ds.Elements,
+						Diagnostic{"error",
+							fmt.Sprintf("[Sax][%v] the actuator [%s] is not bound by any plink",
+								s.Id, a)})
+				} else if n > 1 {
+					ds.Elements = append(ds.Elements,
+						Diagnostic{"error",
+							fmt.Sprintf("[Sax][%v] the actuator [%s] is bound by %d plinks, "+
+								"it must be bound exactly once", s.Id, a, n)})
+				}
+			}
+		}
+	}
+
 	return ds
+
+}
+
+/*
+CheckPlinkDirections checks that plinks between saxes and phyos point the
+right way. A sensor reads a state or a variable the phyo's model determines and
+an actuator drives an input of the model.
+*/
+func CheckPlinkDirections(dsg *addie.Design, models []addie.Model) Diagnostics {
+
+	var ds Diagnostics
+
+	mdls := make(map[string]addie.Model)
+	for _, m := range models {
+		mdls[m.Name] = m
+	}
+
+	for _, e := range dsg.Elements {
+		p, ok := e.(addie.Plink)
+		if !ok {
+			continue
+		}
+
+		for i := 0; i < 2; i++ {
+			x, xok := dsg.Elements[p.Endpoints[i]]
+			y, yok := dsg.Elements[p.Endpoints[1-i]]
+			if !xok || !yok {
+				continue
+			}
+			s, ok := x.(addie.Sax)
+			if !ok {
+				continue
+			}
+			phyo, ok := y.(addie.Phyo)
+			if !ok {
+				continue
+			}
+			m, ok := mdls[phyo.Model]
+			if !ok {
+				continue
+			}
+			inputs, err := ModelInputs(m)
+			if err != nil {
+				continue
+			}
+
+			sd, _ := ExtractSensorData(s)
+			ad, _ := ExtractActuatorData(s)
+			ss, ps := BindingList(p.Bindings[i]), BindingList(p.Bindings[1-i])
+			for j := 0; j < len(ss) && j < len(ps); j++ {
+				_, sensor := sd[ss[j]]
+				_, actuator := ad[ss[j]]
+				input := oneOf(ps[j], inputs)
+				if sensor && input {
+					ds.Elements = append(ds.Elements,
+						Diagnostic{"error",
+							fmt.Sprintf("[Plink][%v] binds the sensor [%s] of [%s] to the "+
+								"input [%s.%s], sensors must be bound to states or "+
+								"variables the model [%s] determines",
+								p.Id, ss[j], s.Name, phyo.Name, ps[j], m.Name)})
+				} else if actuator && !sensor && !input {
+					ds.Elements = append(ds.Elements,
+						Diagnostic{"error",
+							fmt.Sprintf("[Plink][%v] binds the actuator [%s] of [%s] to "+
+								"[%s.%s] which the model [%s] already determines, "+
+								"actuators must be bound to inputs",
+								p.Id, ss[j], s.Name, phyo.Name, ps[j], m.Name)})
+				}
+			}
+		}
+	}
+
+	return ds
+
+}
+
+/*
+ModelInputs returns the variables of a model that none of its equations
+determine and that are not parameters, these are the variables plinks drive
+*/
+func ModelInputs(m addie.Model) ([]string, error) {
+
+	eqs, err := eqn.ParseEquations(m.Equations)
+	if err != nil {
+		return nil, err
+	}
+
+	determined := make(map[string]bool)
+	for _, p := range ModelParams(m) {
+		determined[p] = true
+	}
+	for _, e := range eqs {
+		for _, v := range eqn.Vars(e.Lhs) {
+			determined[strings.TrimSuffix(v, "'")] = true
+		}
+	}
+
+	var result []string
+	for _, e := range eqs {
+		for _, v := range eqn.Vars(e.Rhs) {
+			if v == "time" || strings.HasSuffix(v, "'") || determined[v] {
+				continue
+			}
+			determined[v] = true
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+
 }
 
 func CheckEndpointBindings(bindings string, endpoint addie.Identify) Diagnostics {
 
 	var ds Diagnostics
 
-	bindings = strings.Replace(strings.TrimSuffix(bindings, ","), " ", "", -1)
-	bs := strings.Split(bindings, ",")
+	bs := BindingList(bindings)
 
 	switch endpoint.(type) {
 	case addie.Sax:
 		s := endpoint.(addie.Sax)
 		_ds := CheckSaxBindings(bs, s)
 		ds.Merge(&_ds)
+	case addie.Trace:
+		t := endpoint.(addie.Trace)
+		_ds := CheckTraceBindings(bs, t)
+		ds.Merge(&_ds)
 	}
 
 	return ds
@@ -226,3 +519,476 @@ func CheckSaxBindings(bs []string, s addie.Sax) Diagnostics {
 	return ds
 
 }
+
+func CheckTraces(dsg *addie.Design) Diagnostics {
+
+	var ds Diagnostics
+
+	for _, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Trace:
+			t := e.(addie.Trace)
+			_ds := CheckTrace(t)
+			ds.Merge(&_ds)
+		}
+	}
+
+	return ds
+
+}
+
+func CheckTrace(t addie.Trace) Diagnostics {
+
+	var ds Diagnostics
+
+	if t.File == "" {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error",
+				fmt.Sprintf("[Trace][%v] has no trace file, upload one first", t.Id)})
+	}
+
+	if !oneOf(t.Format, trace.Formats) {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error",
+				fmt.Sprintf("[Trace][%v] has unknown format [%s], it must be one of %v",
+					t.Id, t.Format, trace.Formats)})
+	}
+
+	if t.Interpolation != "" && !oneOf(t.Interpolation, trace.Interpolations) {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error",
+				fmt.Sprintf("[Trace][%v] has unknown interpolation [%s], "+
+					"it must be one of %v", t.Id, t.Interpolation, trace.Interpolations)})
+	}
+
+	if len(TraceColumns(t)) < 2 {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error",
+				fmt.Sprintf("[Trace][%v] must have a time column and at least one "+
+					"data column", t.Id)})
+	}
+
+	return ds
+
+}
+
+/*
+TraceColumns returns the columns of a trace, the leading column is the time
+column.
+*/
+func TraceColumns(t addie.Trace) []string {
+
+	cs := strings.Split(strings.Replace(t.Columns, " ", "", -1), ",")
+	if len(cs) == 0 || cs[0] == "" {
+		return nil
+	}
+
+	return cs
+
+}
+
+func CheckTraceBindings(bs []string, t addie.Trace) Diagnostics {
+
+	var ds Diagnostics
+
+	cs := TraceColumns(t)
+
+	for _, b := range bs {
+		if len(cs) > 0 && b == cs[0] {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("$source The binding [%s] is the time column of Trace [%v]",
+						b, t.Id)})
+			continue
+		}
+		if !oneOf(b, cs) {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("$source The binding [%s] does not exist in Trace [%v]",
+						b, t.Id)})
+		}
+	}
+
+	return ds
+
+}
+
+func oneOf(s string, xs []string) bool {
+	for _, x := range xs {
+		if s == x {
+			return true
+		}
+	}
+	return false
+}
+
+func CheckSimSettings(s addie.SimSettings, dsg *addie.Design,
+	models []addie.Model) Diagnostics {
+
+	var ds Diagnostics
+
+	var fail = func(format string, args ...interface{}) {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error", "[SimSettings] " + fmt.Sprintf(format, args...)})
+	}
+
+	if s.End <= s.Begin {
+		fail("the end time [%g] must come after the begin time [%g]", s.End, s.Begin)
+	}
+	if s.MaxStep <= 0 {
+		fail("the max step [%g] must be positive", s.MaxStep)
+	}
+	if s.ClusterSize < 0 {
+		fail("the cluster size [%d] can not be negative", s.ClusterSize)
+	}
+	if s.Solver != "" && !oneOf(s.Solver, addie.Solvers) {
+		fail("unknown solver [%s], it must be one of %v", s.Solver, addie.Solvers)
+	}
+	if s.AbsTol < 0 {
+		fail("the absolute tolerance [%g] can not be negative", s.AbsTol)
+	}
+	if s.RelTol < 0 || s.RelTol >= 1 {
+		fail("the relative tolerance [%g] must be in [0, 1)", s.RelTol)
+	}
+	if s.SampleInterval < 0 {
+		fail("the sample interval [%g] can not be negative", s.SampleInterval)
+	}
+	if s.SampleInterval > s.End-s.Begin {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"warning",
+				fmt.Sprintf("[SimSettings] the sample interval [%g] is longer than "+
+					"the simulation, only the initial state will be recorded",
+					s.SampleInterval)})
+	}
+	if s.CheckpointInterval < 0 {
+		fail("the checkpoint interval [%g] can not be negative", s.CheckpointInterval)
+	}
+	if s.RealTime && s.RealTimeRatio <= 0 {
+		fail("the real time ratio [%g] must be positive", s.RealTimeRatio)
+	}
+
+	mdls := make(map[string]addie.Model)
+	for _, m := range models {
+		mdls[m.Name] = m
+	}
+
+	record := strings.Split(strings.Replace(s.Record, " ", "", -1), ",")
+	for _, r := range record {
+		if r == "" {
+			continue
+		}
+		_ds := CheckRecordVar(r, dsg, mdls)
+		ds.Merge(&_ds)
+	}
+
+	return ds
+
+}
+
+/*
+CheckModelRefs checks that the published models phyos reference were
+resolved, and warns about references that are not pinned to a version since
+a new release of the model changes the design.
+*/
+func CheckModelRefs(dsg *addie.Design, models []addie.Model) Diagnostics {
+
+	var ds Diagnostics
+
+	mdls := make(map[string]bool)
+	for _, m := range models {
+		mdls[m.Name] = true
+	}
+
+	for _, e := range dsg.Elements {
+		p, ok := e.(addie.Phyo)
+		if !ok {
+			continue
+		}
+		ref, ok := addie.ParseModelRef(p.Model)
+		if !ok {
+			continue
+		}
+
+		if !mdls[p.Model] {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("The phyo [%v] references the published model [%s] "+
+						"which does not exist or is not readable", p.Id, ref)})
+			continue
+		}
+
+		if !ref.Pinned() {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"warning",
+					fmt.Sprintf("The phyo [%v] follows the latest version of [%s], "+
+						"pin a version with %s@<version> to keep the design stable",
+						p.Id, ref, ref)})
+		}
+	}
+
+	return ds
+
+}
+
+/*
+CheckRecordVar checks that a recorded variable of the form element.variable
+refers to something the simulation actually computes.
+*/
+func CheckRecordVar(r string, dsg *addie.Design,
+	models map[string]addie.Model) Diagnostics {
+
+	var ds Diagnostics
+
+	var fail = func(format string, args ...interface{}) {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error", "[SimSettings] " + fmt.Sprintf(format, args...)})
+	}
+
+	parts := strings.Split(r, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		fail("the recorded variable [%s] must be of the form element.variable", r)
+		return ds
+	}
+	name, v := parts[0], parts[1]
+
+	var e addie.Identify
+	for _, x := range dsg.Elements {
+		if x.Identify().Name == name {
+			e = x
+			break
+		}
+	}
+	if e == nil {
+		fail("the recorded variable [%s] references non-existant element [%s]", r, name)
+		return ds
+	}
+
+	switch e.(type) {
+	case addie.Phyo:
+		p := e.(addie.Phyo)
+		m, ok := models[p.Model]
+		if ok && !modelHasVar(m, v) {
+			fail("the recorded variable [%s] does not exist in model [%s]", r, m.Name)
+		}
+	case addie.Trace:
+		t := e.(addie.Trace)
+		if !oneOf(v, TraceColumns(t)) {
+			fail("the recorded variable [%s] is not a column of the trace", r)
+		}
+	case addie.Sax:
+		s := e.(addie.Sax)
+		sd, _ := ExtractSensorData(s)
+		ad, _ := ExtractActuatorData(s)
+		_, sok := sd[v]
+		_, aok := ad[v]
+		if !sok && !aok {
+			fail("the recorded variable [%s] is not a sensor or actuator of the sax", r)
+		}
+	default:
+		fail("the recorded variable [%s] does not belong to a physical element", r)
+	}
+
+	return ds
+
+}
+
+func modelHasVar(m addie.Model, v string) bool {
+
+	re, err := regexp.Compile("\\b" + regexp.QuoteMeta(v) + "\\b")
+	if err != nil {
+		return false
+	}
+
+	return re.MatchString(m.Equations) || re.MatchString(m.Params)
+
+}
+
+/*
+CheckParamOverrides checks the element.parameter values a simulation is
+resumed with against the parameters of the models the elements instantiate.
+*/
+func CheckParamOverrides(params map[string]float64, dsg *addie.Design,
+	models []addie.Model) Diagnostics {
+
+	var ds Diagnostics
+
+	mdls := make(map[string]addie.Model)
+	for _, m := range models {
+		mdls[m.Name] = m
+	}
+
+	phyos := make(map[string]addie.Phyo)
+	for _, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Phyo:
+			p := e.(addie.Phyo)
+			phyos[p.Name] = p
+		}
+	}
+
+	for k, _ := range params {
+		parts := strings.Split(k, ".")
+		if len(parts) != 2 {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("[Resume] the parameter [%s] must be of the form "+
+						"element.parameter", k)})
+			continue
+		}
+		p, ok := phyos[parts[0]]
+		if !ok {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("[Resume] the parameter [%s] references non-existant "+
+						"phyo [%s]", k, parts[0])})
+			continue
+		}
+		m, ok := mdls[p.Model]
+		if !ok {
+			continue
+		}
+		if !oneOf(parts[1], ModelParams(m)) {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("[Resume] [%s] is not a parameter of model [%s]",
+						parts[1], m.Name)})
+		}
+	}
+
+	return ds
+
+}
+
+/*
+ModelParams returns the parameter names of a model.
+*/
+func ModelParams(m addie.Model) []string {
+
+	var ps []string
+	for _, p := range strings.Split(strings.Replace(m.Params, " ", "", -1), ",") {
+		if p != "" {
+			ps = append(ps, p)
+		}
+	}
+
+	return ps
+
+}
+
+/*
+CheckInitSet checks that every phyo an initial condition set references
+exists and that every value it sets belongs to a state of the phyo's model.
+*/
+func CheckInitSet(set addie.InitSet, dsg *addie.Design,
+	models []addie.Model) Diagnostics {
+
+	var ds Diagnostics
+
+	mdls := make(map[string]addie.Model)
+	for _, m := range models {
+		mdls[m.Name] = m
+	}
+
+	for _, pi := range set.Inits {
+		e, ok := dsg.Elements[pi.Phyo]
+		if !ok {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("[InitSet][%s] references non-existant id [%v]",
+						set.Name, pi.Phyo)})
+			continue
+		}
+		p, ok := e.(addie.Phyo)
+		if !ok {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("[InitSet][%s] references [%v] which is not a phyo",
+						set.Name, pi.Phyo)})
+			continue
+		}
+		m, ok := mdls[p.Model]
+		if !ok {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("[InitSet][%s] phyo [%v] has unknown model [%s]",
+						set.Name, pi.Phyo, p.Model)})
+			continue
+		}
+		states := m.States()
+		for state, _ := range pi.Values {
+			if !oneOf(state, states) {
+				ds.Elements = append(ds.Elements,
+					Diagnostic{"error",
+						fmt.Sprintf("[InitSet][%s] [%s] is not a state variable of "+
+							"model [%s] used by phyo [%v]", set.Name, state, m.Name, pi.Phyo)})
+			}
+		}
+	}
+
+	return ds
+
+}
+
+/*
+CheckProfiles checks the OS of every computer and the node profile of every
+computer, router and sax against the catalog of node profiles the testbed
+offers. A computer with a profile must run the image of that profile. A
+testbed without a catalog accepts any node.
+*/
+func CheckProfiles(dsg *addie.Design, profiles []addie.NodeProfile) Diagnostics {
+
+	var ds Diagnostics
+	if len(profiles) == 0 {
+		return ds
+	}
+
+	var names, images []string
+	catalog := make(map[string]addie.NodeProfile)
+	for _, p := range profiles {
+		catalog[p.Name] = p
+		names = append(names, p.Name)
+		if p.OSID != "" && !oneOf(p.OSID, images) {
+			images = append(images, p.OSID)
+		}
+	}
+
+	var checkProfile = func(id addie.Id, attrs addie.Attributes) {
+		p, ok := attrs[addie.ProfileAttribute]
+		if ok && !oneOf(p, names) {
+			ds.Elements = append(ds.Elements,
+				Diagnostic{"error",
+					fmt.Sprintf("[Profile][%v] the node profile [%s] is not in the "+
+						"catalog, it must be one of %v", id, p, names)})
+		}
+	}
+
+	for id, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Computer:
+			c := e.(addie.Computer)
+			checkProfile(id, c.Attributes)
+			if c.OS != "" && !oneOf(c.OS, images) {
+				ds.Elements = append(ds.Elements,
+					Diagnostic{"error",
+						fmt.Sprintf("[Profile][%v] the OS [%s] is not an image the "+
+							"testbed offers, it must be one of %v", id, c.OS, images)})
+			}
+			p, ok := catalog[c.Attributes[addie.ProfileAttribute]]
+			if ok && c.OS != "" && p.OSID != "" && c.OS != p.OSID {
+				ds.Elements = append(ds.Elements,
+					Diagnostic{"error",
+						fmt.Sprintf("[Profile][%v] the OS [%s] is not the image [%s] "+
+							"of the node profile [%s]", id, c.OS, p.OSID, p.Name)})
+			}
+		case addie.Router:
+			r := e.(addie.Router)
+			checkProfile(id, r.Attributes)
+		case addie.Sax:
+			s := e.(addie.Sax)
+			checkProfile(id, s.Attributes)
+		}
+	}
+
+	return ds
+
+}
@@ -0,0 +1,222 @@
+package sema
+
+import (
+	"addie"
+	"strings"
+	"testing"
+)
+
+func expectError(t *testing.T, ds Diagnostics, fragment string) {
+
+	for _, d := range ds.Elements {
+		if d.Level == "error" && strings.Contains(d.Message, fragment) {
+			return
+		}
+	}
+	t.Fatalf("expected an error containing '%s', got %v", fragment, ds)
+
+}
+
+func TestCheckPlinkArity(t *testing.T) {
+
+	dsg := structureDesign([2]string{"upper.qout", "lower.qin"})
+	for _, e := range dsg.Elements {
+		if l, ok := e.(addie.Plink); ok {
+			l.Bindings[0] = "qout, h"
+			dsg.Elements[l.Id] = l
+			expectError(t, CheckPlink(l, dsg), "binds 2 variables of [upper] to 1")
+		}
+	}
+
+}
+
+func TestCheckPlinkSaxes(t *testing.T) {
+
+	dsg := structureDesign([2]string{"valve.q", "upper.qin"})
+	s := addie.Sax{}
+	s.Id = addie.Id{Name: "gauge", Sys: "root", Design: "talisker"}
+	s.Sense = "h(10)"
+	dsg.Elements[s.Id] = s
+
+	l := addie.Plink{}
+	l.Id = addie.Id{Name: "wire", Sys: "root", Design: "talisker"}
+	l.Endpoints = [2]addie.Id{s.Id, {Name: "valve", Sys: "root", Design: "talisker"}}
+	l.Bindings = [2]string{"h", "q"}
+	dsg.Elements[l.Id] = l
+
+	expectError(t, CheckPlink(l, dsg), "connects two saxes")
+
+}
+
+func TestCheckActuatorBindings(t *testing.T) {
+
+	ds := CheckActuatorBindings(structureDesign([2]string{"valve.q", "upper.qin"}))
+	if ds.Fatal() {
+		t.Fatalf("a bound actuator was rejected %v", ds)
+	}
+
+	ds = CheckActuatorBindings(structureDesign())
+	expectError(t, ds, "the actuator [q] is not bound by any plink")
+
+	ds = CheckActuatorBindings(structureDesign(
+		[2]string{"valve.q", "upper.qin"},
+		[2]string{"valve.q", "lower.qin"}))
+	expectError(t, ds, "the actuator [q] is bound by 2 plinks")
+
+}
+
+func TestCheckPlinkDirections(t *testing.T) {
+
+	tank := addie.Model{Name: "Tank", Params: "A,k",
+		Equations: "h' = (qin - qout)/A\nqout = k*h"}
+
+	inputs, err := ModelInputs(tank)
+	if err != nil || len(inputs) != 1 || inputs[0] != "qin" {
+		t.Fatalf("bad model inputs %v %v", inputs, err)
+	}
+
+	ds := CheckPlinkDirections(structureDesign([2]string{"valve.q", "upper.qin"}),
+		[]addie.Model{tank})
+	if len(ds.Elements) != 0 {
+		t.Fatalf("an actuator driving an input was rejected %v", ds)
+	}
+
+	ds = CheckPlinkDirections(structureDesign([2]string{"upper.qout", "valve.q"}),
+		[]addie.Model{tank})
+	expectError(t, ds, "actuators must be bound to inputs")
+
+	dsg := structureDesign()
+	s := addie.Sax{}
+	s.Id = addie.Id{Name: "gauge", Sys: "root", Design: "talisker"}
+	s.Sense = "y(10)"
+	dsg.Elements[s.Id] = s
+	for _, n := range []string{"h", "qin"} {
+		l := addie.Plink{}
+		l.Id = addie.Id{Name: "wire-" + n, Sys: "root", Design: "talisker"}
+		l.Endpoints = [2]addie.Id{s.Id, {Name: "lower", Sys: "root", Design: "talisker"}}
+		l.Bindings = [2]string{"y", n}
+		dsg.Elements[l.Id] = l
+	}
+	ds = CheckPlinkDirections(dsg, []addie.Model{tank})
+	if len(ds.Elements) != 1 {
+		t.Fatalf("expected one direction error %v", ds)
+	}
+	expectError(t, ds, "the input [lower.qin]")
+
+}
+
+func TestCheckParams(t *testing.T) {
+
+	dsg := structureDesign([2]string{"valve.q", "upper.qin"})
+	dsg.Params["q_max"] = 2
+	s := dsg.Elements[addie.Id{Name: "valve", Sys: "root", Design: "talisker"}].(addie.Sax)
+	s.Actuate = "q($q_max,1)"
+	dsg.Elements[s.Id] = s
+
+	if ds := CheckParams(dsg); len(ds.Elements) != 0 {
+		t.Fatalf("a resolvable design was rejected %v", ds)
+	}
+
+	//the checks run on the resolved design
+	if ds := Check(dsg); ds.Fatal() {
+		t.Fatalf("a parameterized actuator was rejected %v", ds)
+	}
+
+	dsg.Params["2fast"] = 1
+	delete(dsg.Params, "q_max")
+	ds := CheckParams(dsg)
+	expectError(t, ds, "'2fast' is not a valid parameter name")
+	expectError(t, ds, "[Param][valve.root.talisker]")
+
+}
+
+func TestCheckProfiles(t *testing.T) {
+
+	dsg := addie.EmptyDesign("talisker")
+	c := addie.Computer{}
+	c.Id = addie.Id{Name: "hmi", Sys: "root", Design: "talisker"}
+	c.OS = "Ubuntu1404-64-STD"
+	dsg.Elements[c.Id] = c
+	r := addie.Router{}
+	r.Id = addie.Id{Name: "gw", Sys: "root", Design: "talisker"}
+	r.Attributes = addie.Attributes{addie.ProfileAttribute: "router"}
+	dsg.Elements[r.Id] = r
+
+	//without a catalog anything goes
+	if ds := CheckProfiles(&dsg, nil); len(ds.Elements) != 0 {
+		t.Fatalf("a design was checked against an empty catalog %v", ds)
+	}
+
+	catalog := []addie.NodeProfile{
+		{Name: "router", Hardware: "pc3000", OSID: "Ubuntu1404-64-STD"},
+		{Name: "hmi", Hardware: "dl380g3", OSID: "Ubuntu1604-64-STD"},
+	}
+	if ds := CheckProfiles(&dsg, catalog); len(ds.Elements) != 0 {
+		t.Fatalf("a design within the catalog was rejected %v", ds)
+	}
+
+	//the OS of a computer has to be the image of its profile
+	c.Attributes = addie.Attributes{addie.ProfileAttribute: "hmi"}
+	dsg.Elements[c.Id] = c
+	expectError(t, CheckProfiles(&dsg, catalog),
+		"the OS [Ubuntu1404-64-STD] is not the image [Ubuntu1604-64-STD] of the node profile [hmi]")
+	c.Attributes = nil
+
+	c.OS = "Windows3.1"
+	dsg.Elements[c.Id] = c
+	r.Attributes[addie.ProfileAttribute] = "mainframe"
+	ds := CheckProfiles(&dsg, catalog)
+	expectError(t, ds, "the OS [Windows3.1] is not an image the testbed offers")
+	expectError(t, ds, "the node profile [mainframe] is not in the catalog")
+
+	//the catalog check is part of the compile check and fails it
+	settings := addie.SimSettings{Begin: 0, End: 10, MaxStep: 1e-3}
+	ds = CheckCompile(&dsg, settings, nil, nil, catalog)
+	expectError(t, ds, "the node profile [mainframe] is not in the catalog")
+	for _, d := range ds.Elements {
+		if d.Level == "success" {
+			t.Fatalf("a design outside the catalog passed the compile check %v", ds)
+		}
+	}
+
+}
+
+func TestCheckSimSettings(t *testing.T) {
+
+	dsg := structureDesign([2]string{"valve.q", "upper.qin"})
+	tank := addie.Model{Name: "Tank", Params: "A", Equations: "h' = (qin - qout)/A"}
+	ok := addie.SimSettings{Begin: 0, End: 10, MaxStep: 1e-3}
+
+	for _, c := range []struct {
+		change func(*addie.SimSettings)
+		error  string
+	}{
+		{func(s *addie.SimSettings) {}, ""},
+		{func(s *addie.SimSettings) { s.Solver = "cvode" }, ""},
+		{func(s *addie.SimSettings) { s.AbsTol, s.RelTol = 1e-8, 1e-6 }, ""},
+		{func(s *addie.SimSettings) { s.Record = "upper.h, valve.q," }, ""},
+		{func(s *addie.SimSettings) { s.End = 0 }, "must come after the begin time"},
+		{func(s *addie.SimSettings) { s.MaxStep = 0 }, "max step [0] must be positive"},
+		{func(s *addie.SimSettings) { s.Solver = "rk4" }, "unknown solver [rk4]"},
+		{func(s *addie.SimSettings) { s.AbsTol = -1 }, "absolute tolerance [-1]"},
+		{func(s *addie.SimSettings) { s.RelTol = 1 }, "relative tolerance [1] must be in"},
+		{func(s *addie.SimSettings) { s.RealTime = true }, "real time ratio [0]"},
+		{func(s *addie.SimSettings) { s.Record = "upper" }, "of the form element.variable"},
+		{func(s *addie.SimSettings) { s.Record = "ghost.h" }, "non-existant element [ghost]"},
+		{func(s *addie.SimSettings) { s.Record = "upper.x" }, "does not exist in model [Tank]"},
+		{func(s *addie.SimSettings) { s.Record = "valve.x" }, "not a sensor or actuator"},
+		{func(s *addie.SimSettings) { s.Record = "pipe0.q" }, "not belong to a physical element"},
+	} {
+		s := ok
+		c.change(&s)
+		ds := CheckSimSettings(s, dsg, []addie.Model{tank})
+		if c.error == "" {
+			if ds.Fatal() {
+				t.Fatalf("good settings %+v were refused %v", s, ds)
+			}
+			continue
+		}
+		expectError(t, ds, c.error)
+	}
+
+}
@@ -0,0 +1,324 @@
+/*
+This file contains the structural analysis of the physical half of a design.
+The equations of the phyos and the plink bindings between them form a
+bipartite incidence graph between equations and the variables they involve.
+A perfect matching of that graph assigns every equation the variable it
+determines, without one the system is structurally singular. Cycles between
+equations in the matched graph are algebraic loops.
+*/
+package sema
+
+import (
+	"addie"
+	"addie/eqn"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type incidence struct {
+	element addie.Id
+	src     string
+	vars    []int
+}
+
+type structure struct {
+	eqs   []incidence
+	vars  []string
+	index map[string]int
+}
+
+func (s *structure) variable(name string) int {
+
+	i, ok := s.index[name]
+	if !ok {
+		i = len(s.vars)
+		s.index[name] = i
+		s.vars = append(s.vars, name)
+	}
+
+	return i
+
+}
+
+/*
+CheckStructure checks that the physical half of a design determines every
+variable exactly once and that it has no algebraic loops
+*/
+func CheckStructure(dsg *addie.Design, models []addie.Model) Diagnostics {
+
+	var ds Diagnostics
+
+	var fail = func(format string, args ...interface{}) {
+		ds.Elements = append(ds.Elements,
+			Diagnostic{"error", "[Structure] " + fmt.Sprintf(format, args...)})
+	}
+
+	s, _ds := buildStructure(dsg, models)
+	ds.Merge(&_ds)
+	if s == nil {
+		return ds
+	}
+
+	match := s.match()
+
+	matched := make(map[int]bool)
+	for i, e := range s.eqs {
+		if match[i] < 0 {
+			fail("the %s of [%s] is over-determined, every variable it "+
+				"involves is already determined by another equation", e.src, e.element.Name)
+			continue
+		}
+		matched[match[i]] = true
+	}
+	for v, name := range s.vars {
+		if !matched[v] {
+			fail("nothing determines the variable [%s], bind it with a plink to "+
+				"an actuator, a trace or another phyo", name)
+		}
+	}
+	if ds.Fatal() {
+		return ds
+	}
+
+	for _, loop := range s.loops(match) {
+		var vars []string
+		elements := make(map[string]bool)
+		for _, i := range loop {
+			vars = append(vars, s.vars[match[i]])
+			elements[s.eqs[i].element.Name] = true
+		}
+		var es []string
+		for e := range elements {
+			es = append(es, e)
+		}
+		sort.Strings(es)
+		sort.Strings(vars)
+		fail("algebraic loop through the variables [%s] involving the elements [%s]",
+			strings.Join(vars, ", "), strings.Join(es, ", "))
+	}
+
+	return ds
+
+}
+
+func buildStructure(dsg *addie.Design, models []addie.Model) (*structure, Diagnostics) {
+
+	var ds Diagnostics
+	s := &structure{index: make(map[string]int)}
+
+	mdls := make(map[string]addie.Model)
+	for _, m := range models {
+		mdls[m.Name] = m
+	}
+
+	var phyos []addie.Phyo
+	var plinks []addie.Plink
+	for _, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Phyo:
+			phyos = append(phyos, e.(addie.Phyo))
+		case addie.Plink:
+			plinks = append(plinks, e.(addie.Plink))
+		}
+	}
+	sort.Slice(phyos, func(i, j int) bool { return phyos[i].Name < phyos[j].Name })
+	sort.Slice(plinks, func(i, j int) bool { return plinks[i].Name < plinks[j].Name })
+
+	//the variables of each phyo that are known rather than solved for
+	known := make(map[string]bool)
+
+	for _, p := range phyos {
+		m, ok := mdls[p.Model]
+		if !ok {
+			continue
+		}
+		eqs, err := eqn.ParseEquations(m.Equations)
+		if err != nil {
+			ds.Elements = append(ds.Elements, Diagnostic{"warning",
+				fmt.Sprintf("[Structure] the model [%s] of phyo [%s] could not be "+
+					"parsed, the structure of the design is not checked: %v",
+					m.Name, p.Name, err)})
+			return nil, ds
+		}
+
+		for _, x := range ModelParams(m) {
+			known[p.Name+"."+x] = true
+		}
+		for _, x := range m.States() {
+			known[p.Name+"."+x] = true
+		}
+
+		for _, e := range eqs {
+			inc := incidence{element: p.Id, src: "equation '" + e.Src + "'"}
+			seen := make(map[string]bool)
+			for _, v := range append(eqn.Vars(e.Lhs), eqn.Vars(e.Rhs)...) {
+				name := p.Name + "." + v
+				if v == "time" || known[name] || seen[name] {
+					continue
+				}
+				seen[name] = true
+				inc.vars = append(inc.vars, s.variable(name))
+			}
+			s.eqs = append(s.eqs, inc)
+		}
+	}
+
+	for _, l := range plinks {
+		a, aok := dsg.Elements[l.Endpoints[0]]
+		b, bok := dsg.Elements[l.Endpoints[1]]
+		if !aok || !bok {
+			continue
+		}
+		as := strings.Split(strings.Replace(l.Bindings[0], " ", "", -1), ",")
+		bs := strings.Split(strings.Replace(l.Bindings[1], " ", "", -1), ",")
+		for i := 0; i < len(as) && i < len(bs); i++ {
+			inc := incidence{element: l.Id,
+				src: fmt.Sprintf("binding '%s.%s ~ %s.%s'", a.Identify().Name, as[i],
+					b.Identify().Name, bs[i])}
+			sensed := false
+			for _, end := range []struct {
+				e addie.Identify
+				v string
+			}{{a, as[i]}, {b, bs[i]}} {
+				switch end.e.(type) {
+				case addie.Phyo:
+					name := end.e.Identify().Name + "." + end.v
+					if !known[name] {
+						inc.vars = append(inc.vars, s.variable(name))
+					}
+				case addie.Sax:
+					sensors, _ := ExtractSensorData(end.e.(addie.Sax))
+					_, sensed = sensors[end.v]
+				}
+			}
+			//a sensor only reads the value it is bound to
+			if sensed {
+				continue
+			}
+			s.eqs = append(s.eqs, inc)
+		}
+	}
+
+	return s, ds
+
+}
+
+/*
+The match function computes a maximum matching of equations to variables with
+augmenting paths, it returns the variable each equation is matched to or -1
+*/
+func (s *structure) match() []int {
+
+	eqOf := make([]int, len(s.vars))
+	for i := range eqOf {
+		eqOf[i] = -1
+	}
+	varOf := make([]int, len(s.eqs))
+	for i := range varOf {
+		varOf[i] = -1
+	}
+
+	var augment func(e int, visited []bool) bool
+	augment = func(e int, visited []bool) bool {
+		for _, v := range s.eqs[e].vars {
+			if visited[v] {
+				continue
+			}
+			visited[v] = true
+			if eqOf[v] < 0 || augment(eqOf[v], visited) {
+				eqOf[v] = e
+				varOf[e] = v
+				return true
+			}
+		}
+		return false
+	}
+
+	for e := range s.eqs {
+		augment(e, make([]bool, len(s.vars)))
+	}
+
+	return varOf
+
+}
+
+/*
+The loops function finds the algebraic loops of a matched system. Equations
+depend on the equations that determine the variables they involve, except for
+state derivatives which the integrator breaks. The strongly connected
+components with more than one equation are the loops.
+*/
+func (s *structure) loops(match []int) [][]int {
+
+	eqOf := make(map[int]int)
+	for e, v := range match {
+		eqOf[v] = e
+	}
+
+	deps := make([][]int, len(s.eqs))
+	for e, inc := range s.eqs {
+		for _, v := range inc.vars {
+			if v == match[e] || strings.HasSuffix(s.vars[v], "'") {
+				continue
+			}
+			deps[e] = append(deps[e], eqOf[v])
+		}
+	}
+
+	//Tarjan's strongly connected components
+	var result [][]int
+	index := make([]int, len(s.eqs))
+	low := make([]int, len(s.eqs))
+	onStack := make([]bool, len(s.eqs))
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	next := 0
+
+	var connect func(e int)
+	connect = func(e int) {
+		index[e], low[e] = next, next
+		next++
+		stack = append(stack, e)
+		onStack[e] = true
+
+		for _, d := range deps[e] {
+			if index[d] < 0 {
+				connect(d)
+				if low[d] < low[e] {
+					low[e] = low[d]
+				}
+			} else if onStack[d] && index[d] < low[e] {
+				low[e] = index[d]
+			}
+		}
+
+		if low[e] == index[e] {
+			var scc []int
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				scc = append(scc, top)
+				if top == e {
+					break
+				}
+			}
+			if len(scc) > 1 {
+				sort.Ints(scc)
+				result = append(result, scc)
+			}
+		}
+	}
+
+	for e := range s.eqs {
+		if index[e] < 0 {
+			connect(e)
+		}
+	}
+
+	return result
+
+}
@@ -0,0 +1,81 @@
+package sema
+
+import (
+	"addie"
+	"strings"
+	"testing"
+)
+
+func structureDesign(bindings ...[2]string) *addie.Design {
+
+	dsg := addie.EmptyDesign("talisker")
+	id := func(n string) addie.Id {
+		return addie.Id{Name: n, Sys: "root", Design: "talisker"}
+	}
+
+	for _, n := range []string{"upper", "lower"} {
+		p := addie.Phyo{}
+		p.Id = id(n)
+		p.Model = "Tank"
+		dsg.Elements[p.Id] = p
+	}
+	s := addie.Sax{}
+	s.Id = id("valve")
+	s.Actuate = "q(0,1)"
+	dsg.Elements[s.Id] = s
+
+	for i, b := range bindings {
+		l := addie.Plink{}
+		l.Id = id("pipe" + string('0'+rune(i)))
+		l.Endpoints = [2]addie.Id{id(strings.Split(b[0], ".")[0]),
+			id(strings.Split(b[1], ".")[0])}
+		l.Bindings = [2]string{strings.Split(b[0], ".")[1], strings.Split(b[1], ".")[1]}
+		dsg.Elements[l.Id] = l
+	}
+
+	return &dsg
+
+}
+
+func TestCheckStructure(t *testing.T) {
+
+	tank := addie.Model{Name: "Tank", Params: "A,k",
+		Equations: "h' = (qin - qout)/A\nqout = k*h"}
+
+	//a cascade fed by a valve is fine
+	ds := CheckStructure(structureDesign(
+		[2]string{"valve.q", "upper.qin"},
+		[2]string{"upper.qout", "lower.qin"}), []addie.Model{tank})
+	if len(ds.Elements) != 0 {
+		t.Fatalf("cascade has diagnostics %v", ds)
+	}
+
+	//nothing feeds the upper tank
+	ds = CheckStructure(structureDesign(
+		[2]string{"upper.qout", "lower.qin"}), []addie.Model{tank})
+	if !ds.Fatal() || !strings.Contains(ds.Elements[0].Message, "upper.qin") {
+		t.Fatalf("under-determined design not caught %v", ds)
+	}
+
+	//both outflows are already determined by the tanks
+	ds = CheckStructure(structureDesign(
+		[2]string{"valve.q", "upper.qin"},
+		[2]string{"valve.q", "lower.qin"},
+		[2]string{"upper.qout", "lower.qout"}), []addie.Model{tank})
+	if !ds.Fatal() || !strings.Contains(ds.Elements[0].Message, "pipe2") {
+		t.Fatalf("over-determined design not caught %v", ds)
+	}
+
+	//outflow that depends on the inflow of the other tank and vice versa
+	direct := addie.Model{Name: "Tank", Params: "A,k",
+		Equations: "h' = (qin - qout)/A\nqout = k*h + qin"}
+	ds = CheckStructure(structureDesign(
+		[2]string{"upper.qout", "lower.qin"},
+		[2]string{"lower.qout", "upper.qin"}), []addie.Model{direct})
+	if !ds.Fatal() || len(ds.Elements) != 1 ||
+		!strings.Contains(ds.Elements[0].Message, "algebraic loop") ||
+		!strings.Contains(ds.Elements[0].Message, "[lower, pipe0, pipe1, upper]") {
+		t.Fatalf("algebraic loop not caught %v", ds)
+	}
+
+}
@@ -0,0 +1,131 @@
+/*
+This file contains the code for working with the state snapshots a running
+simulation writes into its package directory
+*/
+package sim
+
+import (
+	"addie"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const CheckpointDir = "checkpoints"
+
+const checkpointExt = ".cyck"
+
+/*
+A Checkpoint is a snapshot of the simulation state at simulation time Time.
+For cluster simulations every kry node writes its own snapshot under the
+same name.
+*/
+type Checkpoint struct {
+	Name    string    `json:"name"`
+	Time    float64   `json:"time"`
+	Written time.Time `json:"written"`
+}
+
+/*
+The CheckpointName function returns the file name of the snapshot taken at
+simulation time t
+*/
+func CheckpointName(t float64) string {
+	return "ckpt_" + strconv.FormatFloat(t, 'f', -1, 64) + checkpointExt
+}
+
+/*
+The CheckpointTime function recovers the simulation time a snapshot was taken
+at from its file name
+*/
+func CheckpointTime(name string) (float64, error) {
+
+	if !strings.HasPrefix(name, "ckpt_") || !strings.HasSuffix(name, checkpointExt) {
+		return 0, fmt.Errorf("'%s' is not a checkpoint", name)
+	}
+
+	t, err := strconv.ParseFloat(
+		strings.TrimSuffix(strings.TrimPrefix(name, "ckpt_"), checkpointExt), 64)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a checkpoint", name)
+	}
+
+	return t, nil
+
+}
+
+/*
+The ListCheckpoints function lists the snapshots that can be resumed from,
+ordered by simulation time. A snapshot of a cluster simulation is only listed
+when every one of the given package directories holds it.
+*/
+func ListCheckpoints(cypks []string) ([]Checkpoint, error) {
+
+	var result []Checkpoint
+
+	if len(cypks) == 0 {
+		return result, nil
+	}
+
+	fs, err := ioutil.ReadDir(cypks[0] + "/" + CheckpointDir)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read checkpoint directory")
+	}
+
+	for _, f := range fs {
+		t, err := CheckpointTime(f.Name())
+		if err != nil {
+			continue
+		}
+
+		complete := true
+		for _, d := range cypks[1:] {
+			_, err := os.Stat(d + "/" + CheckpointDir + "/" + f.Name())
+			if err != nil {
+				complete = false
+				break
+			}
+		}
+		if !complete {
+			continue
+		}
+
+		result = append(result, Checkpoint{Name: f.Name(), Time: t, Written: f.ModTime()})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Time < result[j].Time })
+
+	return result, nil
+
+}
+
+/*
+The ResumeArgs function returns the runtime arguments for continuing a
+simulation from a checkpoint. The simulation begins at the time of the
+snapshot and the given element.parameter values replace those in the design.
+*/
+func ResumeArgs(s addie.SimSettings, c Checkpoint, params map[string]float64) []string {
+
+	s.Begin = c.Time
+	args := RuntimeArgs(s)
+	args = append(args, "--resume", CheckpointDir+"/"+c.Name)
+
+	var keys []string
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--set", k+"="+ftoa(params[k]))
+	}
+
+	return args
+
+}
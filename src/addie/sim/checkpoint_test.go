@@ -0,0 +1,67 @@
+package sim
+
+import (
+	"addie"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestListCheckpoints(t *testing.T) {
+
+	kry0, err := ioutil.TempDir("", "kry0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(kry0)
+	kry1, err := ioutil.TempDir("", "kry1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(kry1)
+
+	os.MkdirAll(kry0+"/"+CheckpointDir, 0755)
+	os.MkdirAll(kry1+"/"+CheckpointDir, 0755)
+
+	for _, tm := range []float64{10, 2.5, 5} {
+		ioutil.WriteFile(kry0+"/"+CheckpointDir+"/"+CheckpointName(tm), nil, 0644)
+	}
+	ioutil.WriteFile(kry0+"/"+CheckpointDir+"/notes.txt", nil, 0644)
+	//kry1 died before writing its snapshot at 10
+	ioutil.WriteFile(kry1+"/"+CheckpointDir+"/"+CheckpointName(2.5), nil, 0644)
+	ioutil.WriteFile(kry1+"/"+CheckpointDir+"/"+CheckpointName(5), nil, 0644)
+
+	cs, err := ListCheckpoints([]string{kry0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 3 || cs[0].Time != 2.5 || cs[2].Time != 10 {
+		t.Fatalf("bad single node checkpoints %v", cs)
+	}
+
+	cs, err = ListCheckpoints([]string{kry0, kry1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 2 || cs[1].Name != CheckpointName(5) {
+		t.Fatalf("bad cluster checkpoints %v", cs)
+	}
+
+}
+
+func TestResumeArgs(t *testing.T) {
+
+	s := addie.SimSettings{Begin: 0, End: 20, MaxStep: 1e-3}
+	c := Checkpoint{Name: CheckpointName(5), Time: 5}
+
+	args := ResumeArgs(s, c, map[string]float64{"rtr.H": 3, "pump.k": 0.5})
+	expected := []string{"5e+00", "2e+01", "1e-03",
+		"--resume", "checkpoints/ckpt_5.cyck",
+		"--set", "pump.k=5e-01", "--set", "rtr.H=3e+00"}
+
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("bad resume args %v", args)
+	}
+
+}
@@ -0,0 +1,103 @@
+/*
+This file contains the code for applying named initial condition sets to a
+design before it is simulated
+*/
+package sim
+
+import (
+	"addie"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+The ApplyInitSet function returns a copy of a design whose phyos start from
+the initial conditions in set. States the set does not mention keep the
+initial values from the phyo's own Init.
+*/
+func ApplyInitSet(dsg *addie.Design, set *addie.InitSet) *addie.Design {
+
+	d := addie.EmptyDesign(dsg.Name)
+	d.Params = dsg.Params
+	for k, v := range dsg.Elements {
+		d.Elements[k] = v
+	}
+
+	if set == nil {
+		return &d
+	}
+
+	for _, pi := range set.Inits {
+		e, ok := d.Elements[pi.Phyo]
+		if !ok {
+			continue
+		}
+		p, ok := e.(addie.Phyo)
+		if !ok {
+			continue
+		}
+		p.Init = mergeInit(p.Init, pi.Values)
+		d.Elements[p.Id] = p
+	}
+
+	return &d
+
+}
+
+func mergeInit(init string, values map[string]float64) string {
+
+	var keys []string
+	vals := make(map[string]string)
+
+	for _, kv := range strings.Split(strings.Replace(init, " ", "", -1), ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if _, ok := vals[parts[0]]; !ok {
+			keys = append(keys, parts[0])
+		}
+		vals[parts[0]] = parts[1]
+	}
+
+	var added []string
+	for k, v := range values {
+		if _, ok := vals[k]; !ok {
+			added = append(added, k)
+		}
+		vals[k] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	sort.Strings(added)
+	keys = append(keys, added...)
+
+	var kvs []string
+	for _, k := range keys {
+		kvs = append(kvs, k+"="+vals[k])
+	}
+
+	return strings.Join(kvs, ",")
+
+}
+
+/*
+The InitArgs function returns the runtime arguments that start an already
+compiled simulation from the initial conditions in set
+*/
+func InitArgs(set *addie.InitSet) []string {
+
+	var args []string
+	for _, pi := range set.Inits {
+		var states []string
+		for k := range pi.Values {
+			states = append(states, k)
+		}
+		sort.Strings(states)
+		for _, k := range states {
+			args = append(args, "--init", pi.Phyo.Name+"."+k+"="+ftoa(pi.Values[k]))
+		}
+	}
+
+	return args
+
+}
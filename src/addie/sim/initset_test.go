@@ -0,0 +1,46 @@
+package sim
+
+import (
+	"addie"
+	"reflect"
+	"testing"
+)
+
+func TestApplyInitSet(t *testing.T) {
+
+	dsg := addie.EmptyDesign("chinook")
+	rtr := addie.Phyo{}
+	rtr.Id = addie.Id{Name: "rtr", Sys: "root", Design: "chinook"}
+	rtr.Model = "Rotor"
+	rtr.Init = "w=0, theta=0"
+	dsg.Elements[rtr.Id] = rtr
+
+	set := addie.InitSet{Name: "steady", Inits: []addie.PhyoInit{
+		{Phyo: rtr.Id, Values: map[string]float64{"w": 12.5, "i": 0.1}},
+	}}
+
+	d := ApplyInitSet(&dsg, &set)
+	if d.Elements[rtr.Id].(addie.Phyo).Init != "w=12.5,theta=0,i=0.1" {
+		t.Fatalf("bad initial conditions %s", d.Elements[rtr.Id].(addie.Phyo).Init)
+	}
+	if dsg.Elements[rtr.Id].(addie.Phyo).Init != "w=0, theta=0" {
+		t.Fatal("applying an init set modified the original design")
+	}
+
+	args := InitArgs(&set)
+	if !reflect.DeepEqual(args, []string{"--init", "rtr.i=1e-01", "--init", "rtr.w=1.25e+01"}) {
+		t.Fatalf("bad init args %v", args)
+	}
+
+}
+
+func TestModelStates(t *testing.T) {
+
+	m := addie.Model{Name: "Rotor", Params: "H",
+		Equations: "w' = tau - H*w^2\ntheta' = w\nw' = w'"}
+
+	if !reflect.DeepEqual(m.States(), []string{"w", "theta"}) {
+		t.Fatalf("bad states %v", m.States())
+	}
+
+}
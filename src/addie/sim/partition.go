@@ -0,0 +1,193 @@
+/*
+This file contains the code for splitting the physical half of a design across
+a cluster of kry simulation nodes
+*/
+package sim
+
+import (
+	"addie"
+	"sort"
+	"strconv"
+)
+
+/*
+A Partition maps each physical element of a design to the kry node that
+simulates it
+*/
+type Partition map[addie.Id]int
+
+/*
+A Coupling is a single plink binding whose two ends are simulated on different
+kry nodes. Both nodes exchange the coupled value over the krynet using Port.
+*/
+type Coupling struct {
+	Plink *addie.Plink
+	Index int
+	Nodes [2]int
+	Port  int
+}
+
+const couplingBasePort = 4700
+
+func isPhysical(e addie.Identify) bool {
+	switch e.(type) {
+	case addie.Phyo, addie.Sax, addie.Trace:
+		return true
+	}
+	return false
+}
+
+func sortedIds(xs map[addie.Id]bool) []addie.Id {
+	var ids []addie.Id
+	for x := range xs {
+		ids = append(ids, x)
+	}
+	sort.Sort(idSort(ids))
+	return ids
+}
+
+type idSort []addie.Id
+
+func (s idSort) Len() int           { return len(s) }
+func (s idSort) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s idSort) Less(i, j int) bool { return s[i].String() < s[j].String() }
+
+/*
+The PartitionDesign function assigns the physical elements of a design to n
+kry nodes. Elements that are coupled through plinks are kept together on one
+node when possible, larger coupled groups are split in breadth first order so
+the number of cross node couplings stays small.
+*/
+func PartitionDesign(dsg *addie.Design, n int) Partition {
+
+	if n < 1 {
+		n = 1
+	}
+
+	nodes := make(map[addie.Id]bool)
+	adj := make(map[addie.Id]map[addie.Id]bool)
+
+	for _, e := range dsg.Elements {
+		if isPhysical(e) {
+			nodes[e.Identify()] = true
+		}
+	}
+
+	for _, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Plink:
+			p := e.(addie.Plink)
+			a, b := p.Endpoints[0], p.Endpoints[1]
+			if !nodes[a] || !nodes[b] {
+				continue
+			}
+			if adj[a] == nil {
+				adj[a] = make(map[addie.Id]bool)
+			}
+			if adj[b] == nil {
+				adj[b] = make(map[addie.Id]bool)
+			}
+			adj[a][b] = true
+			adj[b][a] = true
+		}
+	}
+
+	//connected components, each one in breadth first order
+	var components [][]addie.Id
+	seen := make(map[addie.Id]bool)
+	for _, x := range sortedIds(nodes) {
+		if seen[x] {
+			continue
+		}
+		seen[x] = true
+		c := []addie.Id{x}
+		for i := 0; i < len(c); i++ {
+			for _, y := range sortedIds(adj[c[i]]) {
+				if !seen[y] {
+					seen[y] = true
+					c = append(c, y)
+				}
+			}
+		}
+		components = append(components, c)
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+
+	capacity := (len(nodes) + n - 1) / n
+	load := make([]int, n)
+	part := make(Partition)
+
+	leastLoaded := func() int {
+		k := 0
+		for i := range load {
+			if load[i] < load[k] {
+				k = i
+			}
+		}
+		return k
+	}
+
+	for _, c := range components {
+		for len(c) > 0 {
+			size := len(c)
+			if size > capacity {
+				size = capacity
+			}
+			k := leastLoaded()
+			for _, x := range c[:size] {
+				part[x] = k
+			}
+			load[k] += size
+			c = c[size:]
+		}
+	}
+
+	return part
+
+}
+
+/*
+The Couplings function returns every plink binding of a design that crosses
+kry nodes under the given partition
+*/
+func Couplings(dsg *addie.Design, part Partition) []Coupling {
+
+	var plinks []*addie.Plink
+	for _, e := range dsg.Elements {
+		switch e.(type) {
+		case addie.Plink:
+			p := e.(addie.Plink)
+			plinks = append(plinks, &p)
+		}
+	}
+	sort.Slice(plinks, func(i, j int) bool {
+		return plinks[i].Id.String() < plinks[j].Id.String()
+	})
+
+	var cs []Coupling
+	for _, p := range plinks {
+		a, b := part[p.Endpoints[0]], part[p.Endpoints[1]]
+		if a == b {
+			continue
+		}
+		aVars, _ := bindingVars(p)
+		for i := range aVars {
+			cs = append(cs, Coupling{
+				Plink: p,
+				Index: i,
+				Nodes: [2]int{a, b},
+				Port:  couplingBasePort + len(cs),
+			})
+		}
+	}
+
+	return cs
+
+}
+
+func (c *Coupling) Name() string {
+	return c.Plink.Name + "_C" + strconv.Itoa(c.Index)
+}
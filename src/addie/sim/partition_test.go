@@ -0,0 +1,93 @@
+package sim
+
+import (
+	"addie"
+	"strings"
+	"testing"
+)
+
+func chainDesign(n int) *addie.Design {
+
+	dsg := addie.EmptyDesign("bowmore")
+
+	var prev addie.Id
+	for i := 0; i < n; i++ {
+		p := addie.Phyo{}
+		p.Id = addie.Id{Name: "tank" + string('a'+rune(i)), Sys: "root", Design: "bowmore"}
+		p.Model = "Tank"
+		p.Args = "A=1"
+		dsg.Elements[p.Id] = p
+
+		if i > 0 {
+			l := addie.Plink{}
+			l.Id = addie.Id{Name: "pl" + string('a'+rune(i)), Sys: "root", Design: "bowmore"}
+			l.Endpoints = [2]addie.Id{prev, p.Id}
+			l.Bindings = [2]string{"qout", "qin"}
+			dsg.Elements[l.Id] = l
+		}
+		prev = p.Id
+	}
+
+	return &dsg
+
+}
+
+func TestPartitionBalance(t *testing.T) {
+
+	dsg := chainDesign(4)
+
+	part := PartitionDesign(dsg, 2)
+	if len(part) != 4 {
+		t.Fatalf("expected 4 partitioned elements, got %d", len(part))
+	}
+
+	load := make(map[int]int)
+	for _, k := range part {
+		load[k]++
+	}
+	if load[0] != 2 || load[1] != 2 {
+		t.Fatalf("unbalanced partition %v", load)
+	}
+
+	//a chain split in two has exactly one crossing binding
+	cs := Couplings(dsg, part)
+	if len(cs) != 1 {
+		t.Fatalf("expected 1 coupling, got %d", len(cs))
+	}
+
+	srcs := GenerateClusterSource(dsg, nil, 2)
+	if len(srcs) != 2 {
+		t.Fatalf("expected 2 sources, got %d", len(srcs))
+	}
+	for i, src := range srcs {
+		if !strings.Contains(src, "Simulation "+SimName("bowmore", i, 2)) {
+			t.Fatalf("source %d has the wrong simulation name\n%s", i, src)
+		}
+		if !strings.Contains(src, "Coupling "+cs[0].Name()) ||
+			!strings.Contains(src, cs[0].Name()+".v") {
+			t.Fatalf("source %d is missing the coupling\n%s", i, src)
+		}
+	}
+
+}
+
+func TestPartitionKeepsComponents(t *testing.T) {
+
+	dsg := chainDesign(2)
+	p := addie.Phyo{}
+	p.Id = addie.Id{Name: "lone", Sys: "root", Design: "bowmore"}
+	p.Model = "Tank"
+	dsg.Elements[p.Id] = p
+
+	part := PartitionDesign(dsg, 2)
+	cs := Couplings(dsg, part)
+	if len(cs) != 0 {
+		t.Fatalf("coupled elements were split across nodes %v", part)
+	}
+
+	single := GenerateClusterSource(dsg, nil, 1)
+	if len(single) != 1 || !strings.HasPrefix(single[0], "Simulation bowmore\n") {
+		t.Fatalf("bad single node source %v", single)
+	}
+
+}
@@ -0,0 +1,78 @@
+/*
+This file contains the code for building the runtime arguments of a compiled
+Cypress simulation from the simulation settings of a design
+*/
+package sim
+
+import (
+	"addie"
+	"strconv"
+	"strings"
+)
+
+func ftoa(x float64) string {
+	return strconv.FormatFloat(x, 'e', -1, 64)
+}
+
+/*
+The RuntimeArgs function returns the command line arguments for the rcomp
+simulation runtime. The leading begin, end and max step arguments are
+positional, everything else is passed as an option and only when it differs
+from the runtime default.
+*/
+func RuntimeArgs(s addie.SimSettings) []string {
+
+	args := []string{ftoa(s.Begin), ftoa(s.End), ftoa(s.MaxStep)}
+
+	if s.Solver != "" {
+		args = append(args, "--solver", s.Solver)
+	}
+	if s.AbsTol > 0 {
+		args = append(args, "--atol", ftoa(s.AbsTol))
+	}
+	if s.RelTol > 0 {
+		args = append(args, "--rtol", ftoa(s.RelTol))
+	}
+	if s.SampleInterval > 0 {
+		args = append(args, "--sample", ftoa(s.SampleInterval))
+	}
+
+	record := RecordVars(s)
+	if len(record) > 0 {
+		args = append(args, "--record", strings.Join(record, ","))
+	}
+
+	if s.CheckpointInterval > 0 {
+		args = append(args,
+			"--checkpoint-dir", CheckpointDir,
+			"--checkpoint-interval", ftoa(s.CheckpointInterval))
+	}
+
+	if s.RealTime {
+		ratio := s.RealTimeRatio
+		if ratio <= 0 {
+			ratio = 1
+		}
+		args = append(args, "--realtime", ftoa(ratio))
+	}
+
+	return args
+
+}
+
+/*
+The RecordVars function returns the element.variable names the user asked to
+record, an empty result means everything is recorded
+*/
+func RecordVars(s addie.SimSettings) []string {
+
+	var vs []string
+	for _, v := range strings.Split(strings.Replace(s.Record, " ", "", -1), ",") {
+		if v != "" {
+			vs = append(vs, v)
+		}
+	}
+
+	return vs
+
+}
@@ -0,0 +1,41 @@
+package sim
+
+import (
+	"addie"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeArgs(t *testing.T) {
+
+	base := addie.SimSettings{Begin: 0, End: 10, MaxStep: 1e-3}
+
+	for _, c := range []struct {
+		change func(*addie.SimSettings)
+		args   string
+	}{
+		{func(s *addie.SimSettings) {}, "0e+00 1e+01 1e-03"},
+		{func(s *addie.SimSettings) { s.Solver = "cvode" }, "0e+00 1e+01 1e-03 --solver cvode"},
+		{func(s *addie.SimSettings) { s.AbsTol, s.RelTol = 1e-8, 1e-6 },
+			"0e+00 1e+01 1e-03 --atol 1e-08 --rtol 1e-06"},
+		{func(s *addie.SimSettings) { s.SampleInterval = 0.5 },
+			"0e+00 1e+01 1e-03 --sample 5e-01"},
+		{func(s *addie.SimSettings) { s.Record = " rtr.w, sax0.tau," },
+			"0e+00 1e+01 1e-03 --record rtr.w,sax0.tau"},
+		{func(s *addie.SimSettings) { s.CheckpointInterval = 2 },
+			"0e+00 1e+01 1e-03 --checkpoint-dir " + CheckpointDir + " --checkpoint-interval 2e+00"},
+		{func(s *addie.SimSettings) { s.RealTime = true }, "0e+00 1e+01 1e-03 --realtime 1e+00"},
+		{func(s *addie.SimSettings) { s.RealTime, s.RealTimeRatio = true, 2 },
+			"0e+00 1e+01 1e-03 --realtime 2e+00"},
+		//a ratio without real time is not passed
+		{func(s *addie.SimSettings) { s.RealTimeRatio = 2 }, "0e+00 1e+01 1e-03"},
+	} {
+		s := base
+		c.change(&s)
+		args := strings.Join(RuntimeArgs(s), " ")
+		if args != c.args {
+			t.Fatalf("bad runtime arguments for %+v\n%s\nexpected\n%s", s, args, c.args)
+		}
+	}
+
+}
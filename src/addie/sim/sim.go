@@ -10,6 +10,8 @@ package sim
 import (
 	"addie"
 	"addie/db"
+	"addie/param"
+	"addie/sema"
 	"fmt"
 	"log"
 	"reflect"
@@ -45,21 +47,65 @@ given a pointer to an in memory design object.
 */
 func GenerateSource(dsg *addie.Design, models []addie.Model) string {
 
-	src := ""
+	return GenerateClusterSource(dsg, models, 1)[0]
+
+}
+
+/*
+The GenerateClusterSource function generates Cypress simulation source for a
+design that is simulated across n kry nodes. The physical elements are
+partitioned across the nodes and one source is generated for each node. The
+references to design parameters are resolved first.
+Plink bindings that cross nodes are lowered into Coupling objects that
+exchange the bound value between the two nodes.
+*/
+func GenerateClusterSource(dsg *addie.Design, models []addie.Model,
+	n int) []string {
+
+	if n < 1 {
+		n = 1
+	}
 
+	//parameter references that do not resolve were reported by the checks
+	dsg, errs := param.Resolve(dsg)
+	for _, err := range errs {
+		log.Println(err)
+	}
+
+	msrc := ""
 	for i, _ := range models {
-		src += modelSrc(&models[i])
+		msrc += modelSrc(&models[i])
 	}
 
-	src += designSrc(dsg)
+	part := PartitionDesign(dsg, n)
+	cs := Couplings(dsg, part)
 
-	return src
+	var srcs []string
+	for k := 0; k < n; k++ {
+		srcs = append(srcs, msrc+designSrc(dsg, part, cs, k, n))
+	}
+
+	return srcs
+
+}
+
+/*
+The SimName function returns the name of the simulation run by the given kry
+node. A single node simulation keeps the name of the design.
+*/
+func SimName(design string, node, n int) string {
+
+	if n <= 1 {
+		return design
+	}
+
+	return fmt.Sprintf("%s_kry%d", design, node)
 
 }
 
 func modelSrc(m *addie.Model) string {
 
-	src := "Object " + m.Name + "(" + strings.TrimSuffix(m.Params, ",") + ")\n"
+	src := "Object " + addie.ModelIdent(m.Name) + "(" + strings.TrimSuffix(m.Params, ",") + ")\n"
 
 	eqtns := strings.Split(m.Equations, "\n")
 	for _, e := range eqtns {
@@ -72,14 +118,18 @@ func modelSrc(m *addie.Model) string {
 
 }
 
-func designSrc(d *addie.Design) string {
+func designSrc(d *addie.Design, part Partition, cs []Coupling, node, n int) string {
 
-	src := "Simulation " + d.Name + "\n"
+	src := "Simulation " + SimName(d.Name, node, n) + "\n"
 
 	var plinks []*addie.Plink
 
 	for _, v := range d.Elements {
 
+		if isPhysical(v) && part[v.Identify()] != node {
+			continue
+		}
+
 		switch v.(type) {
 
 		case addie.Phyo:
@@ -90,27 +140,50 @@ func designSrc(d *addie.Design) string {
 			s := v.(addie.Sax)
 			src += saxSrc(&s)
 
+		case addie.Trace:
+			t := v.(addie.Trace)
+			src += traceSrc(&t)
+
 		case addie.Plink:
 			p := v.(addie.Plink)
-			plinks = append(plinks, &p)
+			if part[p.Endpoints[0]] == node || part[p.Endpoints[1]] == node {
+				plinks = append(plinks, &p)
+			}
 
 		}
 
 	}
 
+	for _, c := range cs {
+		if c.Nodes[0] == node || c.Nodes[1] == node {
+			src += couplingSrc(&c, node)
+		}
+	}
+
 	src += "\n"
 
 	for _, p := range plinks {
-		src += plinkSrc(p, d)
+		src += plinkSrc(p, d, part, node)
 	}
 
 	return src
 
 }
 
+func couplingSrc(c *Coupling, node int) string {
+
+	peer := c.Nodes[0]
+	if peer == node {
+		peer = c.Nodes[1]
+	}
+
+	return fmt.Sprintf("  Coupling %s(Peer:kry%d, Port:%d)\n", c.Name(), peer, c.Port)
+
+}
+
 func phyoSrc(p *addie.Phyo) string {
 
-	src := "  " + p.Model + " " + p.Name + "("
+	src := "  " + addie.ModelIdent(p.Model) + " " + p.Name + "("
 	src += strings.Replace(strings.TrimSuffix(p.Args, ","), "=", ":", -1)
 	_init := strings.Replace(p.Init, " ", "", -1)
 	if len(_init) > 0 {
@@ -123,6 +196,36 @@ func phyoSrc(p *addie.Phyo) string {
 
 }
 
+/*
+Traces are replayed by the simulation from the traces directory that is
+shipped inside the simulation package
+*/
+func traceSrc(t *addie.Trace) string {
+
+	interp := t.Interpolation
+	if interp == "" {
+		interp = "linear"
+	}
+
+	loop := "0"
+	if t.Loop {
+		loop = "1"
+	}
+
+	src := "  Trace " + t.Name + "(" +
+		"File:traces/" + t.File + ", " +
+		"Format:" + t.Format + ", " +
+		"Columns:" + strings.Replace(strings.Replace(t.Columns, " ", "", -1), ",", "|", -1) +
+		", " +
+		"Interpolation:" + interp + ", " +
+		"Loop:" + loop + ")"
+
+	src += "\n"
+
+	return src
+
+}
+
 func saxSrc(sax *addie.Sax) string {
 
 	src := ""
@@ -146,6 +249,10 @@ func sensorSrc(sax *addie.Sax, s string) string {
 
 	re, _ := regexp.Compile("([a-zA-Z_][a-zA-Z0-9_]*)\\(([0-9]*)\\)")
 	m := re.FindAllStringSubmatch(s, -1)
+	//sema reports malformed channels, a sax may have none
+	if len(m) == 0 {
+		return ""
+	}
 
 	src := "  Sensor " + sax.Name + "_S_" + m[0][1] + "(Rate:" + m[0][2] +
 		", Destination:localhost)"
@@ -164,7 +271,7 @@ func containsSensor(sax *addie.Sax, name string) bool {
 
 	for _, s := range sensors {
 		m := re.FindAllStringSubmatch(s, -1)
-		if m[0][1] == name {
+		if len(m) > 0 && m[0][1] == name {
 			return true
 		}
 	}
@@ -177,6 +284,9 @@ func actuatorSrc(sax *addie.Sax, a string) string {
 	re, _ := regexp.Compile(
 		"([a-zA-Z_][a-zA-Z0-9_]*)\\(([0-9]+(?:\\.[0-9]+)?),([0-9]+(?:\\.[0-9]+)?)\\)")
 	m := re.FindAllStringSubmatch(strings.Replace(a, " ", "", -1), -1)
+	if len(m) == 0 {
+		return ""
+	}
 
 	src := "  Actuator " + sax.Name + "_A_" + m[0][1] + "(" +
 		"Min:-" + m[0][2] + ", " +
@@ -199,7 +309,7 @@ func containsActuator(sax *addie.Sax, name string) bool {
 
 	for _, a := range actuators {
 		m := re.FindAllStringSubmatch(strings.Replace(a, " ", "", -1), -1)
-		if m[0][1] == name {
+		if len(m) > 0 && m[0][1] == name {
 			return true
 		}
 	}
@@ -207,37 +317,47 @@ func containsActuator(sax *addie.Sax, name string) bool {
 	return false
 }
 
-func plinkSrc(plink *addie.Plink, d *addie.Design) string {
+func bindingVars(plink *addie.Plink) ([]string, []string) {
+
+	return sema.BindingList(plink.Bindings[0]), sema.BindingList(plink.Bindings[1])
+
+}
+
+func plinkSrc(plink *addie.Plink, d *addie.Design, part Partition,
+	node int) string {
 
 	src := ""
 
-	aVars := strings.Split(strings.Replace(plink.Bindings[0], " ", "", -1), ",")
-	bVars := strings.Split(strings.Replace(plink.Bindings[1], " ", "", -1), ",")
+	aVars, bVars := bindingVars(plink)
 
 	ae := d.Elements[plink.Endpoints[0]]
-	aName := ae.Identify().Name
-
 	be := d.Elements[plink.Endpoints[1]]
-	bName := be.Identify().Name
+
+	aLocal := part[plink.Endpoints[0]] == node
+	bLocal := part[plink.Endpoints[1]] == node
 
 	for i, a := range aVars {
 
+		//sema reports plinks with uneven bindings, never index past them here
+		if i >= len(bVars) {
+			break
+		}
 		b := bVars[i]
 
-		if reflect.TypeOf(ae).Name() == "Sax" {
-			s := ae.(addie.Sax)
-			src += "  " + saxName(&s, a)
+		c := Coupling{Plink: plink, Index: i}
+
+		if aLocal {
+			src += "  " + endpointVar(ae, a)
 		} else {
-			src += "  " + aName + "." + a
+			src += "  " + c.Name() + ".v"
 		}
 
 		src += " ~ "
 
-		if reflect.TypeOf(be).Name() == "Sax" {
-			s := be.(addie.Sax)
-			src += saxName(&s, b)
+		if bLocal {
+			src += endpointVar(be, b)
 		} else {
-			src += bName + "." + b
+			src += c.Name() + ".v"
 		}
 
 		src += "\n"
@@ -247,6 +367,17 @@ func plinkSrc(plink *addie.Plink, d *addie.Design) string {
 
 }
 
+func endpointVar(e addie.Identify, v string) string {
+
+	if reflect.TypeOf(e).Name() == "Sax" {
+		s := e.(addie.Sax)
+		return saxName(&s, v)
+	}
+
+	return e.Identify().Name + "." + v
+
+}
+
 func saxName(sax *addie.Sax, name string) string {
 
 	saxT := "?"
@@ -1,8 +1,10 @@
 package sim
 
 import (
+	"addie"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +50,128 @@ func TestGenerateSourceFromDB(t *testing.T) {
 	}
 
 }
+
+func TestPublishedModelSource(t *testing.T) {
+
+	dsg := addie.EmptyDesign("talisker")
+
+	p := addie.Phyo{}
+	p.Id = addie.Id{Name: "rtr", Sys: "root", Design: "talisker"}
+	p.Model = "grid/Rotor@1.2"
+	p.Args = "H=2"
+	dsg.Elements[p.Id] = p
+
+	m := addie.Model{Name: "grid/Rotor@1.2", Params: "H", Equations: "w' = -H*w"}
+
+	src := GenerateSource(&dsg, []addie.Model{m})
+	if !strings.Contains(src, "Object grid_Rotor_1_2(H)\n") ||
+		!strings.Contains(src, "  grid_Rotor_1_2 rtr(H:2)\n") {
+		t.Fatalf("bad published model source\n%s", src)
+	}
+
+	ref, ok := addie.ParseModelRef(p.Model)
+	if !ok || ref.Scope != "grid" || ref.Name != "Rotor" || !ref.Pinned() ||
+		ref.String() != p.Model {
+		t.Fatalf("bad model reference %+v", ref)
+	}
+	if _, ok := addie.ParseModelRef("Rotor"); ok {
+		t.Fatal("a plain model name parsed as a reference")
+	}
+
+}
+
+func TestParamSource(t *testing.T) {
+
+	dsg := addie.EmptyDesign("talisker")
+	dsg.Params["motor_H"] = 2.5
+
+	p := addie.Phyo{}
+	p.Id = addie.Id{Name: "rtr", Sys: "root", Design: "talisker"}
+	p.Model = "Rotor"
+	p.Args = "H=2*$motor_H"
+	dsg.Elements[p.Id] = p
+
+	m := addie.Model{Name: "Rotor", Params: "H", Equations: "w' = -H*w"}
+
+	src := GenerateSource(&dsg, []addie.Model{m})
+	if !strings.Contains(src, "  Rotor rtr(H:5)\n") {
+		t.Fatalf("the parameter reference was not resolved\n%s", src)
+	}
+
+}
+
+func TestParamClusterSource(t *testing.T) {
+
+	dsg := addie.EmptyDesign("talisker")
+	dsg.Params["motor_H"] = 2.5
+
+	p := addie.Phyo{}
+	p.Id = addie.Id{Name: "rtr", Sys: "root", Design: "talisker"}
+	p.Model = "Rotor"
+	p.Args = "H=$motor_H"
+	p.Init = "w=0"
+	dsg.Elements[p.Id] = p
+
+	m := addie.Model{Name: "Rotor", Params: "H", Equations: "w' = -H*w"}
+	set := addie.InitSet{Name: "spun", Inits: []addie.PhyoInit{
+		{Phyo: p.Id, Values: map[string]float64{"w": 3}},
+	}}
+
+	//compiling goes through ApplyInitSet with and without a set
+	for _, s := range []*addie.InitSet{nil, &set} {
+		srcs := GenerateClusterSource(ApplyInitSet(&dsg, s), []addie.Model{m}, 1)
+		if len(srcs) != 1 || !strings.Contains(srcs[0], "  Rotor rtr(H:2.5") ||
+			strings.Contains(srcs[0], "$") {
+			t.Fatalf("the parameter reference was not resolved\n%v", srcs)
+		}
+	}
+
+}
+
+func TestBindingSource(t *testing.T) {
+
+	dsg := addie.EmptyDesign("talisker")
+	id := func(n string) addie.Id { return addie.Id{Name: n, Sys: "root", Design: "talisker"} }
+
+	p := addie.Phyo{}
+	p.Id = id("tank")
+	p.Model = "Tank"
+	p.Args = "A=2"
+	dsg.Elements[p.Id] = p
+
+	//a sax that only actuates and a sax that only senses
+	valve := addie.Sax{}
+	valve.Id = id("valve")
+	valve.Actuate = "qin(0,1)"
+	dsg.Elements[valve.Id] = valve
+
+	gauge := addie.Sax{}
+	gauge.Id = id("gauge")
+	gauge.Sense = "h(10)"
+	dsg.Elements[gauge.Id] = gauge
+
+	//a trailing comma and bindings of uneven length
+	in := addie.Plink{}
+	in.Id = id("in")
+	in.Endpoints = [2]addie.Id{valve.Id, p.Id}
+	in.Bindings = [2]string{"qin, ", "qin,"}
+	dsg.Elements[in.Id] = in
+
+	out := addie.Plink{}
+	out.Id = id("out")
+	out.Endpoints = [2]addie.Id{p.Id, gauge.Id}
+	out.Bindings = [2]string{"h,qin", "h"}
+	dsg.Elements[out.Id] = out
+
+	m := addie.Model{Name: "Tank", Params: "A", Equations: "h' = qin/A"}
+
+	src := GenerateSource(&dsg, []addie.Model{m})
+	if !strings.Contains(src, "  valve_A_qin.u ~ tank.qin\n") ||
+		!strings.Contains(src, "  tank.h ~ gauge_S_h.y\n") {
+		t.Fatalf("bad bindings\n%s", src)
+	}
+	if strings.Contains(src, ". ~") || strings.Contains(src, "_S_\n") {
+		t.Fatalf("an empty binding or channel was generated\n%s", src)
+	}
+
+}
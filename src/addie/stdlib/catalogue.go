@@ -0,0 +1,171 @@
+/*
+This file contains the models of the standard library
+*/
+package stdlib
+
+import (
+	"addie"
+)
+
+func icon(body string) string {
+	return `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 64 64" ` +
+		`fill="none" stroke="#333" stroke-width="2">` + body + `</svg>`
+}
+
+var entries = []Entry{
+
+	{
+		Model: addie.Model{
+			Name:   "Rotor",
+			Params: "H,D",
+			Equations: "w' = (tau - D*w)/H\n" +
+				"theta' = w",
+		},
+		Version: "1.0.0",
+		Doc: "A rigid rotating mass with viscous damping, driven by the torque " +
+			"tau",
+		ParamDocs: []VarDoc{
+			{"H", "kg*m^2", "moment of inertia"},
+			{"D", "N*m*s/rad", "viscous damping coefficient"},
+		},
+		VarDocs: []VarDoc{
+			{"w", "rad/s", "angular velocity"},
+			{"theta", "rad", "angle"},
+			{"tau", "N*m", "applied torque"},
+		},
+		IconSvg: icon(`<circle cx="32" cy="32" r="24"/><circle cx="32" cy="32" r="4"/>` +
+			`<path d="M32 8 A24 24 0 0 1 56 32"/><path d="M50 26 L56 32 L60 24"/>`),
+	},
+
+	{
+		Model: addie.Model{
+			Name:   "Tank",
+			Params: "A,k",
+			Equations: "h' = (qin - qout)/A\n" +
+				"qout = k*sqrt(h)",
+		},
+		Version: "1.0.0",
+		Doc: "An open tank draining through an orifice at its base, filled by " +
+			"the inflow qin",
+		ParamDocs: []VarDoc{
+			{"A", "m^2", "cross sectional area"},
+			{"k", "m^2.5/s", "orifice discharge coefficient"},
+		},
+		VarDocs: []VarDoc{
+			{"h", "m", "fluid level"},
+			{"qin", "m^3/s", "inflow"},
+			{"qout", "m^3/s", "outflow through the orifice"},
+		},
+		IconSvg: icon(`<path d="M12 8 V56 H52 V8"/><path d="M12 30 H52" stroke-dasharray="4 2"/>` +
+			`<path d="M52 50 H60"/>`),
+	},
+
+	{
+		Model: addie.Model{
+			Name:   "DCMotor",
+			Params: "R,L,Ke,Kt,J,b",
+			Equations: "i' = (v - R*i - Ke*w)/L\n" +
+				"w' = (Kt*i - b*w - tl)/J\n" +
+				"theta' = w",
+		},
+		Version: "1.0.0",
+		Doc: "A permanent magnet DC motor with armature inductance, driven by " +
+			"the terminal voltage v against the load torque tl",
+		ParamDocs: []VarDoc{
+			{"R", "ohm", "armature resistance"},
+			{"L", "H", "armature inductance"},
+			{"Ke", "V*s/rad", "back emf constant"},
+			{"Kt", "N*m/A", "torque constant"},
+			{"J", "kg*m^2", "rotor moment of inertia"},
+			{"b", "N*m*s/rad", "viscous friction coefficient"},
+		},
+		VarDocs: []VarDoc{
+			{"i", "A", "armature current"},
+			{"w", "rad/s", "shaft angular velocity"},
+			{"theta", "rad", "shaft angle"},
+			{"v", "V", "terminal voltage"},
+			{"tl", "N*m", "load torque"},
+		},
+		IconSvg: icon(`<circle cx="32" cy="32" r="18"/><path d="M2 32 H14 M50 32 H62"/>` +
+			`<path d="M26 26 V38 M38 26 L32 32 L38 38"/>`),
+	},
+
+	{
+		Model: addie.Model{
+			Name:      "ThermalMass",
+			Params:    "C,G",
+			Equations: "T' = (q - G*(T - Ta))/C",
+		},
+		Version: "1.0.0",
+		Doc: "A lumped thermal capacity heated by q that loses heat to its " +
+			"surroundings at temperature Ta through a thermal conductance",
+		ParamDocs: []VarDoc{
+			{"C", "J/K", "heat capacity"},
+			{"G", "W/K", "thermal conductance to the surroundings"},
+		},
+		VarDocs: []VarDoc{
+			{"T", "K", "temperature"},
+			{"q", "W", "heat flow into the mass"},
+			{"Ta", "K", "ambient temperature"},
+		},
+		IconSvg: icon(`<rect x="14" y="14" width="36" height="36"/>` +
+			`<path d="M24 8 Q28 4 24 0 M32 8 Q36 4 32 0 M40 8 Q44 4 40 0"/>`),
+	},
+
+	{
+		Model: addie.Model{
+			Name:   "InvertedPendulum",
+			Params: "M,m,l,g,b",
+			Equations: "x' = v\n" +
+				"v' = (F - b*v + m*l*om^2*sin(th) - m*g*sin(th)*cos(th))/(M + m*sin(th)^2)\n" +
+				"th' = om\n" +
+				"om' = ((M + m)*g*sin(th) - cos(th)*(F - b*v + m*l*om^2*sin(th)))/" +
+				"(l*(M + m*sin(th)^2))",
+		},
+		Version: "1.0.0",
+		Doc: "A pendulum balanced on a cart that is pushed by the force F, the " +
+			"pendulum angle th is measured from upright",
+		ParamDocs: []VarDoc{
+			{"M", "kg", "cart mass"},
+			{"m", "kg", "pendulum mass"},
+			{"l", "m", "pendulum length"},
+			{"g", "m/s^2", "gravitational acceleration"},
+			{"b", "N*s/m", "cart friction coefficient"},
+		},
+		VarDocs: []VarDoc{
+			{"x", "m", "cart position"},
+			{"v", "m/s", "cart velocity"},
+			{"th", "rad", "pendulum angle from upright"},
+			{"om", "rad/s", "pendulum angular velocity"},
+			{"F", "N", "force on the cart"},
+		},
+		IconSvg: icon(`<rect x="16" y="42" width="32" height="12"/>` +
+			`<circle cx="22" cy="58" r="3"/><circle cx="42" cy="58" r="3"/>` +
+			`<path d="M32 42 L40 10"/><circle cx="40" cy="10" r="4"/>`),
+	},
+
+	{
+		Model: addie.Model{
+			Name:   "GridBus",
+			Params: "H,D,ws",
+			Equations: "delta' = ws*dw\n" +
+				"dw' = (Pm - Pe - D*dw)/(2*H)",
+		},
+		Version: "1.0.0",
+		Doc: "A power grid bus with a synchronous machine following the swing " +
+			"equation, powers are per unit on the machine base",
+		ParamDocs: []VarDoc{
+			{"H", "s", "inertia constant"},
+			{"D", "pu", "damping coefficient"},
+			{"ws", "rad/s", "synchronous angular speed"},
+		},
+		VarDocs: []VarDoc{
+			{"delta", "rad", "rotor angle relative to the synchronous frame"},
+			{"dw", "pu", "speed deviation"},
+			{"Pm", "pu", "mechanical input power"},
+			{"Pe", "pu", "electrical power drawn from the bus"},
+		},
+		IconSvg: icon(`<path d="M8 20 H56 M8 44 H56" stroke-width="4"/>` +
+			`<path d="M20 20 V44 M44 20 V44"/><circle cx="32" cy="32" r="6"/>`),
+	},
+}
@@ -0,0 +1,141 @@
+/*
+The stdlib package holds the standard library of physical models that ships
+with addie. The library is readable by every user and its models can be
+copied into a user's own model library.
+*/
+package stdlib
+
+import (
+	"addie"
+	"sort"
+)
+
+/*
+The version of the standard library as a whole, it changes whenever an entry
+is added or a new version of an entry is released
+*/
+const Version = "1.0.0"
+
+/*
+A VarDoc documents a parameter or variable of a library model
+*/
+type VarDoc struct {
+	Name string `json:"name"`
+	Unit string `json:"unit"`
+	Doc  string `json:"doc"`
+}
+
+/*
+An Entry is one version of a library model. Entries are never changed once
+released, a change to a model is released as a new entry with a higher
+version so copies and designs built on the old version stay reproducible.
+*/
+type Entry struct {
+	addie.Model
+	Version   string   `json:"version"`
+	Doc       string   `json:"doc"`
+	ParamDocs []VarDoc `json:"paramDocs"`
+	VarDocs   []VarDoc `json:"varDocs"`
+	//the icon as an svg document
+	IconSvg string `json:"iconSvg"`
+}
+
+/*
+The Catalogue function returns the latest version of every library model
+ordered by name
+*/
+func Catalogue() []Entry {
+
+	latest := make(map[string]Entry)
+	for _, e := range entries {
+		l, ok := latest[e.Name]
+		if !ok || versionLess(l.Version, e.Version) {
+			latest[e.Name] = e
+		}
+	}
+
+	var result []Entry
+	for _, e := range latest {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+
+}
+
+/*
+The Versions function returns every released version of a library model,
+oldest first
+*/
+func Versions(name string) []Entry {
+
+	var result []Entry
+	for _, e := range entries {
+		if e.Name == name {
+			result = append(result, e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return versionLess(result[i].Version, result[j].Version)
+	})
+
+	return result
+
+}
+
+/*
+The Lookup function finds a library model, an empty version selects the
+latest one
+*/
+func Lookup(name, version string) (Entry, bool) {
+
+	vs := Versions(name)
+	if len(vs) == 0 {
+		return Entry{}, false
+	}
+	if version == "" {
+		return vs[len(vs)-1], true
+	}
+
+	for _, e := range vs {
+		if e.Version == version {
+			return e, true
+		}
+	}
+
+	return Entry{}, false
+
+}
+
+/*
+The versionLess function orders dotted version numbers numerically
+*/
+func versionLess(a, b string) bool {
+
+	as, bs := versionParts(a), versionParts(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
+	}
+
+	return len(as) < len(bs)
+
+}
+
+func versionParts(v string) []int {
+
+	parts := []int{0}
+	for _, c := range v {
+		switch {
+		case c == '.':
+			parts = append(parts, 0)
+		case c >= '0' && c <= '9':
+			parts[len(parts)-1] = parts[len(parts)-1]*10 + int(c-'0')
+		}
+	}
+
+	return parts
+
+}
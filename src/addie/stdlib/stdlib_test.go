@@ -0,0 +1,64 @@
+package stdlib
+
+import (
+	"addie/modelica"
+	"addie/sema"
+	"strings"
+	"testing"
+)
+
+func TestCatalogueDocumented(t *testing.T) {
+
+	for _, e := range Catalogue() {
+
+		ps := sema.ModelParams(e.Model)
+		if len(ps) != len(e.ParamDocs) {
+			t.Fatalf("%s documents %d of %d parameters", e.Name, len(e.ParamDocs),
+				len(ps))
+		}
+		for i, p := range ps {
+			if e.ParamDocs[i].Name != p {
+				t.Fatalf("%s parameter %s is documented as %s", e.Name, p,
+					e.ParamDocs[i].Name)
+			}
+		}
+
+		v := modelica.ModelVars(&e.Model)
+		documented := make(map[string]bool)
+		for _, d := range e.VarDocs {
+			documented[d.Name] = true
+		}
+		for _, xs := range [][]string{v.States, v.Algebraics, v.Inputs} {
+			for _, x := range xs {
+				if !documented[x] {
+					t.Fatalf("%s variable %s is not documented", e.Name, x)
+				}
+			}
+		}
+
+		if e.Doc == "" || !strings.HasPrefix(e.IconSvg, "<svg") {
+			t.Fatalf("%s is missing its documentation or icon", e.Name)
+		}
+	}
+
+}
+
+func TestLookup(t *testing.T) {
+
+	if len(Catalogue()) != 6 {
+		t.Fatalf("expected 6 library models, got %d", len(Catalogue()))
+	}
+
+	e, ok := Lookup("Tank", "")
+	if !ok || e.Version != "1.0.0" {
+		t.Fatalf("bad latest tank %v", e)
+	}
+	if _, ok := Lookup("Tank", "0.9"); ok {
+		t.Fatal("found a version that was never released")
+	}
+
+	if !versionLess("1.2.0", "1.10.0") || versionLess("2.0", "1.9.9") {
+		t.Fatal("versions are not ordered numerically")
+	}
+
+}
@@ -0,0 +1,372 @@
+/*
+This file contains the hierarchy of systems a design is organised in. Every
+element belongs to the system named by the Sys of its id, and systems nest
+under a parent system up to the root system of the design.
+*/
+package addie
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+The system every design starts with, it can not be renamed, nested or deleted
+*/
+const RootSystem = "root"
+
+/*
+A System groups elements of a design. Collapsed systems are sent to clients
+without the elements inside them, Hidden counts the elements left out.
+*/
+type System struct {
+	Name      string `json:"name"`
+	Parent    string `json:"parent"`
+	Collapsed bool   `json:"collapsed"`
+	Hidden    int    `json:"hidden"`
+}
+
+/*
+WithId returns a copy of an element with the id id
+*/
+func WithId(e Identify, id Id) (Identify, error) {
+
+	switch e.(type) {
+	case Computer:
+		x := e.(Computer)
+		x.Id = id
+		return x, nil
+	case Switch:
+		x := e.(Switch)
+		x.Id = id
+		return x, nil
+	case Router:
+		x := e.(Router)
+		x.Id = id
+		return x, nil
+	case Link:
+		x := e.(Link)
+		x.Id = id
+		return x, nil
+	case Phyo:
+		x := e.(Phyo)
+		x.Id = id
+		return x, nil
+	case Plink:
+		x := e.(Plink)
+		x.Id = id
+		return x, nil
+	case Trace:
+		x := e.(Trace)
+		x.Id = id
+		return x, nil
+	case Sensor:
+		x := e.(Sensor)
+		x.Id = id
+		return x, nil
+	case Actuator:
+		x := e.(Actuator)
+		x.Id = id
+		return x, nil
+	case Sax:
+		x := e.(Sax)
+		x.Id = id
+		return x, nil
+	}
+
+	return nil, fmt.Errorf("elements of type %T do not have an id", e)
+
+}
+
+/*
+Relabel returns a copy of an element whose references to other elements, the
+endpoints of links and plinks and the targets of sensors and actuators, are
+mapped through ids. References that are not in ids are kept.
+*/
+func Relabel(e Identify, ids map[Id]Id) Identify {
+
+	var relabel = func(id Id) Id {
+		if nid, ok := ids[id]; ok {
+			return nid
+		}
+		return id
+	}
+
+	switch e.(type) {
+	case Link:
+		l := e.(Link)
+		for i := range l.Endpoints {
+			l.Endpoints[i].Id = relabel(l.Endpoints[i].Id)
+		}
+		return l
+	case Plink:
+		p := e.(Plink)
+		for i := range p.Endpoints {
+			p.Endpoints[i] = relabel(p.Endpoints[i])
+		}
+		return p
+	case Sensor:
+		s := e.(Sensor)
+		s.Target.Id = relabel(s.Target.Id)
+		return s
+	case Actuator:
+		a := e.(Actuator)
+		a.Target.Id = relabel(a.Target.Id)
+		return a
+	}
+
+	return e
+
+}
+
+/*
+rewire points every reference to the element old at the element id
+*/
+func (d *Design) rewire(old, id Id) {
+
+	ids := map[Id]Id{old: id}
+	for k, e := range d.Elements {
+		d.Elements[k] = Relabel(e, ids)
+	}
+
+}
+
+/*
+MoveElement moves an element into the system sys and rewires the links,
+plinks and targets that reference it. The new id of the element is returned.
+*/
+func (d *Design) MoveElement(id Id, sys string) (Id, error) {
+
+	e, ok := d.Elements[id]
+	if !ok {
+		return id, fmt.Errorf("the element %s does not exist", id)
+	}
+	if id.Sys == sys {
+		return id, nil
+	}
+
+	nid := Id{Name: id.Name, Sys: sys, Design: id.Design}
+	if _, ok := d.Elements[nid]; ok {
+		return id, fmt.Errorf("the system %s already has an element named %s",
+			sys, id.Name)
+	}
+
+	moved, err := WithId(e, nid)
+	if err != nil {
+		return id, err
+	}
+
+	delete(d.Elements, id)
+	d.Elements[nid] = moved
+	d.rewire(id, nid)
+
+	return nid, nil
+
+}
+
+/*
+MoveElements moves the elements ids into the system sys like MoveElement. The
+moves are checked first, so either every element moves or none does. The new
+id of every element that changed systems is returned by its old one.
+*/
+func (d *Design) MoveElements(ids []Id, sys string) (map[Id]Id, error) {
+
+	taken := make(map[Id]bool)
+	for _, id := range ids {
+		e, ok := d.Elements[id]
+		if !ok {
+			return nil, fmt.Errorf("the element %s does not exist", id)
+		}
+		if id.Sys == sys {
+			continue
+		}
+		nid := Id{Name: id.Name, Sys: sys, Design: id.Design}
+		if _, ok := d.Elements[nid]; ok || taken[nid] {
+			return nil, fmt.Errorf("the system %s already has an element named %s",
+				sys, id.Name)
+		}
+		taken[nid] = true
+		if _, err := WithId(e, nid); err != nil {
+			return nil, err
+		}
+	}
+
+	moved := make(map[Id]Id)
+	for _, id := range ids {
+		nid, err := d.MoveElement(id, sys)
+		if err != nil {
+			return moved, err
+		}
+		if nid != id {
+			moved[id] = nid
+		}
+	}
+
+	return moved, nil
+
+}
+
+/*
+SystemElements returns the ids of the elements that belong directly to the
+system sys
+*/
+func (d *Design) SystemElements(sys string) []Id {
+
+	var result []Id
+	for id := range d.Elements {
+		if id.Sys == sys {
+			result = append(result, id)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	return result
+
+}
+
+/*
+SystemTree indexes systems by name
+*/
+type SystemTree map[string]System
+
+func NewSystemTree(systems []System) SystemTree {
+
+	t := make(SystemTree)
+	for _, s := range systems {
+		t[s.Name] = s
+	}
+
+	return t
+
+}
+
+/*
+Children returns the names of the systems directly nested under sys
+*/
+func (t SystemTree) Children(sys string) []string {
+
+	var result []string
+	for _, s := range t {
+		if s.Parent == sys && s.Name != sys {
+			result = append(result, s.Name)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+
+}
+
+/*
+Descendants returns sys and every system nested under it at any depth
+*/
+func (t SystemTree) Descendants(sys string) []string {
+
+	result := []string{sys}
+	for i := 0; i < len(result); i++ {
+		result = append(result, t.Children(result[i])...)
+	}
+
+	return result
+
+}
+
+/*
+CheckNest checks that sys may be nested under parent, which must exist and
+must not be sys or one of its descendants
+*/
+func (t SystemTree) CheckNest(sys, parent string) error {
+
+	if sys == RootSystem {
+		return fmt.Errorf("the root system can not be nested")
+	}
+	if _, ok := t[parent]; !ok {
+		return fmt.Errorf("the system %s does not exist", parent)
+	}
+	for _, s := range t.Descendants(sys) {
+		if s == parent {
+			return fmt.Errorf("nesting %s under %s would create a cycle", sys, parent)
+		}
+	}
+
+	return nil
+
+}
+
+/*
+Collapse returns the elements of a design a client sees when the systems in
+collapse are collapsed along with the systems annotated with what they hide.
+Elements inside a collapsed system or any of its descendants are left out,
+links and plinks are only left out when both their endpoints are, so the links
+that cross into a collapsed system stay visible.
+*/
+func (d *Design) Collapse(t SystemTree, collapse []string) ([]Identify, []System) {
+
+	collapsed := make(map[string]bool)
+	for _, c := range collapse {
+		collapsed[c] = true
+	}
+
+	//a system is hidden by the outermost collapsed system it is nested in
+	hidden := make(map[string]string)
+	for name := range t {
+		for s, depth := name, 0; depth <= len(t); depth++ {
+			if collapsed[s] {
+				hidden[name] = s
+			}
+			p, ok := t[s]
+			if !ok || p.Parent == "" || p.Parent == s {
+				break
+			}
+			s = p.Parent
+		}
+	}
+
+	counts := make(map[string]int)
+	var hide = func(ids ...Id) bool {
+		for _, id := range ids {
+			if _, ok := hidden[id.Sys]; !ok {
+				return false
+			}
+		}
+		counts[hidden[ids[0].Sys]]++
+		return true
+	}
+
+	var elements []Identify
+	for id, e := range d.Elements {
+		switch e.(type) {
+		case Link:
+			l := e.(Link)
+			if hide(l.Endpoints[0].Id, l.Endpoints[1].Id) {
+				continue
+			}
+		case Plink:
+			p := e.(Plink)
+			if hide(p.Endpoints[0], p.Endpoints[1]) {
+				continue
+			}
+		default:
+			if hide(id) {
+				continue
+			}
+		}
+		elements = append(elements, e)
+	}
+
+	var systems []System
+	for _, s := range t {
+		if c, ok := hidden[s.Name]; ok {
+			if c != s.Name {
+				continue
+			}
+			s.Collapsed = true
+			s.Hidden = counts[c]
+		}
+		systems = append(systems, s)
+	}
+	sort.Slice(systems, func(i, j int) bool { return systems[i].Name < systems[j].Name })
+
+	return elements, systems
+
+}
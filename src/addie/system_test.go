@@ -0,0 +1,139 @@
+package addie
+
+import (
+	"testing"
+)
+
+/*
+The elements of the nested design, spread over root, boiler and feed which
+nests in boiler. The link and plink cross systems so moves have to rewire them.
+*/
+var (
+	ctl   = Id{Name: "ctl", Sys: "root", Design: "ardbeg"}
+	l0    = Id{Name: "l0", Sys: "root", Design: "ardbeg"}
+	sw    = Id{Name: "sw", Sys: "boiler", Design: "ardbeg"}
+	level = Id{Name: "level", Sys: "boiler", Design: "ardbeg"}
+	pl0   = Id{Name: "pl0", Sys: "boiler", Design: "ardbeg"}
+	drum  = Id{Name: "drum", Sys: "feed", Design: "ardbeg"}
+)
+
+func nested() Design {
+
+	dsg := EmptyDesign("ardbeg")
+
+	var c Computer
+	c.Id = ctl
+	var s Switch
+	s.Id = sw
+	var x Sax
+	x.Id = level
+	var p Phyo
+	p.Id = drum
+
+	var l Link
+	l.Id = l0
+	l.Endpoints = [2]NetIfRef{{ctl, "eth0"}, {sw, "eth0"}}
+	var pl Plink
+	pl.Id = pl0
+	pl.Endpoints = [2]Id{level, drum}
+
+	for _, e := range []Identify{c, s, x, p, l, pl} {
+		dsg.Elements[e.Identify()] = e
+	}
+
+	return dsg
+
+}
+
+func TestMoveElement(t *testing.T) {
+
+	dsg := nested()
+
+	nid, err := dsg.MoveElement(sw, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dsg.Elements[sw]; ok {
+		t.Fatal("the moved element is still in its old system")
+	}
+	if dsg.Elements[nid].Identify().Sys != "root" {
+		t.Fatalf("the element was not moved %v", dsg.Elements[nid])
+	}
+	l := dsg.Elements[l0].(Link)
+	if l.Endpoints[1].Id != nid || l.Endpoints[1].IfName != "eth0" {
+		t.Fatalf("the link was not rewired %v", l.Endpoints)
+	}
+
+	//there already is a ctl in root
+	c := dsg.Elements[ctl].(Computer)
+	c.Id.Sys = "boiler"
+	dsg.Elements[c.Id] = c
+	if _, err := dsg.MoveElement(c.Id, "root"); err == nil {
+		t.Fatal("a colliding move was allowed")
+	}
+
+}
+
+func TestSystemTree(t *testing.T) {
+
+	tree := NewSystemTree([]System{
+		{Name: "root"},
+		{Name: "boiler", Parent: "root"},
+		{Name: "feed", Parent: "boiler"},
+	})
+
+	if err := tree.CheckNest("boiler", "feed"); err == nil {
+		t.Fatal("a nesting cycle was allowed")
+	}
+	if err := tree.CheckNest("root", "boiler"); err == nil {
+		t.Fatal("the root system was nested")
+	}
+	if err := tree.CheckNest("feed", "root"); err != nil {
+		t.Fatal(err)
+	}
+
+	dsg := nested()
+	elements, systems := dsg.Collapse(tree, []string{"feed", "boiler"})
+
+	//ctl and l0 are visible, everything else is inside boiler
+	if len(elements) != 2 {
+		t.Fatalf("expected 2 visible elements, got %v", elements)
+	}
+	if len(systems) != 2 || !systems[0].Collapsed || systems[0].Name != "boiler" ||
+		systems[0].Hidden != 4 || systems[1].Collapsed {
+		t.Fatalf("bad collapsed systems %+v", systems)
+	}
+
+	elements, _ = dsg.Collapse(tree, nil)
+	if len(elements) != len(dsg.Elements) {
+		t.Fatal("elements were hidden without collapsing anything")
+	}
+
+}
+
+func TestMoveElements(t *testing.T) {
+
+	dsg := nested()
+
+	//a missing element fails the whole move
+	missing := Id{Name: "valve", Sys: "boiler", Design: "ardbeg"}
+	if _, err := dsg.MoveElements([]Id{sw, missing}, "feed"); err == nil {
+		t.Fatal("a move of a missing element was allowed")
+	}
+	if _, ok := dsg.Elements[sw]; !ok {
+		t.Fatal("an element moved though the move was refused")
+	}
+
+	moved, err := dsg.MoveElements([]Id{sw, level, ctl}, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 2 || moved[sw].Sys != "root" || moved[level].Sys != "root" {
+		t.Fatalf("bad moves %v", moved)
+	}
+	pl := dsg.Elements[pl0].(Plink)
+	if pl.Endpoints[0] != moved[level] {
+		t.Fatalf("the plink was not rewired %v", pl.Endpoints)
+	}
+
+}
@@ -0,0 +1,225 @@
+/*
+The templates package replicates parts of a design. A template is a saved
+subgraph of a design with parameters, each instance of it is a renamed copy
+with the links and plinks between the template elements rewired to the copies.
+Instances remember how they were made so they can be remade when the template
+changes.
+*/
+package templates
+
+import (
+	"addie"
+	"addie/protocol"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+The naming pattern instances use when none is given
+*/
+const DefaultPattern = "{name}{i}"
+
+/*
+An Element is a template element in the form the update protocol uses, the
+parameter references are substituted in the JSON text before it is decoded
+*/
+type Element struct {
+	Type    string          `json:"type"`
+	Element json.RawMessage `json:"element"`
+}
+
+type Template struct {
+	Name string `json:"name"`
+	//the template parameters and their default values
+	Params   map[string]string `json:"params"`
+	Elements []Element         `json:"elements"`
+	//incremented every time the template is saved
+	Version int `json:"version"`
+}
+
+/*
+An Instance records how one copy of a template was made, Elements maps the
+ids of the template elements to the ids of their copies
+*/
+type Instance struct {
+	Id       int                 `json:"id"`
+	Template string              `json:"template"`
+	Version  int                 `json:"version"`
+	Index    int                 `json:"index"`
+	Pattern  string              `json:"pattern"`
+	Sys      string              `json:"sys"`
+	Params   map[string]string   `json:"params"`
+	Elements map[string]addie.Id `json:"elements"`
+}
+
+var paramRx = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+/*
+New creates a template from elements of a design. Every parameter the
+elements refer to must have a default value in params.
+*/
+func New(name string, params map[string]string,
+	elements []addie.Identify) (Template, error) {
+
+	t := Template{Name: name, Params: params}
+	if t.Params == nil {
+		t.Params = make(map[string]string)
+	}
+
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i].Identify().String() < elements[j].Identify().String()
+	})
+
+	for _, e := range elements {
+		js, err := json.Marshal(e)
+		if err != nil {
+			return t, err
+		}
+		for _, m := range paramRx.FindAllStringSubmatch(string(js), -1) {
+			if _, ok := t.Params[m[1]]; !ok {
+				return t, fmt.Errorf("the element %s refers to the parameter %s "+
+					"which the template does not have", e.Identify(), m[1])
+			}
+		}
+		t.Elements = append(t.Elements,
+			Element{Type: reflect.TypeOf(e).Name(), Element: js})
+	}
+
+	return t, nil
+
+}
+
+/*
+Name expands a naming pattern for the template element name in instance i
+*/
+func Name(pattern, name string, i int) string {
+
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+
+	return strings.Replace(strings.Replace(pattern, "{name}", name, -1),
+		"{i}", strconv.Itoa(i), -1)
+
+}
+
+/*
+Instantiate makes instance i of a template in the system sys of the design
+dsg. The elements are named by pattern and the template parameters take the
+values in params over their defaults.
+*/
+func Instantiate(t Template, dsg, sys, pattern string, i int,
+	params map[string]string) (Instance, []addie.Identify, error) {
+
+	inst := Instance{
+		Template: t.Name,
+		Version:  t.Version,
+		Index:    i,
+		Pattern:  pattern,
+		Sys:      sys,
+		Params:   params,
+		Elements: make(map[string]addie.Id),
+	}
+
+	values := make(map[string]string)
+	for k, v := range t.Params {
+		values[k] = v
+	}
+	for k, v := range params {
+		if _, ok := t.Params[k]; !ok {
+			return inst, nil, fmt.Errorf("the template %s has no parameter %s", t.Name, k)
+		}
+		values[k] = v
+	}
+	for k, v := range values {
+		values[k] = strings.Replace(v, "{i}", strconv.Itoa(i), -1)
+	}
+
+	var elements []addie.Identify
+	ids := make(map[addie.Id]addie.Id)
+	names := make(map[addie.Id]bool)
+
+	for _, te := range t.Elements {
+		raw := paramRx.ReplaceAllStringFunc(string(te.Element), func(m string) string {
+			//the value lands inside a JSON string, quote it the same way
+			js, _ := json.Marshal(values[paramRx.FindStringSubmatch(m)[1]])
+			return string(js[1 : len(js)-1])
+		})
+
+		e, err := protocol.DecodeElement(te.Type, json.RawMessage(raw))
+		if err != nil {
+			return inst, nil, fmt.Errorf("template %s: %v", t.Name, err)
+		}
+
+		old := e.Identify()
+		id := addie.Id{Name: Name(pattern, old.Name, i), Sys: sys, Design: dsg}
+		if names[id] {
+			return inst, nil, fmt.Errorf("the pattern %s gives two elements of the "+
+				"template %s the name %s", pattern, t.Name, id.Name)
+		}
+		names[id] = true
+		ids[old] = id
+		inst.Elements[old.String()] = id
+
+		elements = append(elements, e)
+	}
+
+	for k, e := range elements {
+		e, err := addie.WithId(e, ids[e.Identify()])
+		if err != nil {
+			return inst, nil, err
+		}
+		elements[k] = addie.Relabel(e, ids)
+	}
+
+	return inst, elements, nil
+
+}
+
+/*
+Remake makes an instance again from the current version of its template, the
+new instance keeps the index, naming and parameters of the old one. Elements
+of the old instance that were moved to another system stay there.
+*/
+func Remake(t Template, old Instance, dsg string) (Instance, []addie.Identify, error) {
+
+	inst, elements, err := Instantiate(t, dsg, old.Sys, old.Pattern, old.Index,
+		old.Params)
+	inst.Id = old.Id
+	if err != nil {
+		return inst, elements, err
+	}
+
+	d := addie.EmptyDesign(dsg)
+	for _, e := range elements {
+		d.Elements[e.Identify()] = e
+	}
+	ids := make(map[addie.Id]addie.Id)
+	for k, id := range inst.Elements {
+		was, ok := old.Elements[k]
+		if !ok || was.Sys == id.Sys {
+			continue
+		}
+		nid, err := d.MoveElement(id, was.Sys)
+		if err != nil {
+			return inst, nil, err
+		}
+		inst.Elements[k] = nid
+		ids[id] = nid
+	}
+	for k, e := range elements {
+		id := e.Identify()
+		if nid, ok := ids[id]; ok {
+			id = nid
+		}
+		elements[k] = d.Elements[id]
+	}
+
+	return inst, elements, nil
+
+}
@@ -0,0 +1,144 @@
+package templates
+
+import (
+	"addie"
+	"testing"
+)
+
+func station() []addie.Identify {
+
+	id := func(n string) addie.Id { return addie.Id{Name: n, Sys: "root", Design: "oban"} }
+
+	p := addie.Phyo{}
+	p.Id = id("pump")
+	p.Model = "Tank"
+	p.Args = "A=${area}, k=0.5"
+
+	s := addie.Sax{}
+	s.Id = id("ctl")
+	s.Sense = "h(10)"
+	s.Actuate = "qin(0,1)"
+
+	c := addie.Computer{}
+	c.Id = id("hmi")
+
+	l := addie.Link{}
+	l.Id = id("lan")
+	l.Endpoints = [2]addie.NetIfRef{{Id: c.Id, IfName: "eth0"}, {Id: s.Id, IfName: "eth0"}}
+
+	//the uplink leaves the template for a switch every station shares
+	u := addie.Link{}
+	u.Id = id("uplink")
+	u.Endpoints = [2]addie.NetIfRef{{Id: c.Id, IfName: "eth1"}, {Id: id("backbone"), IfName: "eth0"}}
+
+	pl := addie.Plink{}
+	pl.Id = id("wire")
+	pl.Endpoints = [2]addie.Id{s.Id, p.Id}
+	pl.Bindings = [2]string{"h,qin", "h,qin"}
+
+	return []addie.Identify{p, s, c, l, u, pl}
+
+}
+
+func TestInstantiate(t *testing.T) {
+
+	if _, err := New("station", nil, station()); err == nil {
+		t.Fatal("a template with an undefined parameter was created")
+	}
+
+	tmpl, err := New("station", map[string]string{"area": "2"}, station())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.Version = 1
+
+	inst, elements, err := Instantiate(tmpl, "oban", "plant", "s{i}-{name}", 3,
+		map[string]string{"area": "{i}.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(elements) != 6 || len(inst.Elements) != 6 || inst.Version != 1 {
+		t.Fatalf("bad instance %+v %v", inst, elements)
+	}
+
+	byName := make(map[string]addie.Identify)
+	for _, e := range elements {
+		if e.Identify().Sys != "plant" || e.Identify().Design != "oban" {
+			t.Fatalf("element placed in the wrong system %v", e.Identify())
+		}
+		byName[e.Identify().Name] = e
+	}
+
+	p, ok := byName["s3-pump"].(addie.Phyo)
+	if !ok || p.Args != "A=3.5, k=0.5" {
+		t.Fatalf("bad pump %+v", byName["s3-pump"])
+	}
+
+	l := byName["s3-lan"].(addie.Link)
+	if l.Endpoints[0].Name != "s3-hmi" || l.Endpoints[1].Name != "s3-ctl" ||
+		l.Endpoints[1].Sys != "plant" {
+		t.Fatalf("the lan was not rewired %v", l.Endpoints)
+	}
+
+	u := byName["s3-uplink"].(addie.Link)
+	if u.Endpoints[0].Name != "s3-hmi" || u.Endpoints[1].Name != "backbone" ||
+		u.Endpoints[1].Sys != "root" {
+		t.Fatalf("the uplink was not rewired %v", u.Endpoints)
+	}
+
+	pl := byName["s3-wire"].(addie.Plink)
+	if pl.Endpoints[0].Name != "s3-ctl" || pl.Endpoints[1].Name != "s3-pump" {
+		t.Fatalf("the plink was not rewired %v", pl.Endpoints)
+	}
+
+	//a newer template remakes the instance with its parameters and ids
+	inst.Id = 7
+	tmpl.Version = 2
+	tmpl.Params["area"] = "4"
+	again, elements, err := Remake(tmpl, inst, "oban")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != 7 || again.Version != 2 || len(elements) != 6 {
+		t.Fatalf("bad remade instance %+v", again)
+	}
+	for k, id := range inst.Elements {
+		if again.Elements[k] != id {
+			t.Fatalf("the remade instance renamed %s to %v", k, again.Elements[k])
+		}
+	}
+
+	//an element moved to another system is remade there
+	for k, id := range inst.Elements {
+		if id.Name == "s3-ctl" {
+			inst.Elements[k] = addie.Id{Name: id.Name, Sys: "boiler", Design: id.Design}
+		}
+	}
+	again, elements, err = Remake(tmpl, inst, "oban")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range elements {
+		switch e.(type) {
+		case addie.Sax:
+			if e.Identify().Sys != "boiler" {
+				t.Fatalf("the moved sax was remade in %s", e.Identify().Sys)
+			}
+		case addie.Link:
+			x := e.(addie.Link)
+			if x.Id.Name == "s3-lan" && x.Endpoints[1].Sys != "boiler" {
+				t.Fatalf("the lan was not rewired to the moved sax %v", x.Endpoints)
+			}
+		}
+	}
+	for k, id := range inst.Elements {
+		if again.Elements[k] != id {
+			t.Fatalf("the remade instance moved %s to %v", k, again.Elements[k])
+		}
+	}
+
+	if _, _, err := Instantiate(tmpl, "oban", "plant", "station", 1, nil); err == nil {
+		t.Fatal("a pattern that names every element the same was allowed")
+	}
+
+}
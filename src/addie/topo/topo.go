@@ -0,0 +1,384 @@
+/*
+The topo package generates the network elements of common topologies. The
+generated switches, routers and computers get an interface for every link
+that attaches to them, eth0 for the first, eth1 for the second and so on.
+*/
+package topo
+
+import (
+	"addie"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+/*
+The topologies Generate knows
+*/
+var Shapes = []string{"star", "ring", "tree", "mesh", "dumbbell", "erdos-renyi",
+	"waxman"}
+
+/*
+The most nodes, or links for a mesh or a random graph, a single generated
+topology may have
+*/
+const MaxNodes = 4096
+
+/*
+The distance between neighbouring generated nodes
+*/
+const Spacing = 100
+
+/*
+A Spec describes a topology to generate. Nodes is the number of leaves of a
+star, the number of routers in a ring, a mesh or a random graph and the number
+of computers on each side of a dumbbell. Trees have Fanout children per node
+and Depth levels below the root.
+*/
+type Spec struct {
+	Shape string `json:"shape"`
+	//element names start with Prefix, the shape name by default
+	Prefix string `json:"prefix"`
+	Nodes  int    `json:"nodes"`
+	Fanout int    `json:"fanout"`
+	Depth  int    `json:"depth"`
+	//the edge probability of an Erdős–Rényi graph
+	P float64 `json:"p"`
+	//the Waxman edge probability is Alpha exp(-d / Beta L)
+	Alpha float64 `json:"alpha"`
+	Beta  float64 `json:"beta"`
+	Seed  int64   `json:"seed"`
+	//the packet conductor of every node, interface and link
+	Capacity int `json:"capacity"`
+	Latency  int `json:"latency"`
+	//the capacity of the link between the two halves of a dumbbell, the
+	//Capacity when 0
+	Bottleneck int            `json:"bottleneck"`
+	Origin     addie.Position `json:"origin"`
+}
+
+type node struct {
+	kind     string
+	name     string
+	position addie.Position
+	ifs      int
+}
+
+type edge struct {
+	a, b     *node
+	capacity int
+}
+
+type builder struct {
+	spec  Spec
+	nodes []*node
+	edges []edge
+}
+
+func (b *builder) node(kind, name string, x, y float64) *node {
+
+	n := &node{kind: kind, name: b.spec.Prefix + "-" + name,
+		position: addie.Position{
+			X: b.spec.Origin.X + float32(x*Spacing),
+			Y: b.spec.Origin.Y + float32(y*Spacing),
+			Z: b.spec.Origin.Z,
+		}}
+	b.nodes = append(b.nodes, n)
+	return n
+
+}
+
+func (b *builder) link(x, y *node) {
+	b.edges = append(b.edges, edge{x, y, b.spec.Capacity})
+}
+
+/*
+circle returns the position of the i'th of n nodes spread around a circle
+large enough to keep them Spacing apart
+*/
+func circle(i, n int) (float64, float64) {
+
+	r := math.Max(1, float64(n)/(2*math.Pi))
+	a := 2 * math.Pi * float64(i) / float64(n)
+	return r * math.Cos(a), r * math.Sin(a)
+
+}
+
+func (b *builder) star() {
+
+	hub := b.node("Switch", "sw", 0, 0)
+	for i := 0; i < b.spec.Nodes; i++ {
+		x, y := circle(i, b.spec.Nodes)
+		b.link(hub, b.node("Computer", fmt.Sprintf("c%d", i), x, y))
+	}
+
+}
+
+func (b *builder) routers() []*node {
+
+	var rs []*node
+	for i := 0; i < b.spec.Nodes; i++ {
+		x, y := circle(i, b.spec.Nodes)
+		rs = append(rs, b.node("Router", fmt.Sprintf("r%d", i), x, y))
+	}
+	return rs
+
+}
+
+func (b *builder) ring() {
+
+	rs := b.routers()
+	for i := range rs {
+		b.link(rs[i], rs[(i+1)%len(rs)])
+	}
+
+}
+
+func (b *builder) mesh() {
+
+	rs := b.routers()
+	for i := range rs {
+		for j := i + 1; j < len(rs); j++ {
+			b.link(rs[i], rs[j])
+		}
+	}
+
+}
+
+/*
+tree builds switches down to Depth levels below the root with computers as
+the leaves, each level is laid out on its own row
+*/
+func (b *builder) tree() {
+
+	width := math.Pow(float64(b.spec.Fanout), float64(b.spec.Depth))
+	level := []*node{b.node("Switch", "s0-0", (width-1)/2, 0)}
+
+	for d := 1; d <= b.spec.Depth; d++ {
+		var next []*node
+		n := len(level) * b.spec.Fanout
+		stride := width / float64(n)
+		for i := 0; i < n; i++ {
+			x := stride*float64(i) + (stride-1)/2
+			var c *node
+			if d == b.spec.Depth {
+				c = b.node("Computer", fmt.Sprintf("c%d", i), x, float64(d))
+			} else {
+				c = b.node("Switch", fmt.Sprintf("s%d-%d", d, i), x, float64(d))
+			}
+			b.link(level[i/b.spec.Fanout], c)
+			next = append(next, c)
+		}
+		level = next
+	}
+
+}
+
+func (b *builder) dumbbell() {
+
+	left := b.node("Router", "left", 0, 0)
+	right := b.node("Router", "right", 3, 0)
+
+	capacity := b.spec.Bottleneck
+	if capacity == 0 {
+		capacity = b.spec.Capacity
+	}
+	b.edges = append(b.edges, edge{left, right, capacity})
+
+	for i := 0; i < b.spec.Nodes; i++ {
+		y := float64(i) - float64(b.spec.Nodes-1)/2
+		b.link(left, b.node("Computer", fmt.Sprintf("a%d", i), -1, y))
+		b.link(right, b.node("Computer", fmt.Sprintf("b%d", i), 4, y))
+	}
+
+}
+
+func (b *builder) erdosRenyi(rnd *rand.Rand) {
+
+	rs := b.routers()
+	for i := range rs {
+		for j := i + 1; j < len(rs); j++ {
+			if rnd.Float64() < b.spec.P {
+				b.link(rs[i], rs[j])
+			}
+		}
+	}
+
+}
+
+/*
+waxman places routers uniformly in a square and links each pair with a
+probability that decays with their distance
+*/
+func (b *builder) waxman(rnd *rand.Rand) {
+
+	side := math.Sqrt(float64(b.spec.Nodes))
+	xs := make([]float64, b.spec.Nodes)
+	ys := make([]float64, b.spec.Nodes)
+
+	var rs []*node
+	for i := 0; i < b.spec.Nodes; i++ {
+		xs[i], ys[i] = rnd.Float64()*side, rnd.Float64()*side
+		rs = append(rs, b.node("Router", fmt.Sprintf("r%d", i), xs[i], ys[i]))
+	}
+
+	l := side * math.Sqrt2
+	for i := range rs {
+		for j := i + 1; j < len(rs); j++ {
+			d := math.Hypot(xs[i]-xs[j], ys[i]-ys[j])
+			if rnd.Float64() < b.spec.Alpha*math.Exp(-d/(b.spec.Beta*l)) {
+				b.link(rs[i], rs[j])
+			}
+		}
+	}
+
+}
+
+func (s Spec) check() error {
+
+	var size, links float64
+	pairs := float64(s.Nodes) * float64(s.Nodes-1) / 2
+	switch s.Shape {
+	case "star", "dumbbell":
+		if s.Nodes < 1 {
+			return fmt.Errorf("a %s needs at least one node", s.Shape)
+		}
+		size = 2 * float64(s.Nodes)
+	case "ring":
+		if s.Nodes < 3 {
+			return fmt.Errorf("a ring needs at least 3 nodes")
+		}
+	case "mesh":
+		if s.Nodes < 2 {
+			return fmt.Errorf("a mesh needs at least 2 nodes")
+		}
+		links = pairs
+	case "tree":
+		if s.Fanout < 1 || s.Depth < 1 {
+			return fmt.Errorf("a tree needs a fanout and a depth of at least 1")
+		}
+		if s.Depth >= MaxNodes {
+			return fmt.Errorf("a tree can not be more than %d deep", MaxNodes-1)
+		}
+		//every level has Fanout times the nodes of the one above it
+		level := 1.0
+		for d := 0; d <= s.Depth && size <= MaxNodes; d++ {
+			size += level
+			level *= float64(s.Fanout)
+		}
+	case "erdos-renyi":
+		if s.Nodes < 1 || s.P < 0 || s.P > 1 {
+			return fmt.Errorf("an Erdős–Rényi graph needs nodes and a " +
+				"probability between 0 and 1")
+		}
+		links = s.P * pairs
+	case "waxman":
+		if s.Nodes < 1 || s.Alpha <= 0 || s.Alpha > 1 || s.Beta <= 0 {
+			return fmt.Errorf("a Waxman graph needs nodes, an alpha in (0,1] " +
+				"and a positive beta")
+		}
+		//no pair is linked with a probability above alpha
+		links = s.Alpha * pairs
+	default:
+		return fmt.Errorf("unknown topology %s", s.Shape)
+	}
+
+	if size == 0 {
+		size = float64(s.Nodes)
+	}
+	if size > MaxNodes {
+		return fmt.Errorf("the %s would have more than %d nodes", s.Shape, MaxNodes)
+	}
+	if links > MaxNodes {
+		return fmt.Errorf("the %s would have more than %d links", s.Shape, MaxNodes)
+	}
+	if s.Capacity < 0 || s.Latency < 0 || s.Bottleneck < 0 {
+		return fmt.Errorf("capacities and latencies can not be negative")
+	}
+
+	return nil
+
+}
+
+/*
+Generate creates the elements of the topology spec describes in the system
+sys of the design dsg
+*/
+func Generate(spec Spec, dsg, sys string) ([]addie.Identify, error) {
+
+	err := spec.check()
+	if err != nil {
+		return nil, err
+	}
+	if spec.Prefix == "" {
+		spec.Prefix = spec.Shape
+	}
+
+	b := &builder{spec: spec}
+	rnd := rand.New(rand.NewSource(spec.Seed))
+
+	switch spec.Shape {
+	case "star":
+		b.star()
+	case "ring":
+		b.ring()
+	case "tree":
+		b.tree()
+	case "mesh":
+		b.mesh()
+	case "dumbbell":
+		b.dumbbell()
+	case "erdos-renyi":
+		b.erdosRenyi(rnd)
+	case "waxman":
+		b.waxman(rnd)
+	}
+
+	id := func(name string) addie.Id {
+		return addie.Id{Name: name, Sys: sys, Design: dsg}
+	}
+	pc := addie.PacketConductor{Capacity: spec.Capacity, Latency: spec.Latency}
+
+	//the interfaces are handed out as the links attach
+	hosts := make(map[*node]*addie.NetHost)
+	for _, n := range b.nodes {
+		hosts[n] = &addie.NetHost{Id: id(n.name),
+			Interfaces: make(map[string]addie.Interface)}
+	}
+	var attach = func(n *node, capacity int) addie.NetIfRef {
+		name := fmt.Sprintf("eth%d", n.ifs)
+		n.ifs++
+		hosts[n].Interfaces[name] = addie.Interface{Name: name,
+			PacketConductor: addie.PacketConductor{Capacity: capacity,
+				Latency: spec.Latency}}
+		return addie.NetIfRef{Id: hosts[n].Id, IfName: name}
+	}
+
+	var links []addie.Identify
+	for i, e := range b.edges {
+		l := addie.Link{}
+		l.Id = id(fmt.Sprintf("%s-l%d", spec.Prefix, i))
+		l.PacketConductor = addie.PacketConductor{Capacity: e.capacity,
+			Latency: spec.Latency}
+		l.Endpoints = [2]addie.NetIfRef{attach(e.a, e.capacity), attach(e.b, e.capacity)}
+		links = append(links, l)
+	}
+
+	var result []addie.Identify
+	for _, n := range b.nodes {
+		switch n.kind {
+		case "Switch":
+			result = append(result, addie.Switch{NetHost: *hosts[n],
+				PacketConductor: pc, Position: n.position})
+		case "Router":
+			result = append(result, addie.Router{NetHost: *hosts[n],
+				PacketConductor: pc, Position: n.position})
+		case "Computer":
+			result = append(result, addie.Computer{NetHost: *hosts[n],
+				Position: n.position})
+		}
+	}
+
+	return append(result, links...), nil
+
+}
@@ -0,0 +1,144 @@
+package topo
+
+import (
+	"addie"
+	"testing"
+)
+
+func count(t *testing.T, es []addie.Identify) (int, int, int, int) {
+
+	var computers, switches, routers, links int
+	ifs := make(map[addie.Id]map[string]bool)
+	for _, e := range es {
+		switch x := e.(type) {
+		case addie.Computer:
+			computers++
+			ifs[x.Id] = make(map[string]bool)
+			for k := range x.Interfaces {
+				ifs[x.Id][k] = true
+			}
+		case addie.Switch:
+			switches++
+			ifs[x.Id] = make(map[string]bool)
+			for k := range x.Interfaces {
+				ifs[x.Id][k] = true
+			}
+		case addie.Router:
+			routers++
+			ifs[x.Id] = make(map[string]bool)
+			for k := range x.Interfaces {
+				ifs[x.Id][k] = true
+			}
+		}
+	}
+
+	//every link endpoint is an interface of a generated node, used once
+	for _, e := range es {
+		if l, ok := e.(addie.Link); ok {
+			links++
+			for _, ep := range l.Endpoints {
+				if !ifs[ep.Id][ep.IfName] {
+					t.Fatalf("link %s has a dangling endpoint %v", l.Name, ep)
+				}
+				delete(ifs[ep.Id], ep.IfName)
+			}
+		}
+	}
+	for id, rest := range ifs {
+		if len(rest) != 0 {
+			t.Fatalf("%s has unused interfaces %v", id, rest)
+		}
+	}
+
+	return computers, switches, routers, links
+
+}
+
+func TestGenerate(t *testing.T) {
+
+	cases := []struct {
+		spec                                Spec
+		computers, switches, routers, links int
+	}{
+		{Spec{Shape: "star", Nodes: 5}, 5, 1, 0, 5},
+		{Spec{Shape: "ring", Nodes: 6}, 0, 0, 6, 6},
+		{Spec{Shape: "tree", Fanout: 2, Depth: 3}, 8, 7, 0, 14},
+		{Spec{Shape: "mesh", Nodes: 5}, 0, 0, 5, 10},
+		{Spec{Shape: "dumbbell", Nodes: 3}, 6, 0, 2, 7},
+		{Spec{Shape: "erdos-renyi", Nodes: 8, P: 1}, 0, 0, 8, 28},
+		{Spec{Shape: "erdos-renyi", Nodes: 8, P: 0}, 0, 0, 8, 0},
+	}
+
+	for _, c := range cases {
+		c.spec.Capacity, c.spec.Latency = 1000, 5
+		es, err := Generate(c.spec, "glenlivet", "lan")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs, ss, rs, ls := count(t, es)
+		if cs != c.computers || ss != c.switches || rs != c.routers || ls != c.links {
+			t.Fatalf("%s generated %d computers %d switches %d routers %d links",
+				c.spec.Shape, cs, ss, rs, ls)
+		}
+		for _, e := range es {
+			if e.Identify().Sys != "lan" || e.Identify().Design != "glenlivet" {
+				t.Fatalf("element placed in the wrong system %v", e.Identify())
+			}
+		}
+	}
+
+}
+
+func TestDumbbellBottleneck(t *testing.T) {
+
+	es, err := Generate(Spec{Shape: "dumbbell", Nodes: 2, Capacity: 1000,
+		Bottleneck: 10}, "glenlivet", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es {
+		if l, ok := e.(addie.Link); ok && l.Name == "dumbbell-l0" {
+			if l.Capacity != 10 {
+				t.Fatalf("bad bottleneck capacity %d", l.Capacity)
+			}
+			return
+		}
+	}
+	t.Fatal("no bottleneck link")
+
+}
+
+func TestWaxman(t *testing.T) {
+
+	spec := Spec{Shape: "waxman", Nodes: 30, Alpha: 0.6, Beta: 0.3, Seed: 47}
+	a, err := Generate(spec, "glenlivet", "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Generate(spec, "glenlivet", "root")
+	_, _, rs, ls := count(t, a)
+	if rs != 30 || ls == 0 || ls == 30*29/2 || len(a) != len(b) {
+		t.Fatalf("bad waxman graph, %d routers %d links", rs, ls)
+	}
+
+}
+
+func TestBadSpecs(t *testing.T) {
+
+	for _, s := range []Spec{
+		{Shape: "ring", Nodes: 2},
+		{Shape: "tree", Fanout: 10, Depth: 5},
+		{Shape: "tree", Fanout: 1, Depth: 10000},
+		{Shape: "tree", Fanout: 2, Depth: 1 << 40},
+		{Shape: "mesh", Nodes: 100},
+		{Shape: "erdos-renyi", Nodes: 4096, P: 1},
+		{Shape: "waxman", Nodes: 200, Alpha: 1, Beta: 1},
+		{Shape: "waxman", Nodes: 5, Alpha: 2, Beta: 1},
+		{Shape: "hypercube", Nodes: 8},
+	} {
+		if _, err := Generate(s, "glenlivet", "root"); err == nil {
+			t.Fatalf("bad spec %+v accepted", s)
+		}
+	}
+
+}
@@ -0,0 +1,145 @@
+/*
+The trace package reads the recorded field data files that back trace elements.
+Traces are stored as either CSV with a header row, or JSON Lines where every
+line is an object keyed by column name. In both cases the first column is time.
+*/
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+const (
+	Linear = "linear"
+	Hold   = "hold"
+)
+
+var Formats = []string{CSV, JSONL}
+var Interpolations = []string{Linear, Hold}
+
+/*
+The FormatOf function guesses the trace format of a file from its extension,
+returning the empty string if the extension is not one we know about.
+*/
+func FormatOf(file string) string {
+
+	switch strings.ToLower(path.Ext(file)) {
+	case ".csv":
+		return CSV
+	case ".jsonl", ".ndjson":
+		return JSONL
+	}
+
+	return ""
+
+}
+
+/*
+The Columns function reads the column names of the trace file at the given
+path.
+*/
+func Columns(file, format string) ([]string, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		log.Println(err)
+		return nil, fmt.Errorf("could not open trace file '%s'", file)
+	}
+	defer f.Close()
+
+	return ReadColumns(f, format)
+
+}
+
+/*
+The ReadColumns function reads the column names of a trace from r. For CSV
+this is the header row, for JSON Lines it is the keys of the first record in
+the order they appear.
+*/
+func ReadColumns(r io.Reader, format string) ([]string, error) {
+
+	br := bufio.NewReader(r)
+
+	line, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		log.Println(err)
+		return nil, fmt.Errorf("could not read trace header")
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, fmt.Errorf("the trace is empty")
+	}
+
+	switch format {
+	case CSV:
+		return csvColumns(line)
+	case JSONL:
+		return jsonlColumns(line)
+	}
+
+	return nil, fmt.Errorf("unknown trace format '%s'", format)
+
+}
+
+func csvColumns(header string) ([]string, error) {
+
+	var cs []string
+	for _, c := range strings.Split(header, ",") {
+		c = strings.Trim(strings.TrimSpace(c), "\"")
+		if c == "" {
+			return nil, fmt.Errorf("the trace header contains an empty column name")
+		}
+		cs = append(cs, c)
+	}
+
+	return cs, nil
+
+}
+
+// the keys are read off the token stream as a map would lose their order
+func jsonlColumns(record string) ([]string, error) {
+
+	dec := json.NewDecoder(bytes.NewBufferString(record))
+
+	t, err := dec.Token()
+	if err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("the first trace record is not a json object")
+	}
+
+	var cs []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			log.Println(err)
+			return nil, fmt.Errorf("malformed trace record")
+		}
+		k, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("malformed trace record")
+		}
+		cs = append(cs, k)
+
+		var v json.RawMessage
+		err = dec.Decode(&v)
+		if err != nil {
+			log.Println(err)
+			return nil, fmt.Errorf("malformed value for trace column '%s'", k)
+		}
+	}
+
+	return cs, nil
+
+}
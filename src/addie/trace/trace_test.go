@@ -0,0 +1,60 @@
+package trace
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCSVColumns(t *testing.T) {
+
+	r := bytes.NewBufferString("t, flow,\"pressure\"\n0,1.2,3.4\n")
+	cs, err := ReadColumns(r, CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cs, []string{"t", "flow", "pressure"}) {
+		t.Fatalf("bad csv columns %v", cs)
+	}
+
+}
+
+func TestJSONLColumns(t *testing.T) {
+
+	r := bytes.NewBufferString(
+		"{\"time\": 0, \"w\": 1.5, \"meta\": {\"a\": 1}}\n{\"time\": 1, \"w\": 2}\n")
+	cs, err := ReadColumns(r, JSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cs, []string{"time", "w", "meta"}) {
+		t.Fatalf("bad jsonl columns %v", cs)
+	}
+
+}
+
+func TestBadTraces(t *testing.T) {
+
+	_, err := ReadColumns(bytes.NewBufferString(""), CSV)
+	if err == nil {
+		t.Fatal("empty trace accepted")
+	}
+
+	_, err = ReadColumns(bytes.NewBufferString("[1,2]\n"), JSONL)
+	if err == nil {
+		t.Fatal("non-object jsonl record accepted")
+	}
+
+	_, err = ReadColumns(bytes.NewBufferString("t,,x\n"), CSV)
+	if err == nil {
+		t.Fatal("empty csv column accepted")
+	}
+
+	if FormatOf("plant.NDJSON") != JSONL || FormatOf("plant.csv") != CSV ||
+		FormatOf("plant.txt") != "" {
+		t.Fatal("bad format detection")
+	}
+
+}
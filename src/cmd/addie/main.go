@@ -2,11 +2,21 @@ package main
 
 import (
 	"addie"
+	"addie/analysis"
 	"addie/db"
 	"addie/deter"
+	"addie/emu"
+	"addie/graph"
+	"addie/layout"
+	"addie/modelica"
+	"addie/param"
 	"addie/protocol"
 	"addie/sema"
 	"addie/sim"
+	"addie/stdlib"
+	"addie/templates"
+	"addie/topo"
+	"addie/trace"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -21,6 +31,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -30,7 +41,16 @@ import (
 
 var design addie.Design
 var userModels = make(map[string]addie.Model)
+
+/*
+The published models references resolved to. Pinned references never change,
+the others are resolved again after a publish.
+*/
+var publishedModels = make(map[string]addie.Model)
 var simSettings addie.SimSettings
+var systems = make(addie.SystemTree)
+var templateInstances []templates.Instance
+var initSets = make(map[string]addie.InitSet)
 var cypdir = os.ExpandEnv("/cypress")
 var user = ""
 var kryClusterSize = 1
@@ -132,6 +152,9 @@ func dbCreate(e addie.Identify) {
 	case addie.Plink:
 		p := e.(addie.Plink)
 		err = db.CreatePlink(p, user)
+	case addie.Trace:
+		tr := e.(addie.Trace)
+		_, err = db.CreateTrace(tr, user)
 	default:
 		log.Printf("[dbCreate] unkown or unsupported element type: %T \n", t)
 	}
@@ -189,6 +212,9 @@ func dbUpdate(oid addie.Id, e addie.Identify) {
 	case addie.Plink:
 		p := e.(addie.Plink)
 		_, err = db.UpdatePlink(oid, p, user)
+	case addie.Trace:
+		tr := e.(addie.Trace)
+		_, err = db.UpdateTrace(oid, tr, user)
 	default:
 		log.Printf("[dbUpdate] unkown or unsupported element type: %T \n", t)
 	}
@@ -216,6 +242,7 @@ func updateSimSettings(s addie.SimSettings) {
 	}
 
 	simSettings = s
+	kryClusterSize = clusterSize(simSettings)
 }
 
 func modelId(name string) addie.Id {
@@ -267,11 +294,13 @@ func onUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	var changed_models []addie.Model
 	var changed_model_oids []addie.Id
 	var modelKillList []string
+	modelRenames := make(map[string]string)
 
 	var placeModel = func(oid string, m addie.Model) {
 
 		if m.Name != oid {
 			modelKillList = append(modelKillList, oid)
+			modelRenames[oid] = m.Name
 		}
 		_, ok := userModels[oid]
 		if !ok {
@@ -356,6 +385,13 @@ func onUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 				log.Println("unable to marshal sax")
 			}
 			place(u.OID, s)
+		case "Trace":
+			var t addie.Trace
+			err := json.Unmarshal(u.Element, &t)
+			if err != nil {
+				log.Println("unable to unmarshal trace")
+			}
+			place(u.OID, t)
 		case "SimSettings":
 			var s addie.SimSettings
 			err := json.Unmarshal(u.Element, &s)
@@ -371,22 +407,29 @@ func onUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 	}
 
+	var diagnostics sema.Diagnostics
 	for i, u := range changed_models {
 		dbUpdate(changed_model_oids[i], u)
 		userModels[u.Name] = u
+		_ds := sema.CheckModelTests(u)
+		diagnostics.Merge(&_ds)
 	}
 	for _, c := range new_models {
 		dbCreate(c)
 		userModels[c.Name] = c
+		_ds := sema.CheckModelTests(c)
+		diagnostics.Merge(&_ds)
 	}
 
 	for i, u := range changed_nodes {
 		dbUpdate(changed_node_oids[i], u)
 		design.Elements[u.Identify()] = u
+		trackSystem(u.Identify().Sys)
 	}
 	for _, c := range new_nodes {
 		dbCreate(c)
 		design.Elements[c.Identify()] = c
+		trackSystem(c.Identify().Sys)
 	}
 
 	for i, u := range changed_links {
@@ -406,10 +449,24 @@ func onUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	for _, k := range modelKillList {
 		delete(userModels, k)
 	}
+	for old, name := range modelRenames {
+		renamePhyoModels(old, name)
+	}
 
 	//log.Println("\n", design.String())
 
-	//send response
+	//send response, the results of the model tests if any were run
+	if len(diagnostics.Elements) > 0 {
+		js, err := json.Marshal(diagnostics)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(js)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 
 }
@@ -475,6 +532,14 @@ func onDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 			}
 			log.Printf("deleting %s %v", d.Type, p.Id)
 			nodes[p.Id] = p
+		case "Trace":
+			var t addie.Trace
+			err := json.Unmarshal(d.Element, &t)
+			if err != nil {
+				log.Println("unable to unmarshal " + d.Type)
+			}
+			log.Printf("deleting %s %v", d.Type, t.Id)
+			nodes[t.Id] = t
 		case "Link":
 			var l addie.Link
 			err := json.Unmarshal(d.Element, &l)
@@ -523,7 +588,12 @@ func onDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 func onRead(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
-	json, err := modelJson()
+	var collapse []string
+	if c := r.URL.Query().Get("collapse"); c != "" {
+		collapse = strings.Split(c, ",")
+	}
+
+	json, err := modelJson(collapse)
 
 	if err != nil {
 		log.Println("modelJson failed")
@@ -557,6 +627,19 @@ func doRead() error {
 
 	design = *dsg
 
+	syss, err := db.ReadSystems(design.Name, user)
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("failed to read systems")
+	}
+	systems = addie.NewSystemTree(syss)
+
+	templateInstances, err = db.ReadTemplateInstances(design.Name, user)
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("failed to read template instances")
+	}
+
 	mls, err := db.ReadUserModels(user)
 	if err != nil {
 		log.Println(err)
@@ -582,32 +665,58 @@ func doRead() error {
 	}
 
 	simSettings = *ss
+	kryClusterSize = clusterSize(simSettings)
+
+	sets, err := db.ReadInitSets(design.Name, user)
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("could not read initial condition sets")
+	}
+
+	initSets = make(map[string]addie.InitSet)
+	for _, s := range sets {
+		initSets[s.Name] = s
+	}
 
 	return nil
 }
 
+func clusterSize(s addie.SimSettings) int {
+	if s.ClusterSize < 1 {
+		return 1
+	}
+	return s.ClusterSize
+}
+
 type JsonModel struct {
-	Name        string            `json:"name"`
-	Elements    []TypeWrapper     `json:"elements"`
-	Models      []addie.Model     `json:"models"`
-	SimSettings addie.SimSettings `json:"simSettings"`
+	Name        string             `json:"name"`
+	Elements    []TypeWrapper      `json:"elements"`
+	Systems     []addie.System     `json:"systems"`
+	Models      []addie.Model      `json:"models"`
+	SimSettings addie.SimSettings  `json:"simSettings"`
+	InitSets    []addie.InitSet    `json:"initSets"`
+	Params      map[string]float64 `json:"params"`
 }
 
-func modelJson() ([]byte, error) {
+/*
+Marshals the design for clients, the elements inside the systems in collapse
+are left out
+*/
+func modelJson(collapse []string) ([]byte, error) {
 
 	var mdl JsonModel
 	mdl.Name = design.Name
 
-	mdl.Elements = make([]TypeWrapper, len(design.Elements))
+	elements, syss := design.Collapse(systems, collapse)
+	mdl.Elements = make([]TypeWrapper, len(elements))
+	mdl.Systems = syss
 	mdl.Models = make([]addie.Model, len(userModels))
 
-	i := 0
-	for _, v := range design.Elements {
+	for i, v := range elements {
 		mdl.Elements[i] = typeWrap(v)
-		i++
 	}
 
-	i = 0
+	i := 0
 	for _, v := range userModels {
 		mdl.Models[i] = v
 		i++
@@ -615,6 +724,12 @@ func modelJson() ([]byte, error) {
 
 	mdl.SimSettings = simSettings
 
+	for _, s := range initSets {
+		mdl.InitSets = append(mdl.InitSets, s)
+	}
+
+	mdl.Params = design.Params
+
 	_json, err := json.Marshal(mdl)
 	if err != nil {
 		log.Println(err)
@@ -629,15 +744,23 @@ func userDir() string {
 	return "/cypress/" + user
 }
 
-func simFileName() string {
-	return userDir() + "/" + design.Name + ".cys"
+func simNodeFileName(node int) string {
+	return userDir() + "/" + sim.SimName(design.Name, node, kryClusterSize) + ".cys"
 }
 
 func topdlFileName() string {
 	return userDir() + "/" + design.Name + ".topdl"
 }
 
-func compileSim() {
+func traceDir() string {
+	return userDir() + "/" + design.Name + ".traces"
+}
+
+func cypkNodeDir(node int) string {
+	return userDir() + "/" + sim.SimName(design.Name, node, kryClusterSize) + ".cypk"
+}
+
+func modelList() []addie.Model {
 
 	models := make([]addie.Model, len(userModels))
 	i := 0
@@ -645,10 +768,68 @@ func compileSim() {
 		models[i] = v
 		i++
 	}
-	src := sim.GenerateSource(&design, models)
-	ioutil.WriteFile(simFileName(), []byte(src), 0644)
 
-	cmd := exec.Command("cyc", simFileName())
+	return append(models, publishedModelList()...)
+
+}
+
+/*
+Resolves the published models the phyos of the design reference. Each model
+is named by the reference it resolved, so it can be found by the phyo's
+Model. References that do not resolve are left to sema to report.
+*/
+func publishedModelList() []addie.Model {
+
+	var models []addie.Model
+	seen := make(map[string]bool)
+
+	for _, e := range design.Elements {
+		p, ok := e.(addie.Phyo)
+		if !ok || seen[p.Model] {
+			continue
+		}
+		seen[p.Model] = true
+
+		ref, ok := addie.ParseModelRef(p.Model)
+		if !ok {
+			continue
+		}
+
+		if m, ok := publishedModels[p.Model]; ok {
+			models = append(models, m)
+			continue
+		}
+
+		pm, err := db.ReadPublishedModel(ref, user)
+		if err != nil {
+			log.Printf("[publishedModelList] could not resolve %s", ref)
+			log.Println(err)
+			continue
+		}
+		m := pm.Model
+		m.Name = p.Model
+		publishedModels[p.Model] = m
+		models = append(models, m)
+	}
+
+	return models
+
+}
+
+func compileSim(init *addie.InitSet) {
+
+	dsg := sim.ApplyInitSet(&design, init)
+	srcs := sim.GenerateClusterSource(dsg, modelList(), kryClusterSize)
+	for i, src := range srcs {
+		compileSimNode(i, src)
+	}
+}
+
+func compileSimNode(node int, src string) {
+
+	ioutil.WriteFile(simNodeFileName(node), []byte(src), 0644)
+
+	cmd := exec.Command("cyc", simNodeFileName(node))
 	cmd.Dir = userDir()
 	outp, err := cmd.Output()
 	if err != nil {
@@ -656,21 +837,59 @@ func compileSim() {
 		log.Println(err)
 	}
 
-	log.Println("cyc returned:")
+	log.Printf("cyc returned (kry%d):", node)
 	log.Println(string(outp))
 
 	cmd = exec.Command("./build_rcomp.sh")
-	cmd.Dir = userDir() + "/" + design.Name + ".cypk"
+	cmd.Dir = cypkNodeDir(node)
 	outp, err = cmd.Output()
 	if err != nil {
 		log.Println("could not build simulation")
 		log.Println(err)
 	}
+
+	err = packageTraces(cypkNodeDir(node))
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+/*
+The simulation replays traces from within its package directory so the
+uploaded traces travel with it to the sim node
+*/
+func packageTraces(cypk string) error {
+
+	fs, err := ioutil.ReadDir(traceDir())
+	if err != nil {
+		//no traces have been uploaded for this design
+		return nil
+	}
+
+	dir := cypk + "/traces"
+	os.MkdirAll(dir, 0755)
+
+	for _, f := range fs {
+		data, err := ioutil.ReadFile(traceDir() + "/" + f.Name())
+		if err != nil {
+			log.Println(err)
+			return fmt.Errorf("could not read trace file %s", f.Name())
+		}
+		err = ioutil.WriteFile(dir+"/"+f.Name(), data, 0644)
+		if err != nil {
+			log.Println(err)
+			return fmt.Errorf("could not package trace file %s", f.Name())
+		}
+	}
+
+	return nil
+
 }
 
-func compileTopDL() {
+func compileTopDL(profiles []addie.NodeProfile) {
 
-	xp := deter.DesignTopDL(&design)
+	xp := deter.DesignClusterTopDL(deter.ApplyProfiles(&design, profiles),
+		kryClusterSize)
 	topdl, err := xml.MarshalIndent(xp, "  ", "  ")
 	if err != nil {
 		log.Println(err)
@@ -840,23 +1059,211 @@ func generateRouterConfigs() error {
 	return nil
 }
 
+/*
+The initial condition set a request asks for by name, falling back to the one
+chosen in the simulation settings. No name at all means no set is applied.
+*/
+func selectInitSet(name string) (*addie.InitSet, sema.Diagnostics) {
+
+	var ds sema.Diagnostics
+
+	if name == "" {
+		name = simSettings.InitSet
+	}
+	if name == "" {
+		return nil, ds
+	}
+
+	s, ok := initSets[name]
+	if !ok {
+		ds.Elements = append(ds.Elements, sema.Diagnostic{
+			Level:   "error",
+			Message: fmt.Sprintf("[InitSet] the initial condition set [%s] does not exist", name),
+		})
+		return nil, ds
+	}
+
+	return &s, ds
+
+}
+
+func onInitSets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	var sets []addie.InitSet
+	for _, s := range initSets {
+		sets = append(sets, s)
+	}
+
+	js, err := json.Marshal(sets)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onInitSetUpdate(w http.ResponseWriter, r *http.Request,
+	ps httprouter.Params) {
+
+	set := new(addie.InitSet)
+	err := protocol.Unpack(r, set)
+	if err != nil || set.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	diagnostics := sema.CheckInitSet(*set, &design, modelList())
+	if !diagnostics.Fatal() {
+		err = db.UpdateInitSet(*set, design.Name, user)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+		initSets[set.Name] = *set
+	}
+
+	js, err := json.Marshal(diagnostics)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onInitSetDelete(w http.ResponseWriter, r *http.Request,
+	ps httprouter.Params) {
+
+	set := new(addie.InitSet)
+	err := protocol.Unpack(r, set)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = db.DeleteInitSet(set.Name, design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	delete(initSets, set.Name)
+
+}
+
+func paramsJson(w http.ResponseWriter) {
+
+	js, err := json.Marshal(design.Params)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onParams(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	paramsJson(w)
+}
+
+func onParamSet(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.Param)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = param.CheckName(msg.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	err = db.SetDesignParam(msg.Name, msg.Value, design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	design.Params[msg.Name] = msg.Value
+
+	paramsJson(w)
+
+}
+
+/*
+Deletes a design parameter, elements still referring to it fail the design
+check until they are changed
+*/
+func onParamDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.Param)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = db.DeleteDesignParam(msg.Name, design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	delete(design.Params, msg.Name)
+
+	paramsJson(w)
+
+}
+
 func onCompile(w http.ResponseWriter, r *http.Request,
 	ps httprouter.Params) {
 	log.Println("addie compiling design")
 
+	init, _ds := selectInitSet(r.URL.Query().Get("init"))
+
+	//the catalog is managed through the gatekeeper so it is read every time
+	profiles, err := db.ReadNodeProfiles()
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	//a missing init set is reported on its own, the design is not checked
+	//against a set that is not there
 	log.Println("checking design ...")
-	diagnostics := sema.Check(&design)
+	diagnostics := _ds
+	if !diagnostics.Fatal() {
+		diagnostics = sema.CheckCompile(&design, simSettings, modelList(), init,
+			profiles)
+	}
 	log.Println("OK")
 
 	if !diagnostics.Fatal() {
 		log.Println("compiling PnetDL ...")
-		compileSim()
+		compileSim(init)
 		log.Println("OK")
 
 		log.Println("compiling TopDL ...")
-		compileTopDL()
+		compileTopDL(profiles)
 		log.Println("OK")
 
+		recordModelUsage()
+
 		log.Println("building dns configs ...")
 		err := generateDnsServerConfig()
 		if err != nil {
@@ -883,63 +1290,248 @@ func onCompile(w http.ResponseWriter, r *http.Request,
 	w.Write(json)
 }
 
-func runSim() {
-
-	log.Println("addie running simulation")
+func recordModelUsage() {
 
-	cmd := exec.Command("./rcomp0",
-		strconv.FormatFloat(simSettings.Begin, 'e', -1, 64),
-		strconv.FormatFloat(simSettings.End, 'e', -1, 64),
-		strconv.FormatFloat(simSettings.MaxStep, 'e', -1, 64))
-	cmd.Dir = userDir() + "/" + design.Name + ".cypk"
-	_, err := cmd.Output()
+	design_key, err := db.ReadDesignKey(design.Name, user)
+	if err == nil {
+		err = db.RecordModelUsage(design_key)
+	}
 	if err != nil {
-		log.Println("could not run simulation")
+		log.Println("could not record the model versions the design was compiled with")
 		log.Println(err)
 	}
 
 }
 
-func runComputerCode(c addie.Computer) {
+func runSim() {
 
-}
+	log.Println("addie running simulation")
 
-type ConfigData struct {
-	User, XP, Id string
-}
+	execSim(sim.RuntimeArgs(simSettings), addie.SimRun{})
 
-func syncFileTBNode(file, target string) error {
-	return _syncFile(user, file, target, "/cypress/scripts/rsync_it_testbed.sh")
 }
 
-func syncFile(file, target string) error {
-	return _syncFile("root", file, target, "/cypress/scripts/rsync_it.sh")
-}
+func cypkDirs() []string {
 
-func _syncFile(u, file, target, script string) error {
+	var ds []string
+	for i := 0; i < kryClusterSize; i++ {
+		ds = append(ds, cypkNodeDir(i))
+	}
 
-	cmdt := []string{script, u, file, target}
+	return ds
 
-	cmd := exec.Command(script, cmdt[1:]...)
-	out, err := cmd.CombinedOutput()
+}
+
+func recordSimRun(run addie.SimRun) {
+
+	design_key, err := db.ReadDesignKey(design.Name, user)
 	if err != nil {
 		log.Println(err)
-		log.Println(string(out))
-		return fmt.Errorf("erorr syncing %s", file)
+		log.Println("[recordSimRun] error reading design key")
+		return
 	}
 
-	return nil
-}
-
-func cypressResource(rel_path string) ([]byte, error) {
-
-	abs_path := gopath + "/src/github.com/cycps/" + rel_path
-	bs, err := ioutil.ReadFile(abs_path)
+	run.Started = time.Now()
+	_, err = db.CreateSimRun(run, design_key)
 	if err != nil {
 		log.Println(err)
-		return nil, fmt.Errorf("Could not read cypress resource '%s'", abs_path)
+		log.Println("[recordSimRun] error recording simulation run")
 	}
-	return bs, nil
+
+}
+
+func execSim(args []string, run addie.SimRun) {
+
+	recordSimRun(run)
+
+	//the partitions of a cluster simulation are coupled, so they all have to be
+	//running at the same time
+	var cmds []*exec.Cmd
+	for i := 0; i < kryClusterSize; i++ {
+		cmd := exec.Command("./rcomp0", args...)
+		cmd.Dir = cypkNodeDir(i)
+		err := cmd.Start()
+		if err != nil {
+			log.Printf("could not start simulation partition kry%d", i)
+			log.Println(err)
+			continue
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	for _, cmd := range cmds {
+		err := cmd.Wait()
+		if err != nil {
+			log.Println("could not run simulation")
+			log.Println(err)
+		}
+	}
+
+}
+
+func findCheckpoint(name string) (*sim.Checkpoint, error) {
+
+	cs, err := sim.ListCheckpoints(cypkDirs())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range cs {
+		if c.Name == name {
+			return &c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no such checkpoint '%s'", name)
+
+}
+
+func onCheckpoints(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	cs, err := sim.ListCheckpoints(cypkDirs())
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	js, err := json.Marshal(cs)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onResume(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.Resume)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c, err := findCheckpoint(msg.Checkpoint)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(404)
+		return
+	}
+
+	diagnostics := sema.CheckParamOverrides(msg.Params, &design, modelList())
+	if diagnostics.Fatal() {
+		js, _ := json.Marshal(diagnostics)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(400)
+		w.Write(js)
+		return
+	}
+
+	log.Printf("addie resuming simulation from %s", c.Name)
+	go execSim(sim.ResumeArgs(simSettings, *c, msg.Params),
+		addie.SimRun{ResumedFrom: c.Name, Params: msg.Params})
+
+	w.Write([]byte("ok"))
+
+}
+
+func onSimRun(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	args := sim.RuntimeArgs(simSettings)
+	run := addie.SimRun{}
+
+	name := r.URL.Query().Get("init")
+	if name != "" {
+		set, ok := initSets[name]
+		if !ok {
+			log.Printf("[onSimRun] unknown initial condition set %s", name)
+			w.WriteHeader(404)
+			return
+		}
+		args = append(args, sim.InitArgs(&set)...)
+		run.InitSet = name
+	}
+
+	log.Println("addie running simulation")
+	go execSim(args, run)
+
+	w.Write([]byte("ok"))
+
+}
+
+func onSimRuns(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	design_key, err := db.ReadDesignKey(design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	runs, err := db.ReadSimRuns(design_key)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	js, err := json.Marshal(runs)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func runComputerCode(c addie.Computer) {
+
+}
+
+type ConfigData struct {
+	User, XP, Id string
+}
+
+func syncFileTBNode(file, target string) error {
+	return _syncFile(user, file, target, "/cypress/scripts/rsync_it_testbed.sh")
+}
+
+func syncFile(file, target string) error {
+	return _syncFile("root", file, target, "/cypress/scripts/rsync_it.sh")
+}
+
+func _syncFile(u, file, target, script string) error {
+
+	cmdt := []string{script, u, file, target}
+
+	cmd := exec.Command(script, cmdt[1:]...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Println(err)
+		log.Println(string(out))
+		return fmt.Errorf("erorr syncing %s", file)
+	}
+
+	return nil
+}
+
+func cypressResource(rel_path string) ([]byte, error) {
+
+	abs_path := gopath + "/src/github.com/cycps/" + rel_path
+	bs, err := ioutil.ReadFile(abs_path)
+	if err != nil {
+		log.Println(err)
+		return nil, fmt.Errorf("Could not read cypress resource '%s'", abs_path)
+	}
+	return bs, nil
 }
 
 func runCypressTemplate(rel_path, outfile string, data interface{}) error {
@@ -1320,6 +1912,43 @@ func onMstate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 }
 
+/*
+Publishes a model icon to the user's icon directory on the webserver and
+returns the name it was staged under
+*/
+func publishModelIcon(mdl, ext string, content []byte) string {
+
+	uuid := uuid.NewV4()
+	fn := "/tmp/" + uuid.String() + "_" + mdl + "." + ext
+
+	log.Println("saving icon " + fn)
+	err := ioutil.WriteFile(fn, content, 0644)
+	if err != nil {
+		log.Println(err)
+		log.Println("failed to save icon file")
+	}
+
+	err = sshCmd("web", "mkdir -p /cypress/web/ico/"+user)
+	if err != nil {
+		log.Println(err)
+		log.Println("could not create user model icon directory on webserver")
+	}
+
+	err = syncFile(fn, "web:/cypress/web/ico/"+user+"/"+mdl+"."+ext)
+	if err != nil {
+		log.Println(err)
+		log.Println("could not sync model icon to user icon dir on webserver")
+	}
+
+	err = os.Remove(fn)
+	if err != nil {
+		log.Printf("could not remove temporary icon file %s", fn)
+	}
+
+	return fn
+
+}
+
 func onModelIco(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 	log.Println("addie receiving model icon")
@@ -1346,8 +1975,7 @@ func onModelIco(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 	log.Printf("icon file size: %d", len(content))
 
-	uuid := uuid.NewV4()
-	fn := "/tmp/" + uuid.String() + "_" + mdl + ".png"
+	fn := publishModelIcon(mdl, "png", content)
 	m, ok := userModels[mdl]
 	if ok {
 		m.Icon = fn
@@ -1355,31 +1983,107 @@ func onModelIco(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		db.UpdateModel(m.Name, m, user)
 	}
 
-	log.Println("saving icon " + fn)
-	err = ioutil.WriteFile(fn, content, 0644)
+	//log.Println(r.MultipartForm)
+
+}
+
+func onTrace(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	log.Println("addie receiving trace")
+	err := r.ParseMultipartForm(200 * 1024 * 1024)
 	if err != nil {
+		log.Println("parse form failed")
 		log.Println(err)
-		log.Println("failed to save icon file")
+		w.WriteHeader(400)
+		return
 	}
 
-	err = sshCmd("web", "mkdir -p /cypress/web/ico/"+user)
+	ids := r.MultipartForm.Value["traceId"]
+	files := r.MultipartForm.File["traceFile"]
+	if len(ids) == 0 || len(files) == 0 {
+		log.Println("trace upload without a traceId or traceFile")
+		w.WriteHeader(400)
+		return
+	}
+
+	var id addie.Id
+	err = json.Unmarshal([]byte(ids[0]), &id)
 	if err != nil {
+		log.Println("bad trace id")
 		log.Println(err)
-		log.Println("could not create user model icon directory on webserver")
+		w.WriteHeader(400)
+		return
+	}
+
+	e, ok := design.Elements[id]
+	if !ok {
+		log.Printf("trace upload for unknown element %v", id)
+		w.WriteHeader(404)
+		return
+	}
+	t, ok := e.(addie.Trace)
+	if !ok {
+		log.Printf("trace upload for non-trace element %v", id)
+		w.WriteHeader(400)
+		return
+	}
+
+	fh := files[0]
+	fn := path.Base(fh.Filename)
+	format := trace.FormatOf(fn)
+	if format == "" {
+		log.Printf("trace file %s has an unknown format", fn)
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "trace files must be csv or jsonl")
+		return
 	}
 
-	err = syncFile(fn, "web:/cypress/web/ico/"+user+"/"+mdl+".png")
+	f, err := fh.Open()
 	if err != nil {
+		log.Println("error opening trace file")
 		log.Println(err)
-		log.Println("could not sync model icon to user icon dir on webserver")
+		w.WriteHeader(500)
+		return
+	}
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		log.Println("could not read trace file")
+		log.Println(err)
+		w.WriteHeader(500)
+		return
 	}
 
-	err = os.Remove(fn)
+	os.MkdirAll(traceDir(), 0755)
+	err = ioutil.WriteFile(traceDir()+"/"+fn, content, 0644)
 	if err != nil {
-		log.Printf("could not remove temporary icon file %s", fn)
+		log.Println(err)
+		log.Println("failed to save trace file")
+		w.WriteHeader(500)
+		return
 	}
 
-	//log.Println(r.MultipartForm)
+	cs, err := trace.Columns(traceDir()+"/"+fn, format)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(400)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+
+	t.File = fn
+	t.Format = format
+	t.Columns = strings.Join(cs, ",")
+	dbUpdate(t.Id, t)
+	design.Elements[t.Id] = t
+
+	js, err := json.Marshal(t)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
 
 }
 
@@ -1398,6 +2102,1273 @@ func onRawData(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	w.Write([]byte(data))
 }
 
+/*
+Exports the physical half of the design as Modelica, or a single user model
+when a model name is given with ?model=
+*/
+func onModelica(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	var src string
+	name := r.URL.Query().Get("model")
+	if name != "" {
+		m, ok := userModels[name]
+		if !ok {
+			log.Printf("[onModelica] unknown model %s", name)
+			w.WriteHeader(404)
+			return
+		}
+		src = modelica.ExportModel(&m)
+	} else {
+		src = modelica.ExportDesign(&design, modelList())
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(src))
+
+}
+
+/*
+Imports the models of the Modelica source in the request body into the user's
+model library. Nothing is saved when the importer reports an error, and models
+that would replace an existing model are refused.
+*/
+func onModelicaImport(w http.ResponseWriter, r *http.Request,
+	ps httprouter.Params) {
+
+	src, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	models, diagnostics := modelica.Import(string(src))
+	for _, m := range models {
+		if _, ok := userModels[m.Name]; ok {
+			diagnostics.Elements = append(diagnostics.Elements, sema.Diagnostic{
+				Level:   "error",
+				Message: fmt.Sprintf("a model named %s already exists", m.Name)})
+		}
+	}
+
+	if !diagnostics.Fatal() {
+		for _, m := range models {
+			err = db.CreateModel(m, user)
+			if err != nil {
+				log.Println(err)
+				w.WriteHeader(500)
+				return
+			}
+			userModels[m.Name] = m
+		}
+	}
+
+	js, err := json.Marshal(diagnostics)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+/*
+Lists the standard model library, or every version of one library model when
+a name is given with ?name=
+*/
+func onLibrary(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	var entries []stdlib.Entry
+	name := r.URL.Query().Get("name")
+	if name != "" {
+		entries = stdlib.Versions(name)
+		if len(entries) == 0 {
+			w.WriteHeader(404)
+			return
+		}
+	} else {
+		entries = stdlib.Catalogue()
+	}
+
+	js, err := json.Marshal(struct {
+		Version string         `json:"version"`
+		Models  []stdlib.Entry `json:"models"`
+	}{stdlib.Version, entries})
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onLibraryCopy(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.LibraryCopy)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	e, ok := stdlib.Lookup(msg.Name, msg.Version)
+	if !ok {
+		log.Printf("[onLibraryCopy] unknown library model %s@%s", msg.Name, msg.Version)
+		w.WriteHeader(404)
+		return
+	}
+
+	m := e.Model
+	if msg.As != "" {
+		m.Name = msg.As
+	}
+	if _, ok := userModels[m.Name]; ok {
+		log.Printf("[onLibraryCopy] model %s already exists", m.Name)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	m.Icon = publishModelIcon(m.Name, "svg", []byte(e.IconSvg))
+	err = db.CreateModel(m, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	userModels[m.Name] = m
+
+	js, err := json.Marshal(m)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onPublish(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.Publish)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	m, ok := userModels[msg.Model]
+	if !ok {
+		log.Printf("[onPublish] unknown model %s", msg.Model)
+		w.WriteHeader(404)
+		return
+	}
+
+	err = db.PublishModel(m, msg.Scope, msg.Version, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	for k := range publishedModels {
+		if ref, _ := addie.ParseModelRef(k); !ref.Pinned() {
+			delete(publishedModels, k)
+		}
+	}
+
+	w.Write([]byte(addie.ModelRef{Scope: msg.Scope, Name: m.Name,
+		Version: msg.Version}.String()))
+
+}
+
+func onPublished(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	models, err := db.ReadPublishedModels(user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	js, err := json.Marshal(models)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+/*
+Phyos reference their model by key in the database, so a renamed model keeps
+its phyos, the in memory design has to follow the rename
+*/
+func renamePhyoModels(old, name string) {
+
+	for k, e := range design.Elements {
+		p, ok := e.(addie.Phyo)
+		if ok && p.Model == old {
+			p.Model = name
+			design.Elements[k] = p
+		}
+	}
+
+}
+
+func onModelVersions(w http.ResponseWriter, r *http.Request,
+	ps httprouter.Params) {
+
+	versions, err := db.ReadModelVersions(r.URL.Query().Get("model"), user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(404)
+		return
+	}
+
+	js, err := json.Marshal(versions)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+/*
+Diffs two versions of a model given as ?model=&from=&to=, without a to
+version the current model is the newer side
+*/
+func onModelDiff(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	name := r.URL.Query().Get("model")
+	to, ok := userModels[name]
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	fv, err := db.ReadModelVersion(name, from, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(404)
+		return
+	}
+
+	if r.URL.Query().Get("to") != "" {
+		tn, err := strconv.Atoi(r.URL.Query().Get("to"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		tv, err := db.ReadModelVersion(name, tn, user)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(404)
+			return
+		}
+		to = tv.Model
+	}
+
+	js, err := json.Marshal(addie.DiffModels(fv.Model, to))
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onModelRollback(w http.ResponseWriter, r *http.Request,
+	ps httprouter.Params) {
+
+	msg := new(protocol.ModelRollback)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	current, ok := userModels[msg.Model]
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+
+	v, err := db.ReadModelVersion(msg.Model, msg.Version, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(404)
+		return
+	}
+
+	//a rollback restores the equations of a model, not an old name or tests
+	m := v.Model
+	m.Name = current.Name
+	m.Tests = current.Tests
+	err = db.UpdateModel(current.Name, m, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	userModels[m.Name] = m
+
+	js, err := json.Marshal(m)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onModelUsage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	usage, err := db.ReadModelUsage(r.URL.Query().Get("model"), user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(404)
+		return
+	}
+
+	js, err := json.Marshal(usage)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onAnalysis(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.Analysis)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var p *analysis.Problem
+	if msg.Model != "" {
+		m, ok := userModels[msg.Model]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		p, err = analysis.ModelProblem(m, msg.Params, msg.Inputs)
+	} else {
+		p, err = analysis.PhyoProblem(&design, modelList(), msg.Phyos, msg.Params,
+			msg.Inputs)
+	}
+
+	var res *analysis.Result
+	if err == nil {
+		for k, v := range msg.Guess {
+			p.Guess[k] = v
+		}
+		res, err = analysis.Analyze(p, msg.Point)
+	}
+	if err != nil {
+		log.Printf("[onAnalysis] %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	js, err := json.Marshal(res)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+/*
+Records a system that an element update created implicitly, the database
+nests such systems at the root
+*/
+func trackSystem(sys string) {
+
+	if _, ok := systems[sys]; ok || sys == "" {
+		return
+	}
+	s := addie.System{Name: sys}
+	if sys != addie.RootSystem {
+		s.Parent = addie.RootSystem
+	}
+	systems[sys] = s
+
+}
+
+func systemsJson(w http.ResponseWriter) {
+
+	_, syss := design.Collapse(systems, nil)
+	js, err := json.Marshal(syss)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onSystems(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	systemsJson(w)
+
+}
+
+func onSystemCreate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.SystemOp)
+	err := protocol.Unpack(r, msg)
+	if err != nil || msg.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if msg.Parent == "" {
+		msg.Parent = addie.RootSystem
+	}
+
+	if _, ok := systems[msg.Name]; ok {
+		log.Printf("[onSystemCreate] system %s already exists", msg.Name)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	err = systems.CheckNest(msg.Name, msg.Parent)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	_, err = db.CreateSystem(msg.Name, design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	err = db.NestSystem(msg.Name, msg.Parent, design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	systems[msg.Name] = addie.System{Name: msg.Name, Parent: msg.Parent}
+
+	systemsJson(w)
+
+}
+
+func onSystemRename(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.SystemOp)
+	err := protocol.Unpack(r, msg)
+	if err != nil || msg.As == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s, ok := systems[msg.Name]
+	if !ok || msg.Name == addie.RootSystem {
+		log.Printf("[onSystemRename] system %s can not be renamed", msg.Name)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := systems[msg.As]; ok {
+		log.Printf("[onSystemRename] system %s already exists", msg.As)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	err = db.RenameSystem(msg.Name, msg.As, design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	//the new system is empty so moving the elements into it can not collide
+	moved, err := design.MoveElements(design.SystemElements(msg.Name), msg.As)
+	if err != nil {
+		log.Println(err)
+	}
+	relabelMoved(moved, msg.Name, msg.As)
+	for _, c := range systems.Children(msg.Name) {
+		child := systems[c]
+		child.Parent = msg.As
+		systems[c] = child
+	}
+	delete(systems, msg.Name)
+	s.Name = msg.As
+	systems[msg.As] = s
+
+	systemsJson(w)
+
+}
+
+func onSystemNest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.SystemOp)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s, ok := systems[msg.Name]
+	if !ok {
+		log.Printf("[onSystemNest] unknown system %s", msg.Name)
+		w.WriteHeader(404)
+		return
+	}
+	err = systems.CheckNest(msg.Name, msg.Parent)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	err = db.NestSystem(msg.Name, msg.Parent, design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	s.Parent = msg.Parent
+	systems[msg.Name] = s
+
+	systemsJson(w)
+
+}
+
+/*
+Deletes a system, its elements and nested systems move up to its parent
+*/
+func onSystemDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.SystemOp)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s, ok := systems[msg.Name]
+	if !ok || msg.Name == addie.RootSystem {
+		log.Printf("[onSystemDelete] system %s can not be deleted", msg.Name)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	members := design.SystemElements(msg.Name)
+	for _, id := range members {
+		if _, ok := design.Elements[addie.Id{Name: id.Name, Sys: s.Parent,
+			Design: id.Design}]; ok {
+			log.Printf("[onSystemDelete] %s already has an element named %s",
+				s.Parent, id.Name)
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	}
+
+	err = db.DeleteSystem(msg.Name, design.Name, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	moved, err := design.MoveElements(members, s.Parent)
+	if err != nil {
+		log.Println(err)
+	}
+	relabelMoved(moved, msg.Name, s.Parent)
+	for _, c := range systems.Children(msg.Name) {
+		child := systems[c]
+		child.Parent = s.Parent
+		systems[c] = child
+	}
+	delete(systems, msg.Name)
+
+	systemsJson(w)
+
+}
+
+/*
+Moves elements between systems, links and plinks keep referring to the moved
+elements. The design is sent back so clients pick up the rewired ids.
+*/
+func onSystemMove(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.Move)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := systems[msg.Sys]; !ok {
+		log.Printf("[onSystemMove] unknown system %s", msg.Sys)
+		w.WriteHeader(404)
+		return
+	}
+
+	for _, id := range msg.Elements {
+		nid := addie.Id{Name: id.Name, Sys: msg.Sys, Design: id.Design}
+		if _, ok := design.Elements[nid]; ok && nid != id {
+			log.Printf("[onSystemMove] %s already has an element named %s",
+				msg.Sys, id.Name)
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	}
+
+	moved, err := design.MoveElements(msg.Elements, msg.Sys)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	for id, nid := range moved {
+		_, err = db.UpdateId(id, nid, user)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	relabelMoved(moved, "", "")
+
+	onRead(w, r, ps)
+
+}
+
+/*
+Carries moves of elements between systems over to the initial condition sets
+and template instances that refer to them. The sets are stored by element so
+only the copies here change. Instances made in the system from are made in the
+system to from now on, when from is not empty.
+*/
+func relabelMoved(moved map[addie.Id]addie.Id, from, to string) {
+
+	for name, set := range initSets {
+		for i, init := range set.Inits {
+			if nid, ok := moved[init.Phyo]; ok {
+				set.Inits[i].Phyo = nid
+			}
+		}
+		initSets[name] = set
+	}
+
+	for k, inst := range templateInstances {
+		changed := from != "" && inst.Sys == from
+		if changed {
+			inst.Sys = to
+		}
+		for n, id := range inst.Elements {
+			if nid, ok := moved[id]; ok {
+				inst.Elements[n] = nid
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		templateInstances[k] = inst
+		_, err := db.SaveTemplateInstance(inst, design.Name, user)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+}
+
+func isLink(e addie.Identify) bool {
+
+	switch e.(type) {
+	case addie.Link, addie.Plink:
+		return true
+	}
+	return false
+
+}
+
+/*
+Places template elements in the design. Nodes go first so the links and
+plinks between them have endpoints to refer to, elements that already exist
+are updated.
+*/
+func placeElements(elements []addie.Identify) {
+
+	for _, links := range []bool{false, true} {
+		for _, e := range elements {
+			if isLink(e) != links {
+				continue
+			}
+			if _, ok := design.Elements[e.Identify()]; ok {
+				dbUpdate(e.Identify(), e)
+			} else {
+				dbCreate(e)
+			}
+			design.Elements[e.Identify()] = e
+		}
+	}
+
+}
+
+func removeElement(id addie.Id) {
+
+	e, ok := design.Elements[id]
+	if !ok {
+		return
+	}
+	db.DeleteId(id, user)
+	if l, ok := e.(addie.Link); ok {
+		db.DeleteInterface(l.Endpoints[0], user)
+		db.DeleteInterface(l.Endpoints[1], user)
+	}
+	delete(design.Elements, id)
+
+}
+
+/*
+A remade template instance waiting to replace the instance at index k
+*/
+type remade struct {
+	k        int
+	inst     templates.Instance
+	elements []addie.Identify
+}
+
+/*
+Remakes the instances of a template in the design that were made from an
+older version of it, without touching the design. Nothing is remade when a new
+element of an instance would take the place of an element it did not make.
+*/
+func planTemplateSync(t templates.Template) ([]remade, error) {
+
+	var plan []remade
+	claimed := make(map[addie.Id]bool)
+
+	for k, old := range templateInstances {
+		if old.Template != t.Name || old.Version >= t.Version {
+			continue
+		}
+
+		inst, elements, err := templates.Remake(t, old, design.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		owned := make(map[addie.Id]bool)
+		for _, id := range old.Elements {
+			owned[id] = true
+		}
+		for _, e := range elements {
+			id := e.Identify()
+			_, exists := design.Elements[id]
+			if (exists && !owned[id]) || claimed[id] {
+				return nil, fmt.Errorf("an element named %s already exists in %s",
+					id.Name, id.Sys)
+			}
+			claimed[id] = true
+		}
+
+		plan = append(plan, remade{k, inst, elements})
+	}
+
+	return plan, nil
+
+}
+
+/*
+Replaces instances with the ones planTemplateSync remade. Elements the
+template no longer has are removed.
+*/
+func applyTemplateSync(plan []remade) error {
+
+	for _, r := range plan {
+		old := templateInstances[r.k]
+
+		kept := make(map[addie.Id]bool)
+		for _, id := range r.inst.Elements {
+			kept[id] = true
+		}
+		for _, id := range old.Elements {
+			if !kept[id] {
+				removeElement(id)
+			}
+		}
+		placeElements(r.elements)
+
+		_, err := db.SaveTemplateInstance(r.inst, design.Name, user)
+		if err != nil {
+			return err
+		}
+		templateInstances[r.k] = r.inst
+	}
+
+	return nil
+
+}
+
+func onTemplates(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	ts, err := db.ReadTemplates(user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	js, err := json.Marshal(ts)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onTemplateInstances(w http.ResponseWriter, r *http.Request,
+	ps httprouter.Params) {
+
+	js, err := json.Marshal(templateInstances)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+/*
+Saves elements of the design as a template, the instances of an existing
+template in this design are remade from the new version
+*/
+func onTemplateSave(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.TemplateSave)
+	err := protocol.Unpack(r, msg)
+	if err != nil || msg.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var elements []addie.Identify
+	for _, id := range msg.Elements {
+		e, ok := design.Elements[id]
+		if !ok {
+			log.Printf("[onTemplateSave] unknown element %s", id)
+			w.WriteHeader(404)
+			return
+		}
+		elements = append(elements, e)
+	}
+
+	t, err := templates.New(msg.Name, msg.Params, elements)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	//the template is only saved when its instances here can be remade
+	t.Version = 1
+	if saved, err := db.ReadTemplate(t.Name, user); err == nil {
+		t.Version = saved.Version + 1
+	}
+	plan, err := planTemplateSync(t)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	t.Version, err = db.SaveTemplate(t, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+	for i := range plan {
+		plan[i].inst.Version = t.Version
+	}
+
+	err = applyTemplateSync(plan)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	onRead(w, r, ps)
+
+}
+
+func onTemplateInstantiate(w http.ResponseWriter, r *http.Request,
+	ps httprouter.Params) {
+
+	msg := new(protocol.Instantiate)
+	err := protocol.Unpack(r, msg)
+	if err != nil || msg.Count < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if msg.Sys == "" {
+		msg.Sys = addie.RootSystem
+	}
+	if _, ok := systems[msg.Sys]; !ok {
+		log.Printf("[onTemplateInstantiate] unknown system %s", msg.Sys)
+		w.WriteHeader(404)
+		return
+	}
+
+	t, err := db.ReadTemplate(msg.Template, user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(404)
+		return
+	}
+
+	//make every instance before placing any so a collision leaves no debris
+	var insts []templates.Instance
+	var elements []addie.Identify
+	names := make(map[addie.Id]bool)
+	for i := msg.Start; i < msg.Start+msg.Count; i++ {
+		inst, es, err := templates.Instantiate(*t, design.Name, msg.Sys, msg.Pattern,
+			i, msg.Params)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		for _, e := range es {
+			_, exists := design.Elements[e.Identify()]
+			if exists || names[e.Identify()] {
+				err = fmt.Errorf("an element named %s already exists in %s",
+					e.Identify().Name, msg.Sys)
+				log.Println(err)
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(err.Error()))
+				return
+			}
+			names[e.Identify()] = true
+		}
+		insts = append(insts, inst)
+		elements = append(elements, es...)
+	}
+
+	placeElements(elements)
+
+	for _, inst := range insts {
+		inst.Id, err = db.SaveTemplateInstance(inst, design.Name, user)
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(500)
+			return
+		}
+		templateInstances = append(templateInstances, inst)
+	}
+
+	onRead(w, r, ps)
+
+}
+
+/*
+Remakes the instances of a template in this design that are older than the
+template, templates saved from other designs leave instances here behind
+*/
+func onTemplateSync(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	t, err := db.ReadTemplate(r.URL.Query().Get("template"), user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(404)
+		return
+	}
+
+	plan, err := planTemplateSync(*t)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	err = applyTemplateSync(plan)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	onRead(w, r, ps)
+
+}
+
+func onGenerate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(protocol.Generate)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if msg.Sys == "" {
+		msg.Sys = addie.RootSystem
+	}
+	if _, ok := systems[msg.Sys]; !ok {
+		log.Printf("[onGenerate] unknown system %s", msg.Sys)
+		w.WriteHeader(404)
+		return
+	}
+
+	elements, err := topo.Generate(msg.Spec, design.Name, msg.Sys)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	for _, e := range elements {
+		if _, ok := design.Elements[e.Identify()]; ok {
+			err = fmt.Errorf("an element named %s already exists in %s",
+				e.Identify().Name, msg.Sys)
+			log.Println(err)
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	placeElements(elements)
+
+	onRead(w, r, ps)
+
+}
+
+/*
+Lays the design out and stores the positions and link paths that changed
+*/
+func onLayout(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	msg := new(layout.Options)
+	err := protocol.Unpack(r, msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	res, err := layout.Layout(&design, systems, *msg)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	for _, e := range layout.Apply(&design, res) {
+		dbUpdate(e.Identify(), e)
+		design.Elements[e.Identify()] = e
+	}
+
+	onRead(w, r, ps)
+
+}
+
+type exportType struct {
+	ctype, ext string
+}
+
+var exportTypes = map[string]exportType{
+	"dot":          {"text/vnd.graphviz", "dot"},
+	"graphml":      {"application/graphml+xml", "graphml"},
+	"containerlab": {"application/zip", "clab.zip"},
+	"mininet":      {"text/x-python", "py"},
+	"compose":      {"application/zip", "compose.zip"},
+}
+
+/*
+The Docker Compose project of the design along with the simulation packages
+its kry nodes run, so the design has to be compiled first
+*/
+func composeBundle() (emu.Files, error) {
+
+	files, err := emu.Compose(&design, simSettings, kryClusterSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < kryClusterSize; i++ {
+		dir := cypkNodeDir(i)
+		err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(userDir(), p)
+			if err != nil {
+				return err
+			}
+			files[rel], err = ioutil.ReadFile(p)
+			return err
+		})
+		if err != nil {
+			log.Println(err)
+			return nil, fmt.Errorf("could not package %s, compile the design first",
+				path.Base(dir))
+		}
+	}
+
+	return files, nil
+
+}
+
+/*
+Exports the topology of the design as a Graphviz or GraphML file, for local
+emulation as a zipped containerlab lab or a Mininet script, or as a zipped
+Docker Compose project. The format is given with ?format= and defaults to dot.
+*/
+func onExport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "dot"
+	}
+	t, ok := exportTypes[format]
+	if !ok {
+		log.Printf("[onExport] unknown format %s", format)
+		w.WriteHeader(400)
+		return
+	}
+
+	var out []byte
+	var err error
+	switch format {
+	case "containerlab":
+		var files emu.Files
+		files, err = emu.Containerlab(&design)
+		if err != nil {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		out, err = files.Zip()
+	case "mininet":
+		out, err = emu.Mininet(&design)
+		if err != nil {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	case "compose":
+		var files emu.Files
+		files, err = composeBundle()
+		if err != nil {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		out, err = files.Zip()
+	default:
+		out, err = graph.Export(&design, systems, format)
+	}
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", t.ctype)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"%s.%s\"", design.Name, t.ext))
+	w.Write(out)
+
+}
+
+/*
+Imports the TopDL experiment in the request body into the system given with
+?sys=, the root system by default
+*/
+func onImportTopDL(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	sys := r.URL.Query().Get("sys")
+	if sys == "" {
+		sys = addie.RootSystem
+	}
+	if _, ok := systems[sys]; !ok {
+		log.Printf("[onImportTopDL] unknown system %s", sys)
+		w.WriteHeader(404)
+		return
+	}
+
+	src, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	elements, err := deter.ImportTopDL(src, design.Name, sys)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	for _, e := range elements {
+		if _, ok := design.Elements[e.Identify()]; ok {
+			err = fmt.Errorf("an element named %s already exists in %s",
+				e.Identify().Name, sys)
+			log.Println(err)
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	placeElements(elements)
+
+	onRead(w, r, ps)
+
+}
+
 //TODO ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
 //The way to do this is to have 1 addie instance and run (user,design) handler
 //pairs as goroutines
@@ -1412,8 +3383,45 @@ func listen() {
 	router.GET("/"+design.Name+"/design/materialize", onMaterialize)
 	router.GET("/"+design.Name+"/design/dematerialize", onDeMaterialize)
 	router.POST("/"+design.Name+"/design/modelIco", onModelIco)
+	router.POST("/"+design.Name+"/design/trace", onTrace)
 	router.GET("/"+design.Name+"/design/mstate", onMstate)
+	router.GET("/"+design.Name+"/design/systems", onSystems)
+	router.POST("/"+design.Name+"/design/systems/create", onSystemCreate)
+	router.POST("/"+design.Name+"/design/systems/rename", onSystemRename)
+	router.POST("/"+design.Name+"/design/systems/nest", onSystemNest)
+	router.POST("/"+design.Name+"/design/systems/delete", onSystemDelete)
+	router.POST("/"+design.Name+"/design/systems/move", onSystemMove)
+	router.GET("/"+design.Name+"/design/templates", onTemplates)
+	router.GET("/"+design.Name+"/design/templates/instances", onTemplateInstances)
+	router.POST("/"+design.Name+"/design/templates/save", onTemplateSave)
+	router.POST("/"+design.Name+"/design/templates/instantiate", onTemplateInstantiate)
+	router.POST("/"+design.Name+"/design/templates/sync", onTemplateSync)
+	router.POST("/"+design.Name+"/design/generate", onGenerate)
+	router.POST("/"+design.Name+"/design/layout", onLayout)
+	router.GET("/"+design.Name+"/design/export", onExport)
+	router.POST("/"+design.Name+"/design/import/topdl", onImportTopDL)
+	router.GET("/"+design.Name+"/design/modelica", onModelica)
+	router.POST("/"+design.Name+"/design/modelica/import", onModelicaImport)
+	router.GET("/"+design.Name+"/library", onLibrary)
+	router.POST("/"+design.Name+"/library/copy", onLibraryCopy)
+	router.POST("/"+design.Name+"/library/publish", onPublish)
+	router.GET("/"+design.Name+"/library/published", onPublished)
+	router.GET("/"+design.Name+"/library/versions", onModelVersions)
+	router.GET("/"+design.Name+"/library/diff", onModelDiff)
+	router.POST("/"+design.Name+"/library/rollback", onModelRollback)
+	router.GET("/"+design.Name+"/library/usage", onModelUsage)
+	router.POST("/"+design.Name+"/analyze/linearize", onAnalysis)
 	router.GET("/"+design.Name+"/analyze/rawData", onRawData)
+	router.GET("/"+design.Name+"/design/initSets", onInitSets)
+	router.POST("/"+design.Name+"/design/initSets/update", onInitSetUpdate)
+	router.POST("/"+design.Name+"/design/initSets/delete", onInitSetDelete)
+	router.GET("/"+design.Name+"/design/params", onParams)
+	router.POST("/"+design.Name+"/design/params/set", onParamSet)
+	router.POST("/"+design.Name+"/design/params/delete", onParamDelete)
+	router.GET("/"+design.Name+"/sim/run", onSimRun)
+	router.GET("/"+design.Name+"/sim/checkpoints", onCheckpoints)
+	router.POST("/"+design.Name+"/sim/resume", onResume)
+	router.GET("/"+design.Name+"/sim/runs", onSimRuns)
 
 	err := doRead()
 	if err != nil {
@@ -229,6 +229,11 @@ func newXP(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	s.Begin = 0
 	s.End = 10
 	s.MaxStep = 1e-3
+	s.ClusterSize = 1
+	s.Solver = "ida"
+	s.AbsTol = 1e-6
+	s.RelTol = 1e-4
+	s.RealTimeRatio = 1
 
 	err = db.CreateSimSettings(s, design_key)
 	if err != nil {
@@ -271,6 +276,121 @@ func myDesigns(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
 }
 
+// Node Profiles --------------------------------------------------------------
+
+/*
+Writes the catalog of node profiles, it is shared by every user and only
+admins change it
+*/
+func profilesJson(w http.ResponseWriter) {
+
+	profiles, err := db.ReadNodeProfiles()
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	js, err := json.Marshal(profiles)
+	if err != nil {
+		log.Printf("[profiles] error marshalling json")
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+
+}
+
+func onProfiles(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	_, err := getUser(r)
+	if err != nil {
+		w.WriteHeader(401)
+		return
+	}
+
+	profilesJson(w)
+
+}
+
+/*
+Checks that the caller is logged in as an admin, the response is written when
+they are not
+*/
+func adminUser(w http.ResponseWriter, r *http.Request) bool {
+
+	user, err := getUser(r)
+	if err != nil {
+		w.WriteHeader(401)
+		return false
+	}
+
+	admin, err := db.IsAdmin(user)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return false
+	}
+	if !admin {
+		log.Printf("[adminUser] '%s' is not an admin", user)
+		w.WriteHeader(403)
+		return false
+	}
+
+	return true
+
+}
+
+func onProfileSave(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if !adminUser(w, r) {
+		return
+	}
+
+	p := new(addie.NodeProfile)
+	err := protocol.Unpack(r, p)
+	if err != nil || p.Name == "" {
+		w.WriteHeader(400)
+		return
+	}
+
+	err = db.SaveNodeProfile(*p)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	profilesJson(w)
+
+}
+
+func onProfileDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if !adminUser(w, r) {
+		return
+	}
+
+	p := new(addie.NodeProfile)
+	err := protocol.Unpack(r, p)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	err = db.DeleteNodeProfile(p.Name)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(500)
+		return
+	}
+
+	profilesJson(w)
+
+}
+
 func main() {
 
 	router := httprouter.New()
@@ -279,6 +399,9 @@ func main() {
 	router.POST("/newXP", newXP)
 	router.GET("/myDesigns", myDesigns)
 	router.GET("/launchAddie", launchAddie)
+	router.GET("/profiles", onProfiles)
+	router.POST("/admin/profiles/save", onProfileSave)
+	router.POST("/admin/profiles/delete", onProfileDelete)
 
 	log.Printf("listening on http://::0:8081")
 	log.Fatal(